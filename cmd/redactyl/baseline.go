@@ -9,6 +9,7 @@ import (
 	"github.com/varalys/redactyl/internal/config"
 	"github.com/varalys/redactyl/internal/engine"
 	"github.com/varalys/redactyl/internal/report"
+	"github.com/varalys/redactyl/internal/types"
 )
 
 func init() {
@@ -31,6 +32,16 @@ func init() {
 			}
 
 			budget, globalBudget := resolveBudgets(0, lcfg, gcfg, 0)
+			jwtExpired, err := resolveJWTSeverity("jwt_expired_severity", "", lcfg.JWTExpiredSeverity, gcfg.JWTExpiredSeverity, types.SevLow)
+			if err != nil {
+				return err
+			}
+			jwtAlgNone, err := resolveJWTSeverity("jwt_alg_none_severity", "", lcfg.JWTAlgNoneSeverity, gcfg.JWTAlgNoneSeverity, "")
+			if err != nil {
+				return err
+			}
+
+			sensitive := mergeSensitivePaths(gcfg, lcfg)
 
 			cfg := engine.Config{
 				Root:                 abs,
@@ -46,13 +57,24 @@ func init() {
 				ScanArchives:         pickBool(false, lcfg.Archives, gcfg.Archives),
 				ScanContainers:       pickBool(false, lcfg.Containers, gcfg.Containers),
 				ScanIaC:              pickBool(false, lcfg.IaC, gcfg.IaC),
+				ScanBinaries:         pickBool(false, lcfg.Binaries, gcfg.Binaries),
 				ScanHelm:             pickBool(false, lcfg.Helm, gcfg.Helm),
 				ScanK8s:              pickBool(false, lcfg.K8s, gcfg.K8s),
+				ScanKustomize:        pickBool(false, lcfg.Kustomize, gcfg.Kustomize),
 				MaxArchiveBytes:      pickInt64(0, lcfg.MaxArchiveBytes, gcfg.MaxArchiveBytes),
 				MaxEntries:           pickInt(0, lcfg.MaxEntries, gcfg.MaxEntries),
 				MaxDepth:             pickInt(0, lcfg.MaxDepth, gcfg.MaxDepth),
+				MaxEntryRatio:        pickInt(0, lcfg.MaxEntryRatio, gcfg.MaxEntryRatio),
+				MaxArchiveRatio:      pickInt(0, lcfg.MaxArchiveRatio, gcfg.MaxArchiveRatio),
 				ScanTimeBudget:       budget,
 				GlobalArtifactBudget: globalBudget,
+				SensitivePaths:       sensitive.Patterns,
+				SensitiveExcludes:    sensitive.Exclude,
+				NoSensitiveDefaults:  pickBool(false, sensitive.DisableDefaults, nil),
+				JWTExpiredSeverity:   jwtExpired,
+				JWTAlgNoneSeverity:   jwtAlgNone,
+				DecodeDepth:          resolveDecodeDepth(0, false, lcfg.DecodeDepth, gcfg.DecodeDepth),
+				DecodeMinLength:      pickInt(0, lcfg.DecodeMinLength, gcfg.DecodeMinLength),
 				GitleaksConfig:       mergeGitleaksConfig(gcfg, lcfg),
 			}
 			results, err := engine.Scan(cfg)
@@ -41,20 +41,43 @@ var (
 	flagArchives             bool
 	flagContainers           bool
 	flagIaC                  bool
+	flagBinaries             bool
 	flagHelm                 bool
 	flagK8s                  bool
+	flagKustomize            bool
 	flagMaxArchiveBytes      int64
 	flagMaxEntries           int
 	flagMaxDepth             int
+	flagMaxEntryRatio        int
+	flagMaxArchiveRatio      int
 	flagScanTimeBudget       time.Duration
 	flagGlobalArtifactBudget time.Duration
+	flagJWTExpiredSeverity   string
+	flagJWTAlgNoneSeverity   string
+	flagDecodeDepth          int
+	flagDecodeMinLength      int
 
 	flagJSONExtended bool
 	flagNoTUI        bool
 	flagViewLast     bool
 	flagDemo         bool
 
-	flagRegistryImages []string
+	flagRegistryImages      []string
+	flagRegistryRepos       []string
+	flagRegistryCatalogs    []string
+	flagRegistryTags        string
+	flagRegistryConcurrency int
+
+	flagHelmCharts     []string
+	flagHelmRepos      []string
+	flagHelmRepoCharts []string
+	flagHelmRender     bool
+	flagHelmValues     []string
+
+	flagK8sCluster  bool
+	flagKubeconfig  string
+	flagKubeContext string
+	flagNamespace   string
 )
 
 func init() {
@@ -90,17 +113,38 @@ func init() {
 	cmd.Flags().BoolVar(&flagTable, "table", false, "output in table format with borders (now default)")
 	cmd.Flags().BoolVar(&flagText, "text", false, "output in plain text columnar format")
 	// deep scanning flags
-	cmd.Flags().BoolVar(&flagArchives, "archives", false, "enable deep scanning of archives (zip/tar/gz)")
+	cmd.Flags().BoolVar(&flagArchives, "archives", false, "enable deep scanning of archives (zip/jar/whl, tar.gz/xz/zst/bz2, 7z, deb, rpm, gz)")
 	cmd.Flags().BoolVar(&flagContainers, "containers", false, "enable deep scanning of container tarballs (Docker save)")
-	cmd.Flags().BoolVar(&flagIaC, "iac", false, "enable scanning IaC hotspots (tfstate, kubeconfigs)")
+	cmd.Flags().BoolVar(&flagIaC, "iac", false, "enable scanning IaC hotspots (Terraform, CloudFormation, Pulumi, Ansible, Compose, Dockerfiles, kubeconfigs)")
+	cmd.Flags().BoolVar(&flagBinaries, "binaries", false, "extract and scan printable strings from binaries (executables, shared libraries, class files) on disk and in archives and images")
 	cmd.Flags().BoolVar(&flagHelm, "helm", false, "enable scanning Helm charts (.tgz archives and directories)")
+	cmd.Flags().StringArrayVar(&flagHelmCharts, "helm-chart", nil, "scan a remote Helm chart (oci://host/charts/app:1.2.3 or https://host/app-1.2.3.tgz)")
+	cmd.Flags().StringArrayVar(&flagHelmRepos, "helm-repo", nil, "scan charts from a Helm repository URL (reads index.yaml)")
+	cmd.Flags().StringArrayVar(&flagHelmRepoCharts, "helm-repo-chart", nil, "chart to scan from --helm-repo as name, name@version or name@* (default: latest of every chart)")
+	cmd.Flags().BoolVar(&flagHelmRender, "helm-render", false, "render Helm templates with their values and scan the rendered manifests")
+	cmd.Flags().StringArrayVar(&flagHelmValues, "helm-values", nil, "values file merged over chart values when rendering (repeatable, later files win)")
 	cmd.Flags().BoolVar(&flagK8s, "k8s", false, "enable scanning Kubernetes manifests (YAML files)")
+	cmd.Flags().BoolVar(&flagKustomize, "kustomize", false, "build kustomization roots in-process and scan the rendered resources")
+	cmd.Flags().BoolVar(&flagK8sCluster, "k8s-cluster", false, "scan Secrets, ConfigMaps, workloads and Helm releases from a live cluster (read-only)")
+	cmd.Flags().StringVar(&flagKubeconfig, "kubeconfig", "", "kubeconfig for --k8s-cluster (default: $KUBECONFIG or ~/.kube/config)")
+	cmd.Flags().StringVar(&flagKubeContext, "kube-context", "", "kubeconfig context for --k8s-cluster (default: current context)")
+	cmd.Flags().StringVar(&flagNamespace, "namespace", "", "namespace for --k8s-cluster (default: all namespaces)")
 	cmd.Flags().StringArrayVar(&flagRegistryImages, "registry", nil, "scan remote container registry image (e.g. gcr.io/project/image:tag)")
+	cmd.Flags().StringArrayVar(&flagRegistryRepos, "registry-repo", nil, "scan every tag of a remote repository (e.g. host/team/app)")
+	cmd.Flags().StringArrayVar(&flagRegistryCatalogs, "registry-catalog", nil, "scan every repository listed in a registry catalog (e.g. registry.example.com)")
+	cmd.Flags().StringVar(&flagRegistryTags, "tags", "", "comma-separated tag globs for --registry-repo/--registry-catalog (e.g. 'v*')")
+	cmd.Flags().IntVar(&flagRegistryConcurrency, "registry-concurrency", 4, "max registry manifests scanned concurrently")
 	cmd.Flags().Int64Var(&flagMaxArchiveBytes, "max-archive-bytes", 32<<20, "max decompressed bytes per artifact before aborting")
 	cmd.Flags().IntVar(&flagMaxEntries, "max-entries", 1000, "max entries per archive/container before aborting")
 	cmd.Flags().IntVar(&flagMaxDepth, "max-depth", 2, "max recursion depth for nested archives")
+	cmd.Flags().IntVar(&flagMaxEntryRatio, "max-entry-ratio", 200, "max decompressed/compressed size ratio of one archive entry before skipping it as a zip bomb")
+	cmd.Flags().IntVar(&flagMaxArchiveRatio, "max-archive-ratio", 100, "max bytes decompressed from an archive (nested archives included) per byte of it before aborting")
 	cmd.Flags().DurationVar(&flagScanTimeBudget, "scan-time-budget", 10*time.Second, "time budget per artifact (e.g., 10s)")
 	cmd.Flags().DurationVar(&flagGlobalArtifactBudget, "global-artifact-budget", 0, "optional global time budget across all artifacts (e.g., 10s)")
+	cmd.Flags().StringVar(&flagJWTExpiredSeverity, "jwt-expired-severity", "", "severity of JWTs whose exp claim has passed: info|low|medium|high|unchanged (default low)")
+	cmd.Flags().StringVar(&flagJWTAlgNoneSeverity, "jwt-alg-none-severity", "", "severity of unsigned (alg none) JWTs: info|low|medium|high|unchanged (default unchanged)")
+	cmd.Flags().IntVar(&flagDecodeDepth, "decode-depth", defaultDecodeDepth, "levels of nested base64, hex and URL encoding to decode and rescan (off by default)")
+	cmd.Flags().IntVar(&flagDecodeMinLength, "decode-min-length", 0, "shortest base64, hex or URL-encoded blob to decode (default 20)")
 	cmd.Flags().BoolVar(&flagJSONExtended, "json-extended", false, "when used with --json, include artifact stats in the JSON object; adds a schema_version field")
 }
 
@@ -129,6 +173,41 @@ func resolveBudgets(flagBudget time.Duration, lcfg, gcfg config.FileConfig, flag
 	return budget, globalBudget
 }
 
+// resolveJWTSeverity picks a JWT severity policy from the flag, local and
+// global config values, falling back to def. "unchanged" leaves the
+// finding's severity as the detector set it.
+func resolveJWTSeverity(name, flag string, local, global *string, def types.Severity) (types.Severity, error) {
+	switch v := pickString(flag, local, global); v {
+	case "":
+		return def, nil
+	case "unchanged":
+		return "", nil
+	case string(types.SevInfo), string(types.SevLow), string(types.SevMed), string(types.SevHigh):
+		return types.Severity(v), nil
+	default:
+		return "", fmt.Errorf("invalid %s %q: want info, low, medium, high or unchanged", name, v)
+	}
+}
+
+// defaultDecodeDepth is how many levels of encoding are decoded when
+// neither the flag nor the config sets it. Decoding is opt-in.
+const defaultDecodeDepth = 0
+
+// resolveDecodeDepth picks the decoding depth from the flag when it was set,
+// then the local and global config, so that either can set it to 0 to
+// disable decoding.
+func resolveDecodeDepth(flag int, flagSet bool, local, global *int) int {
+	switch {
+	case flagSet:
+		return flag
+	case local != nil:
+		return *local
+	case global != nil:
+		return *global
+	}
+	return defaultDecodeDepth
+}
+
 func cloneStringPtr(src *string) *string {
 	if src == nil {
 		return nil
@@ -169,6 +248,24 @@ func mergeGitleaksConfig(gcfg, lcfg config.FileConfig) config.GitleaksConfig {
 	return merged
 }
 
+// mergeSensitivePaths combines the sensitive-path settings of the global and
+// local config: patterns and excludes from both apply, and the local
+// disable_defaults overrides the global one.
+func mergeSensitivePaths(gcfg, lcfg config.FileConfig) config.SensitivePathsConfig {
+	var merged config.SensitivePathsConfig
+	for _, src := range []*config.SensitivePathsConfig{gcfg.SensitivePaths, lcfg.SensitivePaths} {
+		if src == nil {
+			continue
+		}
+		merged.Patterns = append(merged.Patterns, src.Patterns...)
+		merged.Exclude = append(merged.Exclude, src.Exclude...)
+		if src.DisableDefaults != nil {
+			merged.DisableDefaults = cloneBoolPtr(src.DisableDefaults)
+		}
+	}
+	return merged
+}
+
 func runScan(cmd *cobra.Command, _ []string) error {
 	abs, _ := filepath.Abs(flagPath)
 
@@ -201,6 +298,18 @@ func runScan(cmd *cobra.Command, _ []string) error {
 	}
 
 	budget, globalBudget := resolveBudgets(flagScanTimeBudget, lcfg, gcfg, flagGlobalArtifactBudget)
+	jwtExpired, err := resolveJWTSeverity("jwt-expired-severity", flagJWTExpiredSeverity, lcfg.JWTExpiredSeverity, gcfg.JWTExpiredSeverity, types.SevLow)
+	if err != nil {
+		return err
+	}
+	jwtAlgNone, err := resolveJWTSeverity("jwt-alg-none-severity", flagJWTAlgNoneSeverity, lcfg.JWTAlgNoneSeverity, gcfg.JWTAlgNoneSeverity, "")
+	if err != nil {
+		return err
+	}
+
+	sensitive := mergeSensitivePaths(gcfg, lcfg)
+
+	decodeDepth := resolveDecodeDepth(flagDecodeDepth, cmd.Flags().Changed("decode-depth"), lcfg.DecodeDepth, gcfg.DecodeDepth)
 
 	cfg := engine.Config{
 		Root:                 abs,
@@ -221,14 +330,38 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		ScanArchives:         pickBool(flagArchives, lcfg.Archives, gcfg.Archives),
 		ScanContainers:       pickBool(flagContainers, lcfg.Containers, gcfg.Containers),
 		ScanIaC:              pickBool(flagIaC, lcfg.IaC, gcfg.IaC),
+		ScanBinaries:         pickBool(flagBinaries, lcfg.Binaries, gcfg.Binaries),
 		ScanHelm:             pickBool(flagHelm, lcfg.Helm, gcfg.Helm),
+		HelmCharts:           flagHelmCharts,
+		HelmRepos:            flagHelmRepos,
+		HelmRepoCharts:       flagHelmRepoCharts,
+		HelmRender:           pickBool(flagHelmRender, lcfg.HelmRender, gcfg.HelmRender),
+		HelmValues:           pickStrings(flagHelmValues, lcfg.HelmValues, gcfg.HelmValues),
 		ScanK8s:              pickBool(flagK8s, lcfg.K8s, gcfg.K8s),
+		ScanKustomize:        pickBool(flagKustomize, lcfg.Kustomize, gcfg.Kustomize),
+		ScanK8sCluster:       flagK8sCluster,
+		Kubeconfig:           flagKubeconfig,
+		KubeContext:          flagKubeContext,
+		K8sNamespace:         flagNamespace,
 		RegistryImages:       flagRegistryImages,
+		RegistryRepos:        flagRegistryRepos,
+		RegistryCatalogs:     flagRegistryCatalogs,
+		RegistryTags:         flagRegistryTags,
+		RegistryConcurrency:  flagRegistryConcurrency,
 		MaxArchiveBytes:      pickInt64(flagMaxArchiveBytes, lcfg.MaxArchiveBytes, gcfg.MaxArchiveBytes),
 		MaxEntries:           pickInt(flagMaxEntries, lcfg.MaxEntries, gcfg.MaxEntries),
 		MaxDepth:             pickInt(flagMaxDepth, lcfg.MaxDepth, gcfg.MaxDepth),
+		MaxEntryRatio:        pickInt(flagMaxEntryRatio, lcfg.MaxEntryRatio, gcfg.MaxEntryRatio),
+		MaxArchiveRatio:      pickInt(flagMaxArchiveRatio, lcfg.MaxArchiveRatio, gcfg.MaxArchiveRatio),
 		ScanTimeBudget:       budget,
 		GlobalArtifactBudget: globalBudget,
+		SensitivePaths:       sensitive.Patterns,
+		SensitiveExcludes:    sensitive.Exclude,
+		NoSensitiveDefaults:  pickBool(false, sensitive.DisableDefaults, nil),
+		JWTExpiredSeverity:   jwtExpired,
+		JWTAlgNoneSeverity:   jwtAlgNone,
+		DecodeDepth:          decodeDepth,
+		DecodeMinLength:      pickInt(flagDecodeMinLength, lcfg.DecodeMinLength, gcfg.DecodeMinLength),
 		GitleaksConfig:       mergeGitleaksConfig(gcfg, lcfg),
 	}
 
@@ -354,6 +487,11 @@ func runScan(cmd *cobra.Command, _ []string) error {
 		}
 	}
 
+	artifactReports := res.ArtifactStats.Artifacts
+	if artifactReports == nil {
+		artifactReports = []types.ArtifactReport{}
+	}
+
 	switch {
 	case flagSARIF:
 		stats := map[string]int{
@@ -361,8 +499,9 @@ func runScan(cmd *cobra.Command, _ []string) error {
 			"entries": res.ArtifactStats.AbortedByEntries,
 			"depth":   res.ArtifactStats.AbortedByDepth,
 			"time":    res.ArtifactStats.AbortedByTime,
+			"ratio":   res.ArtifactStats.AbortedByRatio,
 		}
-		if err := report.WriteSARIFWithStats(os.Stdout, newFindings, stats); err != nil {
+		if err := report.WriteSARIFWithArtifacts(os.Stdout, newFindings, stats, artifactReports); err != nil {
 			return fmt.Errorf("sarif error: %w", err)
 		}
 	case flagJSON:
@@ -377,7 +516,9 @@ func runScan(cmd *cobra.Command, _ []string) error {
 					"entries": res.ArtifactStats.AbortedByEntries,
 					"depth":   res.ArtifactStats.AbortedByDepth,
 					"time":    res.ArtifactStats.AbortedByTime,
+					"ratio":   res.ArtifactStats.AbortedByRatio,
 				},
+				"artifacts": artifactReports,
 			}
 			if err := enc.Encode(payload); err != nil {
 				return err
@@ -400,8 +541,8 @@ func runScan(cmd *cobra.Command, _ []string) error {
 				_, _ = fmt.Fprintln(os.Stderr, "  redactyl fix redact --file", f.Path, "--pattern", "'"+regexpQuote(f.Match)+"'", "--replace '<redacted>' --summary remediation.json")
 			}
 		}
-		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime > 0 {
-			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime)
+		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime+res.ArtifactStats.AbortedByRatio > 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d ratio=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime, res.ArtifactStats.AbortedByRatio)
 		}
 	case flagTable:
 		report.PrintTable(os.Stdout, newFindings, report.PrintOptions{NoColor: flagNoColor, Duration: res.Duration, FilesScanned: res.FilesScanned, TotalFiles: total, TotalFindings: len(res.Findings)})
@@ -416,8 +557,8 @@ func runScan(cmd *cobra.Command, _ []string) error {
 				_, _ = fmt.Fprintln(os.Stderr, "  redactyl fix redact --file", f.Path, "--pattern", "'"+regexpQuote(f.Match)+"'", "--replace '<redacted>' --summary remediation.json")
 			}
 		}
-		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime > 0 {
-			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime)
+		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime+res.ArtifactStats.AbortedByRatio > 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d ratio=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime, res.ArtifactStats.AbortedByRatio)
 		}
 	default:
 		report.PrintTable(os.Stdout, newFindings, report.PrintOptions{NoColor: flagNoColor, Duration: res.Duration, FilesScanned: res.FilesScanned, TotalFiles: total, TotalFindings: len(res.Findings)})
@@ -432,8 +573,8 @@ func runScan(cmd *cobra.Command, _ []string) error {
 				_, _ = fmt.Fprintln(os.Stderr, "  redactyl fix redact --file", f.Path, "--pattern", "'"+regexpQuote(f.Match)+"'", "--replace '<redacted>' --summary remediation.json")
 			}
 		}
-		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime > 0 {
-			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime)
+		if res.ArtifactStats.AbortedByBytes+res.ArtifactStats.AbortedByEntries+res.ArtifactStats.AbortedByDepth+res.ArtifactStats.AbortedByTime+res.ArtifactStats.AbortedByRatio > 0 {
+			_, _ = fmt.Fprintf(os.Stderr, "\nArtifact limits: bytes=%d entries=%d depth=%d time=%d ratio=%d\n", res.ArtifactStats.AbortedByBytes, res.ArtifactStats.AbortedByEntries, res.ArtifactStats.AbortedByDepth, res.ArtifactStats.AbortedByTime, res.ArtifactStats.AbortedByRatio)
 		}
 	}
 
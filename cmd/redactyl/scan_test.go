@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"github.com/varalys/redactyl/internal/config"
+	"github.com/varalys/redactyl/internal/types"
 )
 
 func strptr(s string) *string { return &s }
@@ -30,3 +31,46 @@ func TestResolveBudgets_Precedence(t *testing.T) {
 		t.Fatalf("global fallback failed: got (%v,%v)", b, g)
 	}
 }
+
+func TestResolveJWTSeverity(t *testing.T) {
+	if s, err := resolveJWTSeverity("x", "", nil, nil, types.SevLow); err != nil || s != types.SevLow {
+		t.Fatalf("default not applied: %q, %v", s, err)
+	}
+	if s, err := resolveJWTSeverity("x", "", strptr("medium"), strptr("info"), types.SevLow); err != nil || s != types.SevMed {
+		t.Fatalf("local config should win over global: %q, %v", s, err)
+	}
+	if s, err := resolveJWTSeverity("x", "unchanged", strptr("medium"), nil, types.SevLow); err != nil || s != "" {
+		t.Fatalf("flag should win and unchanged clear the policy: %q, %v", s, err)
+	}
+	if _, err := resolveJWTSeverity("x", "critical", nil, nil, types.SevLow); err == nil {
+		t.Fatal("expected error for unknown severity")
+	}
+}
+
+func TestResolveDecodeDepth(t *testing.T) {
+	if d := resolveDecodeDepth(2, false, nil, nil); d != 0 {
+		t.Fatalf("decoding should be off by default: %d", d)
+	}
+	zero := 0
+	if d := resolveDecodeDepth(2, false, &zero, intPtr(3)); d != 0 {
+		t.Fatalf("local config of 0 should disable decoding: %d", d)
+	}
+	if d := resolveDecodeDepth(2, false, nil, intPtr(3)); d != 3 {
+		t.Fatalf("global fallback failed: %d", d)
+	}
+	if d := resolveDecodeDepth(0, true, intPtr(3), nil); d != 0 {
+		t.Fatalf("flag should win over config: %d", d)
+	}
+}
+
+func TestMergeSensitivePaths(t *testing.T) {
+	gcfg := config.FileConfig{SensitivePaths: &config.SensitivePathsConfig{Patterns: []string{"**/*.kdbx"}, DisableDefaults: boolPtr(true)}}
+	lcfg := config.FileConfig{SensitivePaths: &config.SensitivePathsConfig{Patterns: []string{"**/secrets/*"}, Exclude: []string{"testdata/**"}, DisableDefaults: boolPtr(false)}}
+	got := mergeSensitivePaths(gcfg, lcfg)
+	if len(got.Patterns) != 2 || len(got.Exclude) != 1 || got.DisableDefaults == nil || *got.DisableDefaults {
+		t.Fatalf("unexpected merge: %+v", got)
+	}
+	if got := mergeSensitivePaths(config.FileConfig{}, config.FileConfig{}); got.DisableDefaults != nil || got.Patterns != nil {
+		t.Fatalf("empty configs should merge to nothing: %+v", got)
+	}
+}
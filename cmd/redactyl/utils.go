@@ -93,3 +93,13 @@ func pickBool(cli bool, local, global *bool) bool {
 	}
 	return false
 }
+
+func pickStrings(cli []string, local, global []string) []string {
+	if len(cli) > 0 {
+		return cli
+	}
+	if len(local) > 0 {
+		return local
+	}
+	return global
+}
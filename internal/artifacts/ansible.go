@@ -0,0 +1,163 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+var ansibleInventoryNames = map[string]bool{
+	"hosts": true, "hosts.ini": true, "hosts.yml": true, "hosts.yaml": true,
+	"inventory": true, "inventory.ini": true, "inventory.yml": true, "inventory.yaml": true,
+}
+
+func isAnsiblePath(rel string) bool {
+	if ansibleVarsScope(rel) != "" {
+		return true
+	}
+	return ansibleInventoryNames[strings.ToLower(filepath.Base(rel))]
+}
+
+// ansibleVarsScope returns "group_vars" or "host_vars" when rel lies in
+// such a directory.
+func ansibleVarsScope(rel string) string {
+	for _, part := range strings.Split(filepath.ToSlash(filepath.Dir(rel)), "/") {
+		if part == "group_vars" || part == "host_vars" {
+			return part
+		}
+	}
+	return ""
+}
+
+// ansibleVarsTarget returns the group or host a group_vars/host_vars file
+// applies to: the file name without extension (group_vars/web.yml) or the
+// directory below group_vars (group_vars/web/main.yml).
+func ansibleVarsTarget(rel, scope string) string {
+	parts := strings.Split(filepath.ToSlash(rel), "/")
+	for i, p := range parts {
+		if p == scope && i+1 < len(parts) {
+			return strings.TrimSuffix(strings.TrimSuffix(strings.TrimSuffix(parts[i+1], ".yml"), ".yaml"), ".json")
+		}
+	}
+	return ""
+}
+
+// ansibleSecretVar reports whether a variable name looks like it holds a
+// secret, including Ansible's own *_pass and *_passwd connection variables.
+func ansibleSecretVar(name string) bool {
+	l := strings.ToLower(name)
+	return keyLooksSensitive(name) || strings.HasSuffix(l, "_pass") || strings.HasSuffix(l, "_passwd")
+}
+
+// scanAnsible scans group_vars/host_vars files and inventories (YAML or INI)
+// for variables with secret-looking names stored in plaintext. Each is
+// emitted at rel::<path of the variable> with Entry.Line set. Files
+// encrypted whole with ansible-vault are skipped.
+func scanAnsible(rel string, data []byte, emit EntryFunc) {
+	if bytes.HasPrefix(bytes.TrimSpace(data), []byte("$ANSIBLE_VAULT;")) {
+		return
+	}
+	meta := map[string]string{"ansible_unencrypted": "true"}
+	switch scope := ansibleVarsScope(rel); scope {
+	case "group_vars":
+		meta["ansible_group"] = ansibleVarsTarget(rel, scope)
+	case "host_vars":
+		meta["ansible_host"] = ansibleVarsTarget(rel, scope)
+	default:
+		ext := strings.ToLower(filepath.Ext(rel))
+		if ext == ".ini" || ext == "" && ansibleINISectionRe.Match(data) {
+			scanAnsibleINI(rel, data, emit)
+			return
+		}
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 {
+		return
+	}
+	ansibleYAMLVars(rel, doc.Content[0], "", meta, emit)
+}
+
+func ansibleYAMLVars(rel string, n *yaml.Node, path string, meta map[string]string, emit EntryFunc) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		var vaulted []string
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if n.Content[i+1].Tag == "!vault" {
+				vaulted = append(vaulted, n.Content[i].Value)
+			}
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i].Value, n.Content[i+1]
+			child := joinK8sLocation(path, key)
+			if val.Kind != yaml.ScalarNode {
+				ansibleYAMLVars(rel, val, child, meta, emit)
+				continue
+			}
+			if val.Tag == "!vault" || val.Value == "" || val.Tag == "!!null" || !ansibleSecretVar(key) {
+				continue
+			}
+			m := copyMeta(meta)
+			m["ansible_var"] = key
+			if len(vaulted) > 0 {
+				m["ansible_vault_siblings"] = strings.Join(vaulted, ",")
+			}
+			emit(Entry{Path: rel + "::" + child, Data: []byte(key + ": " + val.Value), Metadata: m, Line: yamlContentLine(val)})
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			ansibleYAMLVars(rel, c, path+"["+strconv.Itoa(i)+"]", meta, emit)
+		}
+	}
+}
+
+var ansibleINISectionRe = regexp.MustCompile(`(?m)^\s*\[[^\]]+\]\s*$`)
+
+// scanAnsibleINI scans an INI inventory: host lines (host key=value ...) are
+// emitted at rel::<group>.<host>.<key> and [group:vars] entries at
+// rel::<group>.vars.<key>.
+func scanAnsibleINI(rel string, data []byte, emit EntryFunc) {
+	group := "ungrouped"
+	vars := false
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			group = strings.TrimSpace(line[1 : len(line)-1])
+			vars = strings.HasSuffix(group, ":vars")
+			group = strings.TrimSuffix(group, ":vars")
+			continue
+		}
+		fields := splitQuotedFields(line)
+		host := ""
+		if !vars {
+			if strings.HasSuffix(group, ":children") {
+				continue
+			}
+			host, fields = fields[0], fields[1:]
+		}
+		for _, f := range fields {
+			key, val, ok := strings.Cut(f, "=")
+			key = strings.TrimSpace(key)
+			val = unquoteValue(strings.TrimSpace(val))
+			if !ok || val == "" || !ansibleSecretVar(key) {
+				continue
+			}
+			meta := map[string]string{"ansible_var": key, "ansible_group": group, "ansible_unencrypted": "true"}
+			path := rel + "::" + group + ".vars." + key
+			if host != "" {
+				meta["ansible_host"] = host
+				path = rel + "::" + group + "." + host + "." + key
+			}
+			emit(Entry{Path: path, Data: []byte(key + "=" + val), Metadata: meta, Line: lineNo})
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanAnsible_GroupVars(t *testing.T) {
+	src := `ansible_user: deploy
+ansible_become_pass: hunter2-plain
+db:
+  password: S3cr3t-Pa55w0rd
+  api_token: !vault |
+    $ANSIBLE_VAULT;1.1;AES256
+    6162636465
+`
+	got := map[string]Entry{}
+	scanAnsible("inventories/prod/group_vars/web/main.yml", []byte(src), func(e Entry) { got[e.Path] = e })
+
+	become, ok := got["inventories/prod/group_vars/web/main.yml::ansible_become_pass"]
+	require.True(t, ok, "become pass missing: %v", entryPaths(got))
+	assert.Equal(t, "ansible_become_pass: hunter2-plain", string(become.Data))
+	assert.Equal(t, 2, become.Line)
+	assert.Equal(t, "web", become.Metadata["ansible_group"])
+	assert.Equal(t, "true", become.Metadata["ansible_unencrypted"])
+	assert.Empty(t, become.Metadata["ansible_vault_siblings"])
+
+	pw := got["inventories/prod/group_vars/web/main.yml::db.password"]
+	assert.Equal(t, "password", pw.Metadata["ansible_var"])
+	assert.Equal(t, "api_token", pw.Metadata["ansible_vault_siblings"])
+	assert.Len(t, got, 2)
+
+	host := map[string]Entry{}
+	scanAnsible("host_vars/db1.yaml", []byte("ansible_ssh_pass: pw\n"), func(e Entry) { host[e.Path] = e })
+	assert.Equal(t, "db1", host["host_vars/db1.yaml::ansible_ssh_pass"].Metadata["ansible_host"])
+
+	n := 0
+	scanAnsible("group_vars/all.yml", []byte("$ANSIBLE_VAULT;1.1;AES256\n6162\n"), func(Entry) { n++ })
+	assert.Zero(t, n, "vault-encrypted files are skipped")
+}
+
+func TestScanAnsible_INIInventory(t *testing.T) {
+	src := `# inventory
+bastion ansible_host=10.0.0.1 ansible_password="pa ss"
+
+[web]
+web1 ansible_host=10.0.0.2 ansible_become_pass=hunter2
+web2
+
+[web:vars]
+db_password=S3cr3t
+http_port=80
+
+[all:children]
+web
+`
+	got := map[string]Entry{}
+	scanAnsible("hosts", []byte(src), func(e Entry) { got[e.Path] = e })
+
+	bastion, ok := got["hosts::ungrouped.bastion.ansible_password"]
+	require.True(t, ok, "ungrouped host var missing: %v", entryPaths(got))
+	assert.Equal(t, "ansible_password=pa ss", string(bastion.Data))
+	assert.Equal(t, 2, bastion.Line)
+	assert.Equal(t, "bastion", bastion.Metadata["ansible_host"])
+
+	web1 := got["hosts::web.web1.ansible_become_pass"]
+	assert.Equal(t, "web", web1.Metadata["ansible_group"])
+	assert.Equal(t, 5, web1.Line)
+
+	vars := got["hosts::web.vars.db_password"]
+	assert.Equal(t, "db_password=S3cr3t", string(vars.Data))
+	assert.Empty(t, vars.Metadata["ansible_host"])
+	assert.Len(t, got, 3)
+}
+
+func TestScanAnsible_YAMLInventory(t *testing.T) {
+	src := `all:
+  hosts:
+    db1:
+      ansible_password: pw1
+  children:
+    web:
+      vars:
+        ansible_become_password: pw2
+`
+	got := map[string]Entry{}
+	scanAnsible("inventory.yml", []byte(src), func(e Entry) { got[e.Path] = e })
+	assert.Contains(t, got, "inventory.yml::all.hosts.db1.ansible_password")
+	assert.Contains(t, got, "inventory.yml::all.children.web.vars.ansible_become_password")
+	assert.Len(t, got, 2)
+}
@@ -0,0 +1,418 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/klauspost/compress/zstd"
+	"github.com/ulikunitz/xz"
+)
+
+// archiveFormat maps a file extension to the container it names and the
+// compression applied to it.
+type archiveFormat struct {
+	ext         string
+	kind        string // zip, tar, 7z, deb, rpm, apk, or file for a single compressed file
+	compression string // gzip, xz, zstd or bzip2; empty when uncompressed
+}
+
+// archiveFormats lists the recognised archive extensions. Compressed tarball
+// extensions come before the bare compression suffixes so that the longest
+// match wins.
+var archiveFormats = []archiveFormat{
+	{ext: ".tar.gz", kind: "tar", compression: "gzip"},
+	{ext: ".tgz", kind: "tar", compression: "gzip"},
+	{ext: ".tar.xz", kind: "tar", compression: "xz"},
+	{ext: ".txz", kind: "tar", compression: "xz"},
+	{ext: ".tar.zst", kind: "tar", compression: "zstd"},
+	{ext: ".tzst", kind: "tar", compression: "zstd"},
+	{ext: ".tar.bz2", kind: "tar", compression: "bzip2"},
+	{ext: ".tbz2", kind: "tar", compression: "bzip2"},
+	{ext: ".tbz", kind: "tar", compression: "bzip2"},
+	{ext: ".tar", kind: "tar"},
+	{ext: ".gem", kind: "tar"},
+	{ext: ".zip", kind: "zip"},
+	{ext: ".jar", kind: "zip"},
+	{ext: ".war", kind: "zip"},
+	{ext: ".ear", kind: "zip"},
+	{ext: ".whl", kind: "zip"},
+	{ext: ".nupkg", kind: "zip"},
+	{ext: ".apk", kind: "apk"},
+	{ext: ".7z", kind: "7z"},
+	{ext: ".deb", kind: "deb"},
+	{ext: ".rpm", kind: "rpm"},
+	{ext: ".gz", kind: "file", compression: "gzip"},
+	{ext: ".xz", kind: "file", compression: "xz"},
+	{ext: ".zst", kind: "file", compression: "zstd"},
+	{ext: ".bz2", kind: "file", compression: "bzip2"},
+}
+
+// archiveFormatFor returns the format of name, or false if name does not
+// look like an archive.
+func archiveFormatFor(name string) (archiveFormat, bool) {
+	lower := strings.ToLower(name)
+	for _, f := range archiveFormats {
+		if strings.HasSuffix(lower, f.ext) {
+			return f, true
+		}
+	}
+	return archiveFormat{}, false
+}
+
+// decompress wraps r in a reader for the given compression; an empty
+// compression returns r unchanged. xz and zstd streams that declare a
+// dictionary or window larger than maxDecoderWindow (for zstd,
+// zstdDecoderWindow(limits)) fail to read, as corrupt streams do.
+func decompress(compression string, r io.Reader, limits Limits) (io.ReadCloser, error) {
+	switch compression {
+	case "":
+		return io.NopCloser(r), nil
+	case "gzip":
+		return gzip.NewReader(r)
+	case "xz":
+		xr, err := xz.NewReader(newXZDictGuard(r, maxDecoderWindow))
+		if err != nil {
+			return nil, err
+		}
+		return io.NopCloser(xr), nil
+	case "zstd":
+		zr, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1), zstd.WithDecoderLowmem(true), zstd.WithDecoderMaxWindow(uint64(zstdDecoderWindow(limits))))
+		if err != nil {
+			return nil, err
+		}
+		return zr.IOReadCloser(), nil
+	case "bzip2":
+		return io.NopCloser(bzip2.NewReader(r)), nil
+	}
+	return nil, fmt.Errorf("unsupported compression %q", compression)
+}
+
+// scanArchiveWithStats scans the archive name held in ra, reporting its
+// entries under pathChain. Tar-based formats are streamed; zip and 7z need
+// random access, which ra provides.
+func scanArchiveWithStats(pathChain string, name string, ra io.ReaderAt, size int64, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
+	f, ok := archiveFormatFor(name)
+	if !ok {
+		return nil
+	}
+	limits, err := enterArchive(limits, ra, size, *decompressed)
+	if err != nil {
+		stats.ratioExceeded(fmt.Errorf("%s: %w", name, err))
+		return nil
+	}
+	if err := readArchive(f, pathChain, ra, size, limits, decompressed, entries, depth, deadline, emit, stats); err != nil {
+		stats.unscannable(pathChain, err.Error())
+		return err
+	}
+	return nil
+}
+
+// readArchive scans the archive in ra according to its format f.
+func readArchive(f archiveFormat, pathChain string, ra io.ReaderAt, size int64, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
+	r := io.NewSectionReader(ra, 0, size)
+	if f.kind == "apk" {
+		// Android packages are zips; Alpine packages are concatenated
+		// gzipped tarballs.
+		magic := make([]byte, 2)
+		if _, err := ra.ReadAt(magic, 0); err == nil && string(magic) == "PK" {
+			f.kind = "zip"
+		} else {
+			f.kind, f.compression = "tar", "gzip"
+		}
+	}
+	switch f.kind {
+	case "zip":
+		return scanZipReaderWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, ra, size, stats)
+	case "7z":
+		return scanSevenZipWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, ra, size, stats)
+	case "deb":
+		return scanDebWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, r, stats)
+	case "rpm":
+		return scanRPMWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, r, stats)
+	case "tar":
+		dr, err := decompress(f.compression, r, limits)
+		if err != nil {
+			return fmt.Errorf("corrupt %s: %w", f.compression, err)
+		}
+		defer safeClose(dr)
+		return scanTarReaderWithStats(pathChain, "::", limits, decompressed, entries, depth, deadline, emit, dr, stats)
+	case "file":
+		dr, err := decompress(f.compression, r, limits)
+		if err != nil {
+			return fmt.Errorf("corrupt %s: %w", f.compression, err)
+		}
+		defer safeClose(dr)
+		inner := strings.TrimSuffix(baseName(pathChain), f.ext)
+		if gz, ok := dr.(*gzip.Reader); ok {
+			if n := sanitizeEntryName(gz.Name); n != "" {
+				inner = n
+			}
+		}
+		scanEntryWithStats(pathChain+"::"+inner, inner, limitEntryRatio(dr, size, limits), limits, decompressed, entries, depth, deadline, emit, stats)
+	}
+	return nil
+}
+
+// baseName returns the last element of a virtual path, whose segments are
+// separated by "/" or "::".
+func baseName(p string) string {
+	if i := strings.LastIndex(p, "::"); i >= 0 {
+		p = p[i+2:]
+	}
+	if i := strings.LastIndex(p, "/"); i >= 0 {
+		p = p[i+1:]
+	}
+	return p
+}
+
+// scanEntryWithStats reads one archive entry from r and emits it if it is
+// text, or scans it as a nested archive. Entries that cannot be read in full
+// are recorded as unscannable, and what could be read is still scanned.
+// Entries at sensitive paths and credential stores are recorded in stats;
+// binary credential stores are not scanned further.
+func scanEntryWithStats(pathChain string, name string, r io.Reader, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) {
+	b, readErr := readAllBounded(r, limits, decompressed, deadline)
+	if e, ok := limits.SensitivePaths.Match(pathChain, name, b); ok {
+		stats.detected(e)
+	}
+	if readErr != nil {
+		readFailed(stats, limits, *decompressed, *entries, depth, deadline, pathChain, name, readErr)
+		// A truncated or corrupt entry is still scanned up to where it
+		// breaks.
+		if len(b) == 0 {
+			return
+		}
+	}
+	isBinary := looksBinary(b) || looksNonTextMIME(name, b)
+	if e, ok := InspectCredentialStore(pathChain, b); ok {
+		stats.detected(e)
+		if isBinary {
+			return
+		}
+	}
+	if isBinary {
+		if reason := unscannableReason(b); reason != "" {
+			stats.unscannable(pathChain, reason)
+			return
+		}
+		if isArchivePath(name) || !limits.Binaries || looksNonTextMIME(name, b) {
+			scanNestedEntry(pathChain, name, b, limits, decompressed, entries, depth, deadline, emit, stats)
+			return
+		}
+	}
+	emit(pathChain, b)
+	*entries++
+}
+
+// scanSevenZipWithStats scans a 7z archive. Encrypted archives and entries
+// are reported as errors and unscannable content.
+func scanSevenZipWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), ra io.ReaderAt, size int64, stats *Stats) error {
+	zr, err := sevenzip.NewReader(ra, size)
+	if err != nil {
+		var re *sevenzip.ReadError
+		if errors.As(err, &re) && re.Encrypted {
+			return errors.New("encrypted 7z archive")
+		}
+		return fmt.Errorf("corrupt 7z: %w", err)
+	}
+	for _, f := range zr.File {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
+			return nil
+		}
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		name := sanitizeEntryName(f.Name)
+		if name == "" {
+			continue // Skip invalid/traversal entries
+		}
+		rc, err := f.Open()
+		if err != nil {
+			stats.fail(fmt.Errorf("%s: %w", name, err))
+			stats.unscannable(archivePath+"::"+name, unreadableReason(err))
+			continue
+		}
+		scanEntryWithStats(archivePath+"::"+name, name, rc, limits, decompressed, entries, depth, deadline, emit, stats)
+		safeClose(rc)
+	}
+	return nil
+}
+
+// scanDebWithStats scans a Debian package: an ar archive whose
+// control.tar.* and data.tar.* members are streamed as tarballs in place, so
+// that files appear as pkg.deb::data.tar.xz::etc/app.conf.
+func scanDebWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader, stats *Stats) error {
+	br := bufio.NewReader(r)
+	magic := make([]byte, 8)
+	if _, err := io.ReadFull(br, magic); err != nil || string(magic) != "!<arch>\n" {
+		return errors.New("corrupt deb: not an ar archive")
+	}
+	for {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
+			return nil
+		}
+		var hdr [60]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("corrupt deb: %w", err)
+		}
+		size, err := strconv.ParseInt(strings.TrimSpace(string(hdr[48:58])), 10, 64)
+		if err != nil || size < 0 || string(hdr[58:60]) != "`\n" {
+			return errors.New("corrupt deb: bad ar member header")
+		}
+		name := sanitizeEntryName(strings.TrimSuffix(strings.TrimSpace(string(hdr[:16])), "/"))
+		member := io.LimitReader(br, size)
+		if f, ok := archiveFormatFor(name); ok && f.kind == "tar" && name != "" {
+			if err := scanCompressedTar(archivePath+"::"+name, f.compression, member, limits, decompressed, entries, depth, deadline, emit, stats); err != nil {
+				stats.fail(fmt.Errorf("%s: %w", name, err))
+				stats.unscannable(archivePath+"::"+name, err.Error())
+			}
+		} else if name != "" {
+			scanEntryWithStats(archivePath+"::"+name, name, member, limits, decompressed, entries, depth, deadline, emit, stats)
+		}
+		// Skip what was not read, plus the padding to an even offset.
+		if _, err := io.Copy(io.Discard, member); err != nil {
+			return fmt.Errorf("corrupt deb: %w", err)
+		}
+		if size%2 == 1 {
+			if _, err := br.Discard(1); err != nil && !errors.Is(err, io.EOF) {
+				return fmt.Errorf("corrupt deb: %w", err)
+			}
+		}
+	}
+}
+
+// scanCompressedTar streams a tarball compressed with compression.
+func scanCompressedTar(archivePath string, compression string, r io.Reader, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
+	dr, err := decompress(compression, r, limits)
+	if err != nil {
+		return fmt.Errorf("corrupt %s: %w", compression, err)
+	}
+	defer safeClose(dr)
+	return scanTarReaderWithStats(archivePath, "::", limits, decompressed, entries, depth, deadline, emit, dr, stats)
+}
+
+// rpmHeaderMagic starts the signature and main headers of an RPM package.
+var rpmHeaderMagic = []byte{0x8e, 0xad, 0xe8, 0x01}
+
+// scanRPMWithStats scans the payload of an RPM package: the lead and the
+// signature and main headers are skipped, and the compressed cpio archive
+// that follows is streamed, so that files appear as pkg.rpm::etc/app.conf.
+func scanRPMWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader, stats *Stats) error {
+	br := bufio.NewReader(r)
+	lead := make([]byte, 96)
+	if _, err := io.ReadFull(br, lead); err != nil || !bytes.Equal(lead[:4], []byte{0xed, 0xab, 0xee, 0xdb}) {
+		return errors.New("corrupt rpm: missing lead")
+	}
+	// The signature header is padded to a multiple of 8 bytes; the main
+	// header is not.
+	for _, pad := range []bool{true, false} {
+		hdr := make([]byte, 16)
+		if _, err := io.ReadFull(br, hdr); err != nil || !bytes.Equal(hdr[:4], rpmHeaderMagic) {
+			return errors.New("corrupt rpm: bad header")
+		}
+		n := int64(binary.BigEndian.Uint32(hdr[8:12]))*16 + int64(binary.BigEndian.Uint32(hdr[12:16]))
+		if pad && n%8 != 0 {
+			n += 8 - n%8
+		}
+		if _, err := br.Discard(int(n)); err != nil {
+			return fmt.Errorf("corrupt rpm: %w", err)
+		}
+	}
+	magic, _ := br.Peek(6)
+	var compression string
+	switch {
+	case bytes.HasPrefix(magic, []byte{0x1f, 0x8b}):
+		compression = "gzip"
+	case bytes.HasPrefix(magic, []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}):
+		compression = "xz"
+	case bytes.HasPrefix(magic, []byte{0x28, 0xb5, 0x2f, 0xfd}):
+		compression = "zstd"
+	case bytes.HasPrefix(magic, []byte("BZh")):
+		compression = "bzip2"
+	case bytes.HasPrefix(magic, []byte("07070")):
+	default:
+		return errors.New("unsupported rpm payload compression")
+	}
+	dr, err := decompress(compression, br, limits)
+	if err != nil {
+		return fmt.Errorf("corrupt %s: %w", compression, err)
+	}
+	defer safeClose(dr)
+	return scanCpioWithStats(archivePath, limits, decompressed, entries, depth, deadline, emit, dr, stats)
+}
+
+// maxCpioNameSize bounds the name size read from a cpio header (PATH_MAX),
+// so that a corrupt header cannot make the scanner allocate gigabytes.
+const maxCpioNameSize = 4096
+
+// scanCpioWithStats scans a cpio archive in the "newc" format used by RPM
+// payloads.
+func scanCpioWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader, stats *Stats) error {
+	br := bufio.NewReader(r)
+	for {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
+			return nil
+		}
+		var hdr [110]byte
+		if _, err := io.ReadFull(br, hdr[:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return fmt.Errorf("corrupt cpio: %w", err)
+		}
+		if magic := string(hdr[:6]); magic != "070701" && magic != "070702" {
+			return errors.New("corrupt cpio: unsupported header")
+		}
+		field := func(i int) (int64, error) {
+			return strconv.ParseInt(string(hdr[6+8*i:14+8*i]), 16, 64)
+		}
+		mode, err1 := field(1)
+		size, err2 := field(6)
+		nameSize, err3 := field(11)
+		if err := errors.Join(err1, err2, err3); err != nil || size < 0 || nameSize <= 0 || nameSize > maxCpioNameSize {
+			return errors.New("corrupt cpio: bad header")
+		}
+		// The name (NUL-terminated) and the data are each padded so that
+		// they end on a 4-byte boundary.
+		nameBuf := make([]byte, nameSize+pad4(110+nameSize))
+		if _, err := io.ReadFull(br, nameBuf); err != nil {
+			return fmt.Errorf("corrupt cpio: %w", err)
+		}
+		raw := string(bytes.TrimRight(nameBuf[:nameSize], "\x00"))
+		if raw == "TRAILER!!!" {
+			return nil
+		}
+		data := io.LimitReader(br, size)
+		const modeType, modeRegular = 0o170000, 0o100000
+		if name := sanitizeEntryName(raw); name != "" && mode&modeType == modeRegular {
+			scanEntryWithStats(archivePath+"::"+name, name, data, limits, decompressed, entries, depth, deadline, emit, stats)
+		}
+		if _, err := io.Copy(io.Discard, data); err != nil {
+			return fmt.Errorf("corrupt cpio: %w", err)
+		}
+		if _, err := br.Discard(int(pad4(size))); err != nil {
+			return fmt.Errorf("corrupt cpio: %w", err)
+		}
+	}
+}
+
+// pad4 returns the padding needed to bring n to a multiple of 4.
+func pad4(n int64) int64 {
+	return (4 - n%4) % 4
+}
@@ -0,0 +1,340 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+	"unicode/utf16"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+// bzip2Tar is a bzip2-compressed tarball holding conf/app.env; the standard
+// library has no bzip2 writer.
+const bzip2Tar = "QlpoOTFBWSZTWThJCyIAAHF7gMqQIABAAfcCBABvQd+ACAggAHUQp6mj0j1ADEB6mnqCSppoDQaAA0B944YhBM1CEWwfOWuomQIiB7CG75BNiEZEIPNwJldtgb+KAh7jBIu83War8jTCoyNgtZip0ERD8XckU4UJA4SQsiA="
+
+func tarBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o600, Size: int64(len(files[name]))}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func zipBytes(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for _, name := range sortedKeys(files) {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	return buf.Bytes()
+}
+
+func compressBytes(t *testing.T, compression string, data []byte) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	switch compression {
+	case "gzip":
+		w := gzip.NewWriter(&buf)
+		_, err := w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case "xz":
+		w, err := xz.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	case "zstd":
+		w, err := zstd.NewWriter(&buf)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+	default:
+		t.Fatalf("unsupported compression %q", compression)
+	}
+	return buf.Bytes()
+}
+
+// arBytes builds an ar archive, as used by .deb packages.
+func arBytes(members ...[2]string) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("!<arch>\n")
+	for _, m := range members {
+		fmt.Fprintf(&buf, "%-16s%-12d%-6d%-6d%-8o%-10d`\n", m[0]+"/", 0, 0, 0, 0o644, len(m[1]))
+		buf.WriteString(m[1])
+		if len(m[1])%2 == 1 {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// cpioEntry is a file, directory or symlink in a newc cpio archive.
+type cpioEntry struct {
+	name string
+	mode int64
+	data string
+}
+
+func cpioBytes(entries ...cpioEntry) []byte {
+	var buf bytes.Buffer
+	pad := func() {
+		for buf.Len()%4 != 0 {
+			buf.WriteByte(0)
+		}
+	}
+	for _, e := range append(entries, cpioEntry{name: "TRAILER!!!"}) {
+		fields := []int64{0, e.mode, 0, 0, 1, 0, int64(len(e.data)), 0, 0, 0, 0, int64(len(e.name) + 1), 0}
+		buf.WriteString("070701")
+		for _, f := range fields {
+			fmt.Fprintf(&buf, "%08x", f)
+		}
+		buf.WriteString(e.name)
+		buf.WriteByte(0)
+		pad()
+		buf.WriteString(e.data)
+		pad()
+	}
+	return buf.Bytes()
+}
+
+// rpmBytes builds an RPM package around a payload: a lead, a signature
+// header whose store needs padding, and an empty main header.
+func rpmBytes(payload []byte) []byte {
+	var buf bytes.Buffer
+	lead := make([]byte, 96)
+	copy(lead, []byte{0xed, 0xab, 0xee, 0xdb, 3, 0})
+	buf.Write(lead)
+	header := func(store int) {
+		buf.Write(rpmHeaderMagic)
+		buf.Write(make([]byte, 4))
+		_ = binary.Write(&buf, binary.BigEndian, uint32(0))
+		_ = binary.Write(&buf, binary.BigEndian, uint32(store))
+		buf.Write(bytes.Repeat([]byte{'x'}, store))
+	}
+	header(5)
+	buf.Write(make([]byte, 3))
+	header(3)
+	buf.Write(payload)
+	return buf.Bytes()
+}
+
+// sevenZipBytes builds a 7z archive holding one file stored with the copy
+// method.
+func sevenZipBytes(name, content string) []byte {
+	var name16 []byte
+	for _, u := range utf16.Encode([]rune(name)) {
+		name16 = binary.LittleEndian.AppendUint16(name16, u)
+	}
+	name16 = append(name16, 0, 0)
+	size := byte(len(content))
+	hdr := []byte{
+		0x01,                               // Header
+		0x04,                               // MainStreamsInfo
+		0x06, 0x00, 0x01, 0x09, size, 0x00, // PackInfo: pos 0, 1 stream, sizes, end
+		0x07, 0x0b, 0x01, 0x00, // UnpackInfo: Folder, 1 folder, not external
+		0x01, 0x01, 0x00, // 1 coder: simple, 1-byte id, Copy
+		0x0c, size, 0x00, // CodersUnpackSize, end
+		0x00,       // end MainStreamsInfo
+		0x05, 0x01, // FilesInfo: 1 file
+		0x11, byte(1 + len(name16)), 0x00, // Name, size, not external
+	}
+	hdr = append(hdr, name16...)
+	hdr = append(hdr, 0x00, 0x00) // end FilesInfo, end Header
+
+	start := make([]byte, 20)
+	binary.LittleEndian.PutUint64(start[0:], uint64(len(content)))
+	binary.LittleEndian.PutUint64(start[8:], uint64(len(hdr)))
+	binary.LittleEndian.PutUint32(start[16:], crc32.ChecksumIEEE(hdr))
+	var buf bytes.Buffer
+	buf.Write([]byte{'7', 'z', 0xbc, 0xaf, 0x27, 0x1c, 0, 4})
+	_ = binary.Write(&buf, binary.LittleEndian, crc32.ChecksumIEEE(start))
+	buf.Write(start)
+	buf.WriteString(content)
+	buf.Write(hdr)
+	return buf.Bytes()
+}
+
+func scanArchiveFiles(t *testing.T, files map[string][]byte) (map[string]string, Stats) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 3, TimeBudget: 5 * time.Second}
+	got := map[string]string{}
+	var stats Stats
+	err := ScanArchivesWithStats(dir, lim, nil, func(p string, b []byte) { got[p] = string(b) }, &stats)
+	require.NoError(t, err)
+	return got, stats
+}
+
+func TestArchiveFormatFor(t *testing.T) {
+	for name, want := range map[string]archiveFormat{
+		"a.tar.gz":   {ext: ".tar.gz", kind: "tar", compression: "gzip"},
+		"a.TAR.XZ":   {ext: ".tar.xz", kind: "tar", compression: "xz"},
+		"a.tar.zst":  {ext: ".tar.zst", kind: "tar", compression: "zstd"},
+		"a.tbz2":     {ext: ".tbz2", kind: "tar", compression: "bzip2"},
+		"a.gem":      {ext: ".gem", kind: "tar"},
+		"lib.jar":    {ext: ".jar", kind: "zip"},
+		"pkg.whl":    {ext: ".whl", kind: "zip"},
+		"pkg.nupkg":  {ext: ".nupkg", kind: "zip"},
+		"app.apk":    {ext: ".apk", kind: "apk"},
+		"pkg.deb":    {ext: ".deb", kind: "deb"},
+		"pkg.rpm":    {ext: ".rpm", kind: "rpm"},
+		"bundle.7z":  {ext: ".7z", kind: "7z"},
+		"notes.bz2":  {ext: ".bz2", kind: "file", compression: "bzip2"},
+		"notes.zst":  {ext: ".zst", kind: "file", compression: "zstd"},
+		"notes.x.gz": {ext: ".gz", kind: "file", compression: "gzip"},
+	} {
+		got, ok := archiveFormatFor(name)
+		assert.True(t, ok, name)
+		assert.Equal(t, want, got, name)
+	}
+	_, ok := archiveFormatFor("main.go")
+	assert.False(t, ok)
+}
+
+func TestScanArchives_CompressedTarballs(t *testing.T) {
+	tb := tarBytes(t, map[string]string{"conf/app.env": "password=hunter2\n"})
+	bz, err := base64.StdEncoding.DecodeString(bzip2Tar)
+	require.NoError(t, err)
+	got, stats := scanArchiveFiles(t, map[string][]byte{
+		"a.tar.xz":  compressBytes(t, "xz", tb),
+		"b.tar.zst": compressBytes(t, "zstd", tb),
+		"c.tar.bz2": bz,
+		"d.gem":     tb,
+		"e.env.zst": compressBytes(t, "zstd", []byte("token=abc\n")),
+	})
+	assert.Equal(t, map[string]string{
+		"a.tar.xz::conf/app.env":  "password=hunter2\n",
+		"b.tar.zst::conf/app.env": "password=hunter2\n",
+		"c.tar.bz2::conf/app.env": "password=hunter2\n",
+		"d.gem::conf/app.env":     "password=hunter2\n",
+		"e.env.zst::e.env":        "token=abc\n",
+	}, got)
+	assert.Empty(t, stats.errs)
+}
+
+func TestScanArchives_ZipBasedPackages(t *testing.T) {
+	// A jar nested in a war nested in an ear, plus a wheel and a nupkg.
+	jar := zipBytes(t, map[string]string{"application.properties": "db.password=s3cr3t\n"})
+	war := zipBytes(t, map[string]string{"WEB-INF/lib/core.jar": string(jar)})
+	got, _ := scanArchiveFiles(t, map[string][]byte{
+		"app.ear":   zipBytes(t, map[string]string{"web.war": string(war)}),
+		"pkg.whl":   zipBytes(t, map[string]string{"pkg/settings.py": "API_KEY = 'x'\n"}),
+		"lib.nupkg": zipBytes(t, map[string]string{"content/appsettings.json": "{}\n"}),
+	})
+	assert.Equal(t, "db.password=s3cr3t\n", got["app.ear::web.war::WEB-INF/lib/core.jar::application.properties"])
+	assert.Contains(t, got, "pkg.whl::pkg/settings.py")
+	assert.Contains(t, got, "lib.nupkg::content/appsettings.json")
+}
+
+func TestScanArchives_APK(t *testing.T) {
+	// Android packages are zips; Alpine packages are gzipped tarballs.
+	got, _ := scanArchiveFiles(t, map[string][]byte{
+		"android.apk": zipBytes(t, map[string]string{"res/raw/config.json": "{\"key\":\"v\"}\n"}),
+		"alpine.apk":  compressBytes(t, "gzip", tarBytes(t, map[string]string{"etc/app.conf": "secret=1\n"})),
+	})
+	assert.Contains(t, got, "android.apk::res/raw/config.json")
+	assert.Equal(t, "secret=1\n", got["alpine.apk::etc/app.conf"])
+}
+
+func TestScanArchives_Deb(t *testing.T) {
+	deb := arBytes(
+		[2]string{"debian-binary", "2.0\n"},
+		[2]string{"control.tar.gz", string(compressBytes(t, "gzip", tarBytes(t, map[string]string{"./control": "Package: app\n"})))},
+		[2]string{"data.tar.xz", string(compressBytes(t, "xz", tarBytes(t, map[string]string{"./etc/app/app.conf": "password=hunter2\n"})))},
+	)
+	got, stats := scanArchiveFiles(t, map[string][]byte{"app.deb": deb})
+	assert.Equal(t, "Package: app\n", got["app.deb::control.tar.gz::control"])
+	assert.Equal(t, "password=hunter2\n", got["app.deb::data.tar.xz::etc/app/app.conf"])
+	assert.Empty(t, stats.errs)
+}
+
+func TestScanArchives_RPM(t *testing.T) {
+	payload := cpioBytes(
+		cpioEntry{name: "./etc", mode: 0o040755},
+		cpioEntry{name: "./etc/app.conf", mode: 0o100644, data: "password=hunter2\n"},
+		cpioEntry{name: "./etc/link", mode: 0o120777, data: "app.conf"},
+		cpioEntry{name: "./usr/share/app/bundle.zip", mode: 0o100644, data: string(zipBytes(t, map[string]string{"k.env": "K=1\n"}))},
+	)
+	for _, compression := range []string{"gzip", "xz", "zstd"} {
+		got, stats := scanArchiveFiles(t, map[string][]byte{"app.rpm": rpmBytes(compressBytes(t, compression, payload))})
+		assert.Equal(t, map[string]string{
+			"app.rpm::etc/app.conf":                    "password=hunter2\n",
+			"app.rpm::usr/share/app/bundle.zip::k.env": "K=1\n",
+		}, got, compression)
+		assert.Empty(t, stats.errs, compression)
+	}
+}
+
+func TestScanCpio_RejectsOversizedName(t *testing.T) {
+	payload := cpioBytes(cpioEntry{name: "./etc/app.conf", mode: 0o100644, data: "password=hunter2\n"})
+	// The name size is the twelfth 8-digit field after the 6-byte magic.
+	copy(payload[6+8*11:], "ffffffff")
+	var decompressed int64
+	var entries int
+	err := scanCpioWithStats("app.rpm", Limits{}, &decompressed, &entries, 1, time.Time{}, func(string, []byte) {}, bytes.NewReader(payload), nil)
+	assert.EqualError(t, err, "corrupt cpio: bad header")
+}
+
+func TestScanArchives_SevenZip(t *testing.T) {
+	inner := compressBytes(t, "zstd", tarBytes(t, map[string]string{"k.env": "K=1\n"}))
+	got, stats := scanArchiveFiles(t, map[string][]byte{
+		"a.7z":       sevenZipBytes("conf/app.env", "password=hunter2\n"),
+		"nested.zip": zipBytes(t, map[string]string{"b.7z": string(sevenZipBytes("c.tar.zst", string(inner)))}),
+	})
+	assert.Equal(t, "password=hunter2\n", got["a.7z::conf/app.env"])
+	assert.Equal(t, "K=1\n", got["nested.zip::b.7z::c.tar.zst::k.env"])
+	assert.Empty(t, stats.errs)
+}
+
+func TestScanArchives_NewFormatsRespectEntryLimit(t *testing.T) {
+	files := map[string]string{}
+	for i := 0; i < 20; i++ {
+		files[fmt.Sprintf("f%02d.txt", i)] = "x"
+	}
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "many.tar.xz"), compressBytes(t, "xz", tarBytes(t, files)), 0o600))
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 5, MaxDepth: 2, TimeBudget: 5 * time.Second}
+	count := 0
+	var stats Stats
+	require.NoError(t, ScanArchivesWithStats(dir, lim, nil, func(string, []byte) { count++ }, &stats))
+	assert.Equal(t, 5, count)
+	assert.Equal(t, 1, stats.AbortedByEntries)
+}
+
+func TestScanArchives_UTF16EntriesAreText(t *testing.T) {
+	// A UTF-16LE PowerShell script, as written by Out-File on Windows.
+	ps1 := "\xff\xfe$\x00k\x00=\x00'\x00x\x00'\x00\n\x00"
+	got, _ := scanArchiveFiles(t, map[string][]byte{
+		"scripts.zip": zipBytes(t, map[string]string{"deploy.ps1": ps1, "tool.bin": "\x00\x01\x02\x03"}),
+	})
+	assert.Equal(t, ps1, got["scripts.zip::deploy.ps1"], "emitted as is, for the engine to transcode")
+	assert.NotContains(t, got, "scripts.zip::tool.bin")
+}
@@ -4,11 +4,10 @@ import (
 	"archive/tar"
 	"archive/zip"
 	"bytes"
-	"compress/gzip"
 	"encoding/json"
 	"errors"
+	"fmt"
 	"io"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
@@ -16,9 +15,8 @@ import (
 	"strings"
 	"time"
 
-	"sync"
-
-	"github.com/varalys/redactyl/internal/ignore"
+	"github.com/varalys/redactyl/internal/textenc"
+	"github.com/varalys/redactyl/internal/types"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -65,6 +63,23 @@ type Limits struct {
 	Workers         int
 	// GlobalDeadline stops scanning across all artifacts when exceeded.
 	GlobalDeadline time.Time
+	// MaxEntryRatio stops reading an archive entry once it has expanded to
+	// more than this many times its compressed size (zip entries and
+	// single-file .gz/.xz/.zst/.bz2 archives). MaxArchiveRatio stops
+	// scanning an archive once the bytes decompressed from it, nested
+	// archives included, exceed this many times its size. Both allow the
+	// first MiB regardless; 0 disables the check.
+	MaxEntryRatio   int
+	MaxArchiveRatio int
+	// SensitivePaths reports entries at paths where credentials are
+	// conventionally kept; nil disables the check.
+	SensitivePaths *SensitivePaths
+	// Binaries emits binary entries, such as executables, shared libraries
+	// and class files, for the caller to extract strings from with
+	// ExtractStrings instead of skipping them.
+	Binaries bool
+
+	archive *archiveFrame // archive being scanned, if any
 }
 
 // Stats collects counters for artifacts aborted due to guardrails.
@@ -73,12 +88,32 @@ type Stats struct {
 	AbortedByEntries int
 	AbortedByDepth   int
 	AbortedByTime    int
+	AbortedByRatio   int
+	// Artifacts has one report per artifact scanned, in scan order.
+	Artifacts []types.ArtifactReport
+	// Bombs lists the artifacts that a compression-ratio guardrail stopped,
+	// in scan order.
+	Bombs []Bomb
+	// Unscannable lists encrypted and corrupt content found inside
+	// artifacts, in scan order.
+	Unscannable []Unscannable
+	// Detected lists the entries inside artifacts that were identified as
+	// secrets without being passed to the scanner (Entry.Detector set):
+	// credential stores and files at sensitive paths, in scan order.
+	Detected []Entry
+
+	aborted string   // first abort reason of the artifact being scanned
+	errs    []string // problems found in the artifact being scanned
+	bomb    string   // first compression-ratio problem in the artifact being scanned
 }
 
 func (s *Stats) add(reason string) {
 	if s == nil || reason == "" {
 		return
 	}
+	if s.aborted == "" {
+		s.aborted = reason
+	}
 	switch reason {
 	case "bytes":
 		s.AbortedByBytes++
@@ -88,6 +123,137 @@ func (s *Stats) add(reason string) {
 		s.AbortedByDepth++
 	case "time":
 		s.AbortedByTime++
+	case "ratio":
+		s.AbortedByRatio++
+	}
+}
+
+// merge adds the counters from o into s.
+func (s *Stats) merge(o Stats) {
+	if s == nil {
+		return
+	}
+	s.AbortedByBytes += o.AbortedByBytes
+	s.AbortedByEntries += o.AbortedByEntries
+	s.AbortedByDepth += o.AbortedByDepth
+	s.AbortedByTime += o.AbortedByTime
+	s.AbortedByRatio += o.AbortedByRatio
+	s.Artifacts = append(s.Artifacts, o.Artifacts...)
+	s.Bombs = append(s.Bombs, o.Bombs...)
+	s.Unscannable = append(s.Unscannable, o.Unscannable...)
+	s.Detected = append(s.Detected, o.Detected...)
+}
+
+// detected records an entry the artifact scanner identified as a secret.
+func (s *Stats) detected(e Entry) {
+	if s == nil {
+		return
+	}
+	s.Detected = append(s.Detected, e)
+}
+
+// fail records a problem that left part of the artifact being scanned
+// unscanned, such as an entry that could not be read.
+func (s *Stats) fail(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.errs = append(s.errs, err.Error())
+}
+
+// ratioExceeded records a compression-ratio abort and the problem behind it.
+func (s *Stats) ratioExceeded(err error) {
+	if s == nil {
+		return
+	}
+	s.add("ratio")
+	s.fail(err)
+	if s.bomb == "" {
+		s.bomb = err.Error()
+	}
+}
+
+// artifactScan scans one artifact, recording aborts and problems in stats,
+// and returns the number of entries it emitted and the bytes it read or
+// decompressed. A candidate that turns out not to be an artifact of the
+// scanner's type returns zeros and no error.
+type artifactScan func(stats *Stats) (entries int, bytes int64, err error)
+
+// scanArtifact runs scan for the artifact at path with Stats of its own and
+// merges them into stats together with a report for the artifact. Once
+// limits.GlobalDeadline has passed the artifact is not scanned, only
+// reported as aborted by time. Candidates that scan returned zeros for are
+// not reported. The error from scan is returned.
+func scanArtifact(stats *Stats, limits Limits, path, typ string, scan artifactScan) error {
+	_, err := runArtifactScan(stats, limits, path, typ, scan)
+	return err
+}
+
+// scanCandidate is scanArtifact for candidates matched by name alone, such
+// as any YAML file, which are mostly not artifacts at all. scan runs even
+// once limits.GlobalDeadline has passed, and checks it with
+// pastGlobalDeadline only after confirming the candidate is an artifact, so
+// that only artifacts are reported as aborted by time.
+func scanCandidate(stats *Stats, limits Limits, path, typ string, scan artifactScan) error {
+	_, err := reportArtifactScan(stats, limits, path, typ, scan)
+	return err
+}
+
+// pastGlobalDeadline reports whether limits.GlobalDeadline has passed,
+// recording the artifact in stats as aborted by time if so.
+func pastGlobalDeadline(stats *Stats, limits Limits) bool {
+	if limits.GlobalDeadline.IsZero() || !time.Now().After(limits.GlobalDeadline) {
+		return false
+	}
+	stats.add("time")
+	return true
+}
+
+// runArtifactScan is scanArtifact, also reporting whether the artifact was
+// scanned in full: scan ran, which it does not once limits.GlobalDeadline
+// has passed, and no guardrail stopped it and no problem was recorded.
+func runArtifactScan(stats *Stats, limits Limits, path, typ string, scan artifactScan) (complete bool, err error) {
+	return reportArtifactScan(stats, limits, path, typ, func(stats *Stats) (int, int64, error) {
+		if pastGlobalDeadline(stats, limits) {
+			return 0, 0, nil
+		}
+		return scan(stats)
+	})
+}
+
+// reportArtifactScan runs scan and reports the artifact as runArtifactScan
+// does, without checking limits.GlobalDeadline first.
+func reportArtifactScan(stats *Stats, limits Limits, path, typ string, scan artifactScan) (complete bool, err error) {
+	var local Stats
+	r := types.ArtifactReport{Path: filepath.ToSlash(path), Type: typ}
+	r.Entries, r.Bytes, err = scan(&local)
+	r.Aborted = local.aborted
+	r.Errors = local.errs
+	if err != nil {
+		r.Errors = append(r.Errors, err.Error())
+	}
+	complete = r.Aborted == "" && len(r.Errors) == 0
+	if !complete || r.Entries > 0 || r.Bytes > 0 {
+		local.Artifacts = append(local.Artifacts, r)
+	}
+	if local.AbortedByRatio > 0 {
+		reason := local.bomb
+		if reason == "" {
+			reason = fmt.Sprintf("archive decompressed to more than %dx its size", limits.MaxArchiveRatio)
+		}
+		local.Bombs = append(local.Bombs, Bomb{Path: r.Path, Type: typ, Reason: reason})
+	}
+	stats.merge(local)
+	return complete, err
+}
+
+// countEntries wraps emit to count the entries passed through it and the
+// bytes they carry.
+func countEntries(emit EntryFunc, n *int, size *int64) EntryFunc {
+	return func(e Entry) {
+		*n++
+		*size += int64(len(e.Data))
+		emit(e)
 	}
 }
 
@@ -96,6 +262,42 @@ func (s *Stats) add(reason string) {
 // all artifact filenames are allowed.
 type PathAllowFunc func(rel string) bool
 
+// Entry is a unit of text content emitted from inside an artifact together
+// with metadata describing where it came from (chart, image digest, ...).
+type Entry struct {
+	Path string
+	Data []byte
+	// Metadata is copied into the findings in Data. The keys each artifact
+	// scanner sets are listed in docs/deep-scanning.md.
+	Metadata map[string]string
+	// Line is the 1-based line of the enclosing file at which Data starts
+	// when Data is an excerpt (e.g. one decoded Secret value); 0 if unknown.
+	Line int
+	// Detector is set when the artifact scanner has itself identified Data
+	// as a secret (one of the IDs in Detectors); the entry is then reported
+	// as a finding with Confidence instead of being passed to the scanner.
+	Detector   string
+	Confidence float64
+}
+
+// EntryFunc receives entries from metadata-aware artifact scanners.
+type EntryFunc func(Entry)
+
+// Detectors describes the detector IDs that artifact scanners report
+// through Entry.Detector.
+var Detectors = map[string]string{
+	DetectorEncryptedFilePlaintext: "Plaintext value in a SOPS-encrypted file or SealedSecret",
+	DetectorArchiveBomb:            "Archive that expands far beyond its compressed size, has overlapping entries or contains itself",
+	DetectorUnscannableContent:     "Encrypted or corrupt content inside an artifact that could not be scanned",
+	DetectorCredentialStore:        "Keystore, password database or private key file, with its key type and whether it opens with an empty or default password",
+	DetectorSensitivePath:          "File at a path where credentials are conventionally kept, such as .ssh/id_rsa or .aws/credentials",
+}
+
+// DetectorIDs returns the keys of Detectors, sorted.
+func DetectorIDs() []string {
+	return sortedKeys(Detectors)
+}
+
 // ScanArchives walks recognized archive files under root and emits text entries.
 // It enforces per-artifact limits and does not extract to disk.
 func ScanArchives(root string, limits Limits, emit func(path string, data []byte)) error {
@@ -105,47 +307,77 @@ func ScanArchives(root string, limits Limits, emit func(path string, data []byte
 // ScanArchivesWithFilter is like ScanArchives but also consults an optional
 // allow predicate to filter which artifact filenames are processed.
 func ScanArchivesWithFilter(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte)) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
-	// Walk the filesystem and locate candidate archives by extension
-	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
-		}
-		if allow != nil && !allow(rel) {
-			return nil
-		}
-		if !isArchivePath(rel) {
-			return nil
-		}
-		// Avoid double-processing container images: skip .tar that looks like a Docker save
-		if strings.HasSuffix(strings.ToLower(rel), ".tar") {
-			ok, ierr := isContainerTar(p)
-			if ierr != nil {
-				return nil
-			}
-			if ok {
-				return nil
-			}
-		}
+	return ScanArchivesWithStats(root, limits, allow, emit, nil)
+}
+
+// ScanArchivesWithStats is like ScanArchivesWithFilter but also increments
+// the provided stats counters when a guardrail abort reason is encountered.
+func ScanArchivesWithStats(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte), stats *Stats) error {
+	return Discover(root, allow, NewArchiveHandler(limits, emit, stats))
+}
+
+// NewArchiveHandler returns the Discover handler for zip, tar, tar.gz/tgz and
+// gzip files. Tarballs that are container images are left to the container
+// handler. Archives are collected during the walk and scanned once it is
+// done by up to limits.Workers goroutines; entries are still emitted in walk
+// order, one archive at a time.
+func NewArchiveHandler(limits Limits, emit func(path string, data []byte), stats *Stats) Handler {
+	return &archiveHandler{limits: limits, emit: emit, stats: stats}
+}
+
+type archiveHandler struct {
+	limits Limits
+	emit   func(path string, data []byte)
+	stats  *Stats
+	items  []*Candidate
+}
+
+func (h *archiveHandler) Match(c *Candidate) bool {
+	if c.IsDir() || !isArchivePath(c.Rel) {
+		return false
+	}
+	// Avoid double-processing container images: skip .tar that looks like a Docker save
+	if strings.HasSuffix(strings.ToLower(c.Rel), ".tar") {
+		ok, err := c.isContainerTar()
+		return err == nil && !ok
+	}
+	return true
+}
+
+func (h *archiveHandler) Handle(c *Candidate) error {
+	h.items = append(h.items, c)
+	return nil
+}
+
+func (h *archiveHandler) Finish(string) error {
+	return scanArtifactFiles(h.items, h.limits, "archive", h.emit, h.stats, func(c *Candidate, emit func(string, []byte), stats *Stats) (int, int64, error) {
 		// per-artifact counters and deadline
 		started := time.Now()
 		deadline := time.Time{}
-		if limits.TimeBudget > 0 {
-			deadline = started.Add(limits.TimeBudget)
+		if h.limits.TimeBudget > 0 {
+			deadline = started.Add(h.limits.TimeBudget)
 		}
 		var decompressed int64
 		var entries int
-		_ = scanArchiveFile(p, rel, limits, &decompressed, &entries, 0, deadline, emit) //nolint:errcheck
-		return nil
+		err := scanArchiveFileWithStats(c.Path, c.Rel, h.limits, &decompressed, &entries, 0, deadline, emit, stats)
+		return entries, decompressed, err
 	})
-	return nil
+}
+
+// scanArtifactFiles scans each candidate with scan on the artifact worker
+// pool (see runArtifactJobs), emitting in candidate order and reporting each
+// one as an artifact of type typ.
+func scanArtifactFiles(items []*Candidate, limits Limits, typ string, emit func(path string, data []byte), stats *Stats, scan func(c *Candidate, emit func(path string, data []byte), stats *Stats) (int, int64, error)) error {
+	jobs := make([]artifactJob[Entry], len(items))
+	for i, c := range items {
+		jobs[i] = func(emit func(Entry), stats *Stats) error {
+			scanOne := func(stats *Stats) (int, int64, error) {
+				return scan(c, func(p string, b []byte) { emit(Entry{Path: p, Data: b}) }, stats)
+			}
+			return scanArtifact(stats, limits, c.Rel, typ, scanOne)
+		}
+	}
+	return runArtifactJobs(jobs, limits.Workers, func(e Entry) { emit(e.Path, e.Data) }, stats)
 }
 
 // ScanContainers walks recognized container image/layer tarballs and emits text entries.
@@ -157,146 +389,97 @@ func ScanContainers(root string, limits Limits, emit func(path string, data []by
 // ScanContainersWithFilter is like ScanContainers but also consults an optional
 // allow predicate to filter which artifact filenames are processed.
 func ScanContainersWithFilter(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte)) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
-	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(rel), ".tar") {
-			return nil
-		}
-		isContainer, ierr := isContainerTar(p)
-		if ierr != nil || !isContainer {
-			return nil
-		}
-		if allow != nil && !allow(rel) {
-			return nil
-		}
-		// per-artifact counters and deadline
-		started := time.Now()
-		deadline := time.Time{}
-		if limits.TimeBudget > 0 {
-			deadline = started.Add(limits.TimeBudget)
-		}
-		var decompressed int64
-		var entries int
-		// stream through outer tar and process each layer tar entry
-		f, oerr := os.Open(p)
-		if oerr != nil {
-			return nil
-		}
-		defer safeClose(f)
-		tr := tar.NewReader(f)
-		for {
-			if r := limitsExceededReason(limits, decompressed, entries, 0, deadline); r != "" {
-				return nil
-			}
-			hdr, nerr := tr.Next()
-			if errors.Is(nerr, io.EOF) || hdr == nil {
-				return nil
-			}
-			if nerr != nil {
-				return nil
-			}
-			name := sanitizeEntryName(hdr.Name)
-			if name == "" || hdr.FileInfo().IsDir() {
-				continue
-			}
-			// layer tar entries have a path like "<layerID>/layer.tar"
-			if strings.HasSuffix(name, "/layer.tar") {
-				layerID := filepath.Dir(name)
-				if i := strings.LastIndex(layerID, "/"); i >= 0 {
-					layerID = layerID[i+1:]
-				}
-				// Limit reader to this entry size and hand off to tar reader using '/' join for layer path
-				lr := &io.LimitedReader{R: tr, N: hdr.Size}
-				vp := rel + "::" + layerID
-				_ = scanTarReaderJoin(vp, "/", limits, &decompressed, &entries, 1, deadline, emit, lr) //nolint:errcheck
-			}
-		}
-	})
-	return nil
+	return ScanContainersWithStats(root, limits, allow, emit, nil)
 }
 
 // ScanContainersWithStats is like ScanContainersWithFilter but also increments
 // the provided stats counters when a guardrail abort reason is encountered.
 func ScanContainersWithStats(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte), stats *Stats) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
-	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
-		}
-		if !strings.HasSuffix(strings.ToLower(rel), ".tar") {
-			return nil
-		}
-		isContainer, err := isContainerTar(p)
-		if err != nil || !isContainer {
-			return nil
-		}
-		if allow != nil && !allow(rel) {
-			return nil
+	return Discover(root, allow, NewContainerHandler(limits, emit, stats))
+}
+
+// NewContainerHandler returns the Discover handler for `docker save`
+// tarballs. Like archives, images are scanned after the walk by up to
+// limits.Workers goroutines.
+func NewContainerHandler(limits Limits, emit func(path string, data []byte), stats *Stats) Handler {
+	return &containerHandler{limits: limits, emit: emit, stats: stats}
+}
+
+type containerHandler struct {
+	limits Limits
+	emit   func(path string, data []byte)
+	stats  *Stats
+	items  []*Candidate
+}
+
+func (h *containerHandler) Match(c *Candidate) bool {
+	if c.IsDir() || !strings.HasSuffix(strings.ToLower(c.Rel), ".tar") {
+		return false
+	}
+	ok, err := c.isContainerTar()
+	return err == nil && ok
+}
+
+func (h *containerHandler) Handle(c *Candidate) error {
+	h.items = append(h.items, c)
+	return nil
+}
+
+func (h *containerHandler) Finish(string) error {
+	return scanArtifactFiles(h.items, h.limits, "container", h.emit, h.stats, func(c *Candidate, emit func(string, []byte), stats *Stats) (int, int64, error) {
+		return scanContainerFile(c.Path, c.Rel, h.limits, emit, stats)
+	})
+}
+
+// scanContainerFile streams through the outer tar of a container image and
+// scans each layer tar entry, returning the entries emitted and the bytes
+// decompressed.
+func scanContainerFile(fullPath, rel string, limits Limits, emit func(path string, data []byte), stats *Stats) (int, int64, error) {
+	// per-artifact counters and deadline
+	started := time.Now()
+	deadline := time.Time{}
+	if limits.TimeBudget > 0 {
+		deadline = started.Add(limits.TimeBudget)
+	}
+	var decompressed int64
+	var entries int
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer safeClose(f)
+	tr := tar.NewReader(f)
+	for {
+		if r := limitsExceededReason(limits, decompressed, entries, 0, deadline); r != "" {
+			stats.add(r)
+			return entries, decompressed, nil
 		}
-		// per-artifact counters and deadline
-		started := time.Now()
-		deadline := time.Time{}
-		if limits.TimeBudget > 0 {
-			deadline = started.Add(limits.TimeBudget)
+		hdr, err := tr.Next()
+		if errors.Is(err, io.EOF) {
+			return entries, decompressed, nil
 		}
-		var decompressed int64
-		var entries int
-		// stream through outer tar and process each layer tar entry
-		f, err := os.Open(p)
 		if err != nil {
-			return nil
+			return entries, decompressed, fmt.Errorf("corrupt tar: %w", err)
 		}
-		defer safeClose(f)
-		tr := tar.NewReader(f)
-		for {
-			if r := limitsExceededReason(limits, decompressed, entries, 0, deadline); r != "" {
-				if stats != nil {
-					stats.add(r)
-				}
-				return nil
-			}
-			hdr, err := tr.Next()
-			if errors.Is(err, io.EOF) || hdr == nil {
-				return nil
-			}
-			if err != nil {
-				return nil
-			}
-			name := sanitizeEntryName(hdr.Name)
-			if name == "" || hdr.FileInfo().IsDir() {
-				continue
+		name := sanitizeEntryName(hdr.Name)
+		if name == "" || hdr.FileInfo().IsDir() {
+			continue
+		}
+		// layer tar entries have a path like "<layerID>/layer.tar"
+		if strings.HasSuffix(name, "/layer.tar") {
+			layerID := filepath.Dir(name)
+			if i := strings.LastIndex(layerID, "/"); i >= 0 {
+				layerID = layerID[i+1:]
 			}
-			// layer tar entries have a path like "<layerID>/layer.tar"
-			if strings.HasSuffix(name, "/layer.tar") {
-				layerID := filepath.Dir(name)
-				if i := strings.LastIndex(layerID, "/"); i >= 0 {
-					layerID = layerID[i+1:]
-				}
-				// Limit reader to this entry size and hand off to tar reader using '/' join for layer path
-				lr := &io.LimitedReader{R: tr, N: hdr.Size}
-				vp := rel + "::" + layerID
-				_ = scanTarReaderJoin(vp, "/", limits, &decompressed, &entries, 1, deadline, emit, lr) //nolint:errcheck
+			// Limit reader to this entry size and hand off to tar reader using '/' join for layer path
+			lr := &io.LimitedReader{R: tr, N: hdr.Size}
+			vp := rel + "::" + layerID
+			if err := scanTarReaderWithStats(vp, "/", limits, &decompressed, &entries, 1, deadline, emit, lr, stats); err != nil {
+				stats.fail(fmt.Errorf("layer %s: %w", layerID, err))
+				stats.unscannable(vp, err.Error())
 			}
 		}
-	})
-	return nil
+	}
 }
 
 // ScanIaC scans IaC hotspots like Terraform state files and kubeconfigs.
@@ -309,89 +492,137 @@ func ScanIaC(root string, limits Limits, emit func(path string, data []byte)) er
 // individually to reduce noise. For larger files, it falls back to text emission
 // bounded by limits. Kubeconfigs are emitted as text for structured detectors to parse.
 func ScanIaCWithFilter(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte)) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
-	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
+	return ScanIaCWithMetadata(root, limits, allow, func(e Entry) { emit(e.Path, e.Data) })
+}
+
+// ScanIaCWithMetadata is like ScanIaCWithFilter but also scans the files
+// handled by iacFormats (Terraform configuration and plans, CloudFormation,
+// Pulumi, Ansible, Docker Compose and Dockerfiles), and emits entries
+// carrying metadata about where each value sits, such as the Terraform
+// address and attribute.
+func ScanIaCWithMetadata(root string, limits Limits, allow PathAllowFunc, emit EntryFunc) error {
+	return Discover(root, allow, NewIaCHandler(limits, emit, nil))
+}
+
+// NewIaCHandler returns the Discover handler for Terraform state,
+// kubeconfigs and the files matched by iacFormats. Each file is reported in
+// stats, which may be nil.
+func NewIaCHandler(limits Limits, emit EntryFunc, stats *Stats) Handler {
+	return &iacHandler{limits: limits, emit: emit, stats: stats}
+}
+
+type iacHandler struct {
+	limits Limits
+	emit   EntryFunc
+	stats  *Stats
+}
+
+func (h *iacHandler) Match(c *Candidate) bool {
+	if c.IsDir() {
+		return false
+	}
+	lower := strings.ToLower(c.Rel)
+	return strings.HasSuffix(lower, ".tfstate") || strings.HasSuffix(lower, ".kubeconfig") ||
+		isKubeConfigPath(c.Rel) || len(iacFormatsFor(c.Rel)) > 0
+}
+
+func (h *iacHandler) Handle(c *Candidate) error {
+	return scanCandidate(h.stats, h.limits, c.Rel, "iac", func(stats *Stats) (int, int64, error) {
+		return scanIaCFile(c.Path, c.Rel, h.limits, h.emit, stats)
+	})
+}
+
+// scanIaCFile scans one file matched by the IaC handler, returning the
+// entries emitted and the bytes read.
+func scanIaCFile(p, rel string, limits Limits, emit EntryFunc, stats *Stats) (int, int64, error) {
+	lower := strings.ToLower(rel)
+	isTF := strings.HasSuffix(lower, ".tfstate")
+	isKC := strings.HasSuffix(lower, ".kubeconfig") || isKubeConfigPath(rel)
+	formats := iacFormatsFor(rel)
+	// Files with an iacFormat are confirmed by what they emit, which emitTF
+	// stops once limits.GlobalDeadline has passed.
+	if (isTF || isKC || len(formats) == 0) && pastGlobalDeadline(stats, limits) {
+		return 0, 0, nil
+	}
+	// establish time budget
+	started := time.Now()
+	deadline := time.Time{}
+	if limits.TimeBudget > 0 {
+		deadline = started.Add(limits.TimeBudget)
+	}
+	var decompressed int64
+	count := 0
+	var size int64
+	emit = countEntries(emit, &count, &size)
+	aborted := false
+	emitTF := func(e Entry) {
+		if r := limitsExceededReason(limits, decompressed, count, 0, deadline); r != "" {
+			if !aborted {
+				stats.add(r)
+				aborted = true
+			}
+			return
 		}
-		if allow != nil && !allow(rel) {
-			return nil
+		emit(e)
+	}
+	f, err := os.Open(p)
+	if err != nil {
+		return 0, 0, err
+	}
+	defer safeClose(f)
+	b, readErr := readAllBounded(f, limits, &decompressed, deadline)
+	if readErr != nil {
+		if r := limitsExceededReason(limits, decompressed, 0, 0, deadline); r != "" {
+			stats.add(r)
+		} else {
+			return 0, decompressed, readErr
 		}
-		lower := strings.ToLower(rel)
-		isTF := strings.HasSuffix(lower, ".tfstate")
-		isKC := strings.HasSuffix(lower, ".kubeconfig") || isKubeConfigPath(rel)
-		if !isTF && !isKC {
-			return nil
+	}
+	// Files with an iacFormat (Terraform configuration, CloudFormation,
+	// Compose, ...)
+	if len(formats) > 0 && !isTF && !isKC {
+		if readErr != nil {
+			return count, decompressed, nil
 		}
-		// establish time budget
-		started := time.Now()
-		deadline := time.Time{}
-		if limits.TimeBudget > 0 {
-			deadline = started.Add(limits.TimeBudget)
+		for _, fm := range formats {
+			fm.scan(rel, b, emitTF)
 		}
-		var decompressed int64
-		// Terraform state selective scan
-		if isTF {
-			f, err := os.Open(p)
-			if err != nil {
-				return nil
-			}
-			defer safeClose(f)
-			b, readErr := readAllBounded(f, limits, &decompressed, deadline)
-			if readErr == nil {
-				// Skip selective JSON parsing for very large tfstate to avoid overhead
-				const tfstateSelectiveMaxBytes = 2 << 20 // 2 MiB
-				if len(b) <= tfstateSelectiveMaxBytes {
-					var data any
-					if json.Unmarshal(b, &data) == nil {
-						count := 0
-						emitKV := func(pathStr string, value string) {
-							// stop if limits exceeded by entries
-							if limitsExceeded(limits, decompressed, count, 0, deadline) {
-								return
-							}
-							buf := []byte(pathStr + ": " + value)
-							emit(rel+"::json:"+pathStr, buf)
-							count++
-						}
-						extractSensitiveJSON("", data, emitKV)
-						return nil
+		return count, decompressed, nil
+	}
+	// Terraform state selective scan
+	if isTF {
+		if readErr == nil {
+			// Skip selective JSON parsing for very large tfstate to avoid overhead
+			const tfstateSelectiveMaxBytes = 2 << 20 // 2 MiB
+			if len(b) <= tfstateSelectiveMaxBytes {
+				if scanTerraformState(rel, b, emitTF) {
+					return count, decompressed, nil
+				}
+				var data any
+				if json.Unmarshal(b, &data) == nil {
+					emitKV := func(pathStr string, value string) {
+						emitTF(Entry{Path: rel + "::json:" + pathStr, Data: []byte(pathStr + ": " + value)})
 					}
+					extractSensitiveJSON("", data, emitKV)
+					return count, decompressed, nil
 				}
 			}
-			// fallback: emit bounded text content
-			if len(b) > 0 {
-				emit(rel, b)
-			}
-			return nil
 		}
-		// Kubeconfigs: try selective YAML extraction, else emit entire file
-		if isKC {
-			f, err := os.Open(p)
-			if err != nil {
-				return nil
-			}
-			defer safeClose(f)
-			b, rerr := readAllBounded(f, limits, &decompressed, deadline)
-			if rerr != nil {
-				return nil
-			}
-			if len(b) > 0 {
-				if emitted := tryExtractKubeconfig(rel, b, emit); !emitted {
-					emit(rel, b)
-				}
-			}
+		// fallback: emit bounded text content
+		if len(b) > 0 {
+			emit(Entry{Path: rel, Data: b})
 		}
-		return nil
-	})
-	return nil
+		return count, decompressed, nil
+	}
+	// Kubeconfigs: try selective YAML extraction, else emit entire file
+	if readErr != nil || len(b) == 0 {
+		return count, decompressed, nil
+	}
+	emitKC := func(path string, data []byte) { emit(Entry{Path: path, Data: data}) }
+	if emitted := tryExtractKubeconfig(rel, b, emitKC); !emitted {
+		emitKC(rel, b)
+	}
+	return count, decompressed, nil
 }
 
 // isKubeConfigPath returns true if the path looks like a default kube config location
@@ -449,11 +680,8 @@ func keyLooksSensitive(k string) bool {
 }
 
 func isArchivePath(path string) bool {
-	lower := strings.ToLower(path)
-	if strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar") || strings.HasSuffix(lower, ".tgz") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".gz") {
-		return true
-	}
-	return false
+	_, ok := archiveFormatFor(path)
+	return ok
 }
 
 func isContainerTar(fullPath string) (bool, error) {
@@ -478,215 +706,6 @@ func isContainerTar(fullPath string) (bool, error) {
 	}
 }
 
-func scanArchiveFile(fullPath string, rel string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte)) error {
-	// respect depth only for nested archives; top-level depth is 0
-	f, err := os.Open(fullPath)
-	if err != nil {
-		return nil
-	}
-	defer safeClose(f)
-
-	lower := strings.ToLower(rel)
-	switch {
-	case strings.HasSuffix(lower, ".zip"):
-		return scanZipReader(rel, limits, decompressed, entries, depth, deadline, emit, f)
-	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
-		gz, err := gzip.NewReader(f)
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		return scanTarReader(rel, limits, decompressed, entries, depth, deadline, emit, gz)
-	case strings.HasSuffix(lower, ".tar"):
-		return scanTarReader(rel, limits, decompressed, entries, depth, deadline, emit, f)
-	case strings.HasSuffix(lower, ".gz"):
-		// single-file gzip: emit decompressed content as a single entry
-		gz, err := gzip.NewReader(f)
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		name := sanitizeEntryName(gz.Name)
-		if name == "" {
-			name = strings.TrimSuffix(rel, ".gz")
-		}
-		b, readErr := readAllBounded(gz, limits, decompressed, deadline)
-		if readErr != nil {
-			return nil
-		}
-		if looksBinary(b) || looksNonTextMIME(name, b) {
-			return nil
-		}
-		vp := rel + "::" + name
-		emit(vp, b)
-		*entries++
-		return nil
-	default:
-		return nil
-	}
-}
-
-func scanZipReader(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.ReaderAt) error {
-	// Determine size for zip.NewReader: we need an io.ReaderAt and size.
-	// Unfortunately we cannot get size from a generic io.ReaderAt; reopen the file by path if possible.
-	// Here archivePath is a virtual path (relative); attempt to use current working dir join is unreliable.
-	// As a compromise for local filesystem, require r to be *os.File for top-level; nested uses bytes.Reader with known size.
-	switch v := r.(type) {
-	case *os.File:
-		fi, err := v.Stat()
-		if err != nil {
-			return nil
-		}
-		zr, err := zip.NewReader(v, fi.Size())
-		if err != nil {
-			return nil
-		}
-		for _, f := range zr.File {
-			if limitsExceeded(limits, *decompressed, *entries, depth, deadline) {
-				return nil
-			}
-			if f.FileInfo().IsDir() {
-				continue
-			}
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			b, readErr := readAllBounded(rc, limits, decompressed, deadline)
-			safeClose(rc)
-			if readErr != nil {
-				continue
-			}
-			name := sanitizeEntryName(f.Name)
-			if name == "" {
-				continue // Skip invalid/traversal entries
-			}
-			if looksBinary(b) || looksNonTextMIME(name, b) {
-				if depth < limits.MaxDepth && isArchivePath(name) {
-					_ = scanNestedArchive(archivePath+"::"+name, name, b, limits, decompressed, entries, depth+1, deadline, emit) //nolint:errcheck
-				}
-				continue
-			}
-			vp := archivePath + "::" + name
-			emit(vp, b)
-			*entries++
-		}
-	default:
-		// Cannot determine size; skip
-		return nil
-	}
-	return nil
-}
-
-func scanTarReader(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader) error {
-	return scanTarReaderJoin(archivePath, "::", limits, decompressed, entries, depth, deadline, emit, r)
-}
-
-func scanTarReaderJoin(archivePath string, sep string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader) error {
-	tr := tar.NewReader(r)
-	for {
-		if limitsExceeded(limits, *decompressed, *entries, depth, deadline) {
-			return nil
-		}
-		hdr, err := tr.Next()
-		if errors.Is(err, io.EOF) || hdr == nil {
-			return nil
-		}
-		if err != nil {
-			return nil
-		}
-		if hdr.FileInfo().IsDir() {
-			continue
-		}
-		b, readErr := readAllBounded(tr, limits, decompressed, deadline)
-		if readErr != nil {
-			continue
-		}
-		name := sanitizeEntryName(hdr.Name)
-		if name == "" {
-			continue // Skip invalid/traversal entries
-		}
-		if looksBinary(b) || looksNonTextMIME(name, b) {
-			if depth < limits.MaxDepth && isArchivePath(name) {
-				_ = scanNestedArchive(archivePath+sep+name, name, b, limits, decompressed, entries, depth+1, deadline, emit) //nolint:errcheck
-			}
-			continue
-		}
-		vp := archivePath + sep + name
-		emit(vp, b)
-		*entries++
-	}
-}
-
-func scanNestedArchive(pathChain string, name string, blob []byte, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte)) error {
-	lower := strings.ToLower(name)
-	switch {
-	case strings.HasSuffix(lower, ".zip"):
-		zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
-		if err != nil {
-			return nil
-		}
-		for _, f := range zr.File {
-			if limitsExceeded(limits, *decompressed, *entries, depth, deadline) {
-				return nil
-			}
-			if f.FileInfo().IsDir() {
-				continue
-			}
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			b, readErr := readAllBounded(rc, limits, decompressed, deadline)
-			safeClose(rc)
-			if readErr != nil {
-				continue
-			}
-			fname := sanitizeEntryName(f.Name)
-			if fname == "" {
-				continue // Skip invalid/traversal entries
-			}
-			if looksBinary(b) || looksNonTextMIME(fname, b) {
-				if depth < limits.MaxDepth && isArchivePath(fname) {
-					_ = scanNestedArchive(pathChain+"::"+fname, fname, b, limits, decompressed, entries, depth+1, deadline, emit) //nolint:errcheck
-				}
-				continue
-			}
-			emit(pathChain+"::"+fname, b)
-			*entries++
-		}
-	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
-		gz, err := gzip.NewReader(bytes.NewReader(blob))
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		return scanTarReader(pathChain, limits, decompressed, entries, depth, deadline, emit, gz)
-	case strings.HasSuffix(lower, ".tar"):
-		return scanTarReader(pathChain, limits, decompressed, entries, depth, deadline, emit, bytes.NewReader(blob))
-	case strings.HasSuffix(lower, ".gz"):
-		gz, err := gzip.NewReader(bytes.NewReader(blob))
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		name := sanitizeEntryName(gz.Name)
-		if name == "" {
-			name = strings.TrimSuffix(filepath.Base(pathChain), ".gz")
-		}
-		b, readErr := readAllBounded(gz, limits, decompressed, deadline)
-		if readErr != nil {
-			return nil
-		}
-		if looksBinary(b) || looksNonTextMIME(name, b) {
-			return nil
-		}
-		emit(pathChain+"::"+name, b)
-		*entries++
-	}
-	return nil
-}
-
 func readAllBounded(r io.Reader, limits Limits, decompressed *int64, deadline time.Time) ([]byte, error) {
 	// enforce time budget prior to read
 	if !deadline.IsZero() && time.Now().After(deadline) {
@@ -700,6 +719,16 @@ func readAllBounded(r io.Reader, limits Limits, decompressed *int64, deadline ti
 			return nil, errors.New("byte budget exceeded")
 		}
 	}
+	// and by what MaxArchiveRatio leaves of it
+	ratioBound := false
+	if b := limits.ratioBudget(); b > 0 {
+		if b-*decompressed <= 0 {
+			return nil, errCompressionRatio
+		}
+		if b-*decompressed < remain {
+			remain, ratioBound = b-*decompressed, true
+		}
+	}
 	// copy in chunks up to remain, checking deadline between chunks
 	var buf bytes.Buffer
 	chunk := int64(32 * 1024)
@@ -718,10 +747,20 @@ func readAllBounded(r io.Reader, limits Limits, decompressed *int64, deadline ti
 			if errors.Is(err, io.EOF) {
 				break
 			}
-			if errors.Is(err, io.ErrUnexpectedEOF) {
-				break
+			if errors.Is(err, errCompressionRatio) {
+				return nil, err
 			}
-			return nil, err
+			// A truncated or corrupt stream: what was read is still
+			// returned for scanning.
+			return buf.Bytes(), err
+		}
+	}
+	if ratioBound && remain == 0 {
+		// Unlike a truncated entry at the byte limit, an entry cut short
+		// by the ratio budget is likely a bomb and is not passed on.
+		var probe [1]byte
+		if _, err := io.ReadFull(r, probe[:]); err == nil {
+			return nil, errCompressionRatio
 		}
 	}
 	return buf.Bytes(), nil
@@ -734,6 +773,9 @@ func limitsExceeded(l Limits, decompressed int64, entries int, depth int, deadli
 	if l.MaxArchiveBytes > 0 && decompressed >= l.MaxArchiveBytes {
 		return true
 	}
+	if b := l.ratioBudget(); b > 0 && decompressed >= b {
+		return true
+	}
 	if l.MaxDepth > 0 && depth > l.MaxDepth {
 		return true
 	}
@@ -754,6 +796,9 @@ func limitsExceededReason(l Limits, decompressed int64, entries int, depth int,
 	if l.MaxArchiveBytes > 0 && decompressed >= l.MaxArchiveBytes {
 		return "bytes"
 	}
+	if b := l.ratioBudget(); b > 0 && decompressed >= b {
+		return "ratio"
+	}
 	if l.MaxDepth > 0 && depth > l.MaxDepth {
 		return "depth"
 	}
@@ -766,6 +811,8 @@ func limitsExceededReason(l Limits, decompressed int64, entries int, depth int,
 	return ""
 }
 
+// looksBinary reports whether b has NUL bytes near its start, other than
+// those of UTF-16 or UTF-32 text.
 func looksBinary(b []byte) bool {
 	const sniff = 800
 	if len(b) == 0 {
@@ -777,7 +824,8 @@ func looksBinary(b []byte) bool {
 	}
 	for i := 0; i < n; i++ {
 		if b[i] == 0 {
-			return true
+			_, ok := textenc.Decode(b)
+			return !ok
 		}
 	}
 	return false
@@ -873,300 +921,124 @@ func looksLikeYAML(b []byte) bool {
 	return yamlDocRx.Find(b) != nil
 }
 
-func ScanArchivesWithStats(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte), stats *Stats) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
-	type item struct{ full, rel string }
-	var items []item
-	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
-		if d.IsDir() {
-			return nil
-		}
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
-		}
-		if allow != nil && !allow(rel) {
-			return nil
-		}
-		if !isArchivePath(rel) {
-			return nil
-		}
-		if strings.HasSuffix(strings.ToLower(rel), ".tar") {
-			ok, ierr := isContainerTar(p)
-			if ierr != nil {
-				return nil
-			}
-			if ok {
-				return nil
-			}
-		}
-		items = append(items, item{full: p, rel: rel})
-		return nil
-	})
-	workers := limits.Workers
-	if workers <= 0 {
-		workers = 1
-	}
-	ch := make(chan item, workers*2)
-	var wg sync.WaitGroup
-	for i := 0; i < workers; i++ {
-		wg.Add(1)
-		go func() {
-			defer wg.Done()
-			for it := range ch {
-				started := time.Now()
-				deadline := time.Time{}
-				if limits.TimeBudget > 0 {
-					deadline = started.Add(limits.TimeBudget)
-				}
-				var decompressed int64
-				var entries int
-				_ = scanArchiveFileWithStats(it.full, it.rel, limits, &decompressed, &entries, 0, deadline, emit, stats) //nolint:errcheck
-			}
-		}()
+func scanArchiveFileWithStats(fullPath string, rel string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return err
 	}
-	for _, it := range items {
-		ch <- it
+	defer safeClose(f)
+	fi, err := f.Stat()
+	if err != nil {
+		return err
 	}
-	close(ch)
-	wg.Wait()
-	return nil
+	return scanArchiveWithStats(rel, rel, f, fi.Size(), limits, decompressed, entries, depth, deadline, emit, stats)
 }
 
-func scanArchiveFileWithStats(fullPath string, rel string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
-	f, err := os.Open(fullPath)
+// readFailed records why reading the archive entry at pathChain failed: the
+// guardrail that stopped it or, failing that, the read error itself (a
+// truncated, corrupt or encrypted stream), which leaves the entry unscannable.
+func readFailed(stats *Stats, limits Limits, decompressed int64, entries int, depth int, deadline time.Time, pathChain string, name string, err error) {
+	if r := limitsExceededReason(limits, decompressed, entries, depth, deadline); r != "" {
+		stats.add(r)
+		return
+	}
+	if errors.Is(err, errCompressionRatio) {
+		stats.ratioExceeded(fmt.Errorf("%s: %w", name, err))
+		return
+	}
+	stats.fail(fmt.Errorf("%s: %w", name, err))
+	stats.unscannable(pathChain, unreadableReason(err))
+}
+
+// scanNestedEntry scans an archive found inside another one, or records why
+// it was not scanned.
+func scanNestedEntry(pathChain string, name string, blob []byte, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) {
+	if !isArchivePath(name) {
+		return
+	}
+	if depth >= limits.MaxDepth {
+		stats.add("depth")
+		return
+	}
+	if err := scanNestedArchiveWithStats(pathChain, name, blob, limits, decompressed, entries, depth+1, deadline, emit, stats); err != nil {
+		stats.fail(fmt.Errorf("%s: %w", name, err))
+	}
+}
+
+func scanZipReaderWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.ReaderAt, size int64, stats *Stats) error {
+	zr, err := zip.NewReader(r, size)
 	if err != nil {
+		return fmt.Errorf("corrupt zip: %w", err)
+	}
+	if a, b, ok := overlappingZipEntries(zr); ok {
+		stats.ratioExceeded(fmt.Errorf("zip entries %s and %s overlap", sanitizeEntryName(a), sanitizeEntryName(b)))
 		return nil
 	}
-	defer safeClose(f)
-
-	lower := strings.ToLower(rel)
-	switch {
-	case strings.HasSuffix(lower, ".zip"):
-		return scanZipReaderWithStats(rel, limits, decompressed, entries, depth, deadline, emit, f, stats)
-	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
-		gz, err := gzip.NewReader(f)
-		if err != nil {
+	repos := embeddedRepos{}
+	for _, f := range zr.File {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
 			return nil
 		}
-		defer safeClose(gz)
-		return scanTarReaderWithStats(rel, limits, decompressed, entries, depth, deadline, emit, gz, stats)
-	case strings.HasSuffix(lower, ".tar"):
-		return scanTarReaderWithStats(rel, limits, decompressed, entries, depth, deadline, emit, f, stats)
-	case strings.HasSuffix(lower, ".gz"):
-		gz, err := gzip.NewReader(f)
-		if err != nil {
-			return nil
+		if f.FileInfo().IsDir() {
+			continue
 		}
-		defer safeClose(gz)
-		name := sanitizeEntryName(gz.Name)
+		name := sanitizeEntryName(f.Name)
 		if name == "" {
-			name = strings.TrimSuffix(rel, ".gz")
-		}
-		b, readErr := readAllBounded(gz, limits, decompressed, deadline)
-		if readErr != nil {
-			if stats != nil {
-				if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-					stats.add(r)
-				}
-			}
-			return nil
-		}
-		if looksBinary(b) || looksNonTextMIME(name, b) {
-			return nil
+			continue // Skip invalid/traversal entries
 		}
-		emit(rel+"::"+name, b)
-		*entries++
-		return nil
-	default:
-		return nil
-	}
-}
-
-func scanZipReaderWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.ReaderAt, stats *Stats) error {
-	switch v := r.(type) {
-	case *os.File:
-		fi, err := v.Stat()
-		if err != nil {
-			return nil
+		if f.Flags&0x1 != 0 {
+			stats.fail(fmt.Errorf("%s: encrypted zip entry", name))
+			stats.unscannable(archivePath+"::"+name, "encrypted zip entry")
+			continue
 		}
-		zr, err := zip.NewReader(v, fi.Size())
+		rc, err := f.Open()
 		if err != nil {
-			return nil
-		}
-		for _, f := range zr.File {
-			if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-				if stats != nil {
-					stats.add(r)
-				}
-				return nil
-			}
-			if f.FileInfo().IsDir() {
-				continue
-			}
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			b, readErr := readAllBounded(rc, limits, decompressed, deadline)
-			safeClose(rc)
-			if readErr != nil {
-				if stats != nil {
-					if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-						stats.add(r)
-					}
-				}
-				continue
-			}
-			name := sanitizeEntryName(f.Name)
-			if name == "" {
-				continue // Skip invalid/traversal entries
-			}
-			if looksBinary(b) || looksNonTextMIME(name, b) {
-				if depth < limits.MaxDepth && isArchivePath(name) {
-					_ = scanNestedArchiveWithStats(archivePath+"::"+name, name, b, limits, decompressed, entries, depth+1, deadline, emit, stats) //nolint:errcheck
-				} else if stats != nil && isArchivePath(name) {
-					stats.add("depth")
-				}
-				continue
-			}
-			emit(archivePath+"::"+name, b)
-			*entries++
+			stats.fail(fmt.Errorf("%s: %w", name, err))
+			stats.unscannable(archivePath+"::"+name, unreadableReason(err))
+			continue
 		}
-	default:
-		return nil
+		repos.scanEntry(name, int64(f.UncompressedSize64), limitEntryRatio(rc, int64(f.CompressedSize64), limits), func(r io.Reader) {
+			scanEntryWithStats(archivePath+"::"+name, name, r, limits, decompressed, entries, depth, deadline, emit, stats)
+		})
+		safeClose(rc)
 	}
+	repos.scan(archivePath, "::", limits, decompressed, entries, depth, deadline, emit, stats)
 	return nil
 }
 
-func scanTarReaderWithStats(archivePath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader, stats *Stats) error {
+// scanTarReaderWithStats scans the entries of a tar stream, joining entry
+// names to archivePath with sep ("::" for archives, "/" for image layers),
+// then the history of any .git directories in it.
+func scanTarReaderWithStats(archivePath string, sep string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), r io.Reader, stats *Stats) error {
 	tr := tar.NewReader(r)
+	repos := embeddedRepos{}
 	for {
 		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-			if stats != nil {
-				stats.add(r)
-			}
+			stats.add(r)
 			return nil
 		}
 		hdr, err := tr.Next()
-		if errors.Is(err, io.EOF) || hdr == nil {
+		if errors.Is(err, io.EOF) {
+			repos.scan(archivePath, sep, limits, decompressed, entries, depth, deadline, emit, stats)
 			return nil
 		}
 		if err != nil {
-			return nil
+			return fmt.Errorf("corrupt tar: %w", err)
 		}
 		if hdr.FileInfo().IsDir() {
 			continue
 		}
-		b, readErr := readAllBounded(tr, limits, decompressed, deadline)
-		if readErr != nil {
-			if stats != nil {
-				if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-					stats.add(r)
-				}
-			}
-			continue
-		}
 		name := sanitizeEntryName(hdr.Name)
 		if name == "" {
 			continue // Skip invalid/traversal entries
 		}
-		if looksBinary(b) || looksNonTextMIME(name, b) {
-			if depth < limits.MaxDepth && isArchivePath(name) {
-				_ = scanNestedArchiveWithStats(archivePath+"::"+name, name, b, limits, decompressed, entries, depth+1, deadline, emit, stats) //nolint:errcheck
-			} else if stats != nil && isArchivePath(name) {
-				stats.add("depth")
-			}
-			continue
-		}
-		emit(archivePath+"::"+name, b)
-		*entries++
+		repos.scanEntry(name, hdr.Size, tr, func(r io.Reader) {
+			scanEntryWithStats(archivePath+sep+name, name, r, limits, decompressed, entries, depth, deadline, emit, stats)
+		})
 	}
 }
 
 func scanNestedArchiveWithStats(pathChain string, name string, blob []byte, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
-	lower := strings.ToLower(name)
-	switch {
-	case strings.HasSuffix(lower, ".zip"):
-		zr, err := zip.NewReader(bytes.NewReader(blob), int64(len(blob)))
-		if err != nil {
-			return nil
-		}
-		for _, f := range zr.File {
-			if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-				if stats != nil {
-					stats.add(r)
-				}
-				return nil
-			}
-			if f.FileInfo().IsDir() {
-				continue
-			}
-			rc, err := f.Open()
-			if err != nil {
-				continue
-			}
-			b, readErr := readAllBounded(rc, limits, decompressed, deadline)
-			safeClose(rc)
-			if readErr != nil {
-				if stats != nil {
-					if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-						stats.add(r)
-					}
-				}
-				continue
-			}
-			fname := sanitizeEntryName(f.Name)
-			if fname == "" {
-				continue // Skip invalid/traversal entries
-			}
-			if looksBinary(b) || looksNonTextMIME(fname, b) {
-				if depth < limits.MaxDepth && isArchivePath(fname) {
-					_ = scanNestedArchiveWithStats(pathChain+"::"+fname, fname, b, limits, decompressed, entries, depth+1, deadline, emit, stats) //nolint:errcheck
-				} else if stats != nil && isArchivePath(fname) {
-					stats.add("depth")
-				}
-				continue
-			}
-			emit(pathChain+"::"+fname, b)
-			*entries++
-		}
-	case strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz"):
-		gz, err := gzip.NewReader(bytes.NewReader(blob))
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		return scanTarReaderWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, gz, stats)
-	case strings.HasSuffix(lower, ".tar"):
-		return scanTarReaderWithStats(pathChain, limits, decompressed, entries, depth, deadline, emit, bytes.NewReader(blob), stats) //nolint:errcheck
-	case strings.HasSuffix(lower, ".gz"):
-		gz, err := gzip.NewReader(bytes.NewReader(blob))
-		if err != nil {
-			return nil
-		}
-		defer safeClose(gz)
-		n := gz.Name
-		if n == "" {
-			n = strings.TrimSuffix(filepath.Base(pathChain), ".gz")
-		}
-		b, readErr := readAllBounded(gz, limits, decompressed, deadline)
-		if readErr != nil {
-			if stats != nil {
-				if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
-					stats.add(r)
-				}
-			}
-			return nil
-		}
-		if looksBinary(b) || looksNonTextMIME(n, b) {
-			return nil
-		}
-		emit(pathChain+"::"+n, b)
-		*entries++
-	}
-	return nil
+	return scanArchiveWithStats(pathChain, name, bytes.NewReader(blob), int64(len(blob)), limits, decompressed, entries, depth, deadline, emit, stats)
 }
@@ -10,6 +10,8 @@ import (
 	"strings"
 	"testing"
 	"time"
+
+	"github.com/varalys/redactyl/internal/types"
 )
 
 func makeZip(t *testing.T, path string, files map[string]string) {
@@ -326,6 +328,120 @@ func TestScanArchivesWithStats_TimeCounter(t *testing.T) {
 	}
 }
 
+func TestScanArchivesWithStats_ParallelOrdered(t *testing.T) {
+	dir := t.TempDir()
+	var want []string
+	for i := 0; i < 8; i++ {
+		name := "a" + itoa(i) + ".zip"
+		makeZip(t, filepath.Join(dir, name), map[string]string{"x.txt": "1"})
+		want = append(want, name+"::x.txt")
+	}
+	var got []string
+	stats := &Stats{}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 2, Workers: 4}
+	if err := ScanArchivesWithStats(dir, lim, nil, func(p string, _ []byte) { got = append(got, p) }, stats); err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Fatalf("expected entries in walk order %v, got %v", want, got)
+	}
+}
+
+func TestScanArchivesWithStats_ArtifactReports(t *testing.T) {
+	dir := t.TempDir()
+	makeZip(t, filepath.Join(dir, "ok.zip"), map[string]string{"a.txt": "hello", "b.txt": "world"})
+	if err := os.WriteFile(filepath.Join(dir, "broken.tgz"), []byte("not gzip"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// A zip whose entry has the encryption flag set.
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "secret.txt", Method: zip.Store, Flags: 0x1})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = w.Write([]byte("ciphertext"))
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "locked.zip"), buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	makeZip(t, filepath.Join(dir, "many.zip"), map[string]string{"1.txt": "1", "2.txt": "2", "3.txt": "3"})
+
+	stats := &Stats{}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 2, MaxDepth: 2}
+	err = ScanArchivesWithStats(dir, lim, nil, func(string, []byte) {}, stats)
+	if err == nil || !strings.Contains(err.Error(), "corrupt gzip") {
+		t.Fatalf("expected corrupt gzip error, got %v", err)
+	}
+	reports := map[string]types.ArtifactReport{}
+	for _, r := range stats.Artifacts {
+		reports[r.Path] = r
+	}
+	if r := reports["ok.zip"]; r.Type != "archive" || r.Entries != 2 || r.Bytes != 10 || r.Aborted != "" || len(r.Errors) != 0 {
+		t.Fatalf("unexpected ok.zip report: %+v", r)
+	}
+	if r := reports["broken.tgz"]; len(r.Errors) != 1 || !strings.HasPrefix(r.Errors[0], "corrupt gzip") {
+		t.Fatalf("unexpected broken.tgz report: %+v", r)
+	}
+	if r := reports["locked.zip"]; len(r.Errors) != 1 || r.Errors[0] != "secret.txt: encrypted zip entry" {
+		t.Fatalf("unexpected locked.zip report: %+v", r)
+	}
+	if r := reports["many.zip"]; r.Entries != 2 || r.Aborted != "entries" {
+		t.Fatalf("unexpected many.zip report: %+v", r)
+	}
+}
+
+func TestScanArtifact_GlobalDeadline(t *testing.T) {
+	stats := &Stats{}
+	lim := Limits{GlobalDeadline: time.Now().Add(-time.Second)}
+	ran := false
+	err := scanArtifact(stats, lim, "a.zip", "archive", func(*Stats) (int, int64, error) {
+		ran = true
+		return 0, 0, nil
+	})
+	if err != nil || ran {
+		t.Fatalf("expected artifact to be skipped, err=%v ran=%v", err, ran)
+	}
+	if stats.AbortedByTime != 1 || len(stats.Artifacts) != 1 || stats.Artifacts[0].Aborted != "time" {
+		t.Fatalf("expected time abort to be reported; got %+v", *stats)
+	}
+}
+
+func TestDiscover_GlobalDeadlineReportsOnlyArtifacts(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"config.yaml": "name: app\nreplicas: 2\n",
+		"data.json":   `{"items": [1, 2, 3]}`,
+		"secret.yaml": "apiVersion: v1\nkind: Secret\nmetadata:\n  name: db\nstringData:\n  password: hunter2\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	stats := &Stats{}
+	lim := Limits{MaxArchiveBytes: 1 << 20, GlobalDeadline: time.Now().Add(-time.Second)}
+	emit := func(Entry) { t.Fatal("nothing should be scanned past the deadline") }
+	helm, err := NewHelmHandler(lim, HelmOptions{}, emit, stats)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := Discover(dir, nil, helm, NewK8sHandler(lim, emit, stats), NewIaCHandler(lim, emit, stats)); err != nil {
+		t.Fatal(err)
+	}
+	var aborted []string
+	for _, r := range stats.Artifacts {
+		if r.Aborted != "" {
+			aborted = append(aborted, r.Type+":"+r.Path+":"+r.Aborted)
+		}
+	}
+	if stats.AbortedByTime != 1 || len(aborted) != 1 || aborted[0] != "k8s:secret.yaml:time" {
+		t.Fatalf("expected only the Secret manifest to be aborted by time; got %v, %+v", aborted, *stats)
+	}
+}
+
 func TestScanIaC_TerraformStateSelective(t *testing.T) {
 	dir := t.TempDir()
 	// Small tfstate with sensitive-looking keys
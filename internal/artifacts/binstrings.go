@@ -0,0 +1,439 @@
+package artifacts
+
+import (
+	"bytes"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// minStringLen is the shortest printable run ExtractStrings reports; shorter
+// runs are mostly noise and too short to hold a credential.
+const minStringLen = 8
+
+// BinaryStrings is the printable text extracted from a binary, one string
+// per line of Data, for the scanner to match against.
+type BinaryStrings struct {
+	Format  string // "elf", "macho", "pe", "class" or "" for other binaries
+	Data    []byte
+	strings []binaryString
+}
+
+// binaryString is a printable run at Offset in the binary, in UTF-16LE if
+// wide, within a named section and symbol when the format has them.
+type binaryString struct {
+	offset  int64
+	wide    bool
+	section string
+	symbol  string
+}
+
+// binaryRegion is a part of a binary to extract strings from.
+type binaryRegion struct {
+	section string
+	offset  int64
+	data    []byte
+	symbols []binarySymbol // sorted by offset within data
+}
+
+// binarySymbol names the bytes from offset (relative to its region) up to
+// offset+size, or up to the next symbol if size is 0.
+type binarySymbol struct {
+	name   string
+	offset int64
+	size   int64
+}
+
+// ExtractStrings returns the printable ASCII and UTF-16LE runs in b. In ELF,
+// Mach-O and PE binaries only data sections are read, skipping code, debug
+// information and symbol tables; in Java class files only the string
+// literals of the constant pool are. Each string is attributed to its
+// section and, where the binary has a symbol table, the symbol containing
+// it.
+func ExtractStrings(b []byte) BinaryStrings {
+	format, regions := binaryRegions(b)
+	bs := BinaryStrings{Format: format}
+	var buf bytes.Buffer
+	add := func(r binaryRegion, start int, text string, wide bool) {
+		s := binaryString{offset: r.offset + int64(start), wide: wide, section: r.section, symbol: r.symbolAt(int64(start))}
+		bs.strings = append(bs.strings, s)
+		buf.WriteString(text)
+		buf.WriteByte('\n')
+	}
+	for _, r := range regions {
+		if format == "class" {
+			// Constant pool strings are whole values, however short.
+			add(r, 0, printableOnly(r.data), false)
+			continue
+		}
+		asciiRuns(r.data, func(start int, text string) { add(r, start, text, false) })
+		utf16Runs(r.data, func(start int, text string) { add(r, start, text, true) })
+	}
+	bs.Data = buf.Bytes()
+	return bs
+}
+
+// Locate maps a 1-based line and byte column of Data to the offset of the
+// same character in the binary, and the section and symbol it is in.
+func (bs BinaryStrings) Locate(line, col int) (offset int64, section, symbol string, ok bool) {
+	if line < 1 || line > len(bs.strings) {
+		return 0, "", "", false
+	}
+	s := bs.strings[line-1]
+	offset = s.offset
+	if col > 1 {
+		if s.wide {
+			offset += int64(col-1) * 2
+		} else {
+			offset += int64(col - 1)
+		}
+	}
+	return offset, s.section, s.symbol, true
+}
+
+func (r binaryRegion) symbolAt(off int64) string {
+	i := sort.Search(len(r.symbols), func(i int) bool { return r.symbols[i].offset > off }) - 1
+	if i < 0 {
+		return ""
+	}
+	s := r.symbols[i]
+	if s.size > 0 && off >= s.offset+s.size {
+		return ""
+	}
+	return s.name
+}
+
+func isPrintable(c byte) bool {
+	return c >= 0x20 && c < 0x7F || c == '\t'
+}
+
+func printableOnly(b []byte) string {
+	out := make([]byte, 0, len(b))
+	for _, c := range b {
+		if isPrintable(c) {
+			out = append(out, c)
+		} else {
+			out = append(out, ' ')
+		}
+	}
+	return string(out)
+}
+
+// asciiRuns calls fn for each run of at least minStringLen printable bytes.
+func asciiRuns(b []byte, fn func(start int, text string)) {
+	start := -1
+	for i := 0; i <= len(b); i++ {
+		if i < len(b) && isPrintable(b[i]) {
+			if start < 0 {
+				start = i
+			}
+			continue
+		}
+		if start >= 0 && i-start >= minStringLen {
+			fn(start, string(b[start:i]))
+		}
+		start = -1
+	}
+}
+
+// utf16Runs calls fn for each run of at least minStringLen printable ASCII
+// characters encoded as UTF-16LE, at either byte alignment.
+func utf16Runs(b []byte, fn func(start int, text string)) {
+	for align := 0; align < 2; align++ {
+		start := -1
+		var run []byte
+		for i := align; i+1 <= len(b); i += 2 {
+			if i+1 < len(b) && isPrintable(b[i]) && b[i+1] == 0 {
+				if start < 0 {
+					start = i
+				}
+				run = append(run, b[i])
+				continue
+			}
+			if start >= 0 && len(run) >= minStringLen {
+				fn(start, string(run))
+			}
+			start, run = -1, run[:0]
+		}
+	}
+}
+
+// binaryRegions splits b into the regions to extract strings from. Files in
+// no known format, or that fail to parse, are one region.
+func binaryRegions(b []byte) (string, []binaryRegion) {
+	whole := []binaryRegion{{data: b}}
+	switch {
+	case bytes.HasPrefix(b, []byte("\x7fELF")):
+		if regions, err := elfRegions(b); err == nil {
+			return "elf", regions
+		}
+		return "elf", whole
+	case isMachO(b):
+		if regions, err := machoRegions(b); err == nil {
+			return "macho", regions
+		}
+		return "macho", whole
+	case bytes.HasPrefix(b, []byte("MZ")):
+		if regions, err := peRegions(b); err == nil {
+			return "pe", regions
+		}
+		return "", whole
+	case bytes.HasPrefix(b, []byte{0xCA, 0xFE, 0xBA, 0xBE}) && len(b) > 8 && binary.BigEndian.Uint16(b[6:8]) >= 45:
+		// 0xCAFEBABE is also the magic of Mach-O universal binaries, whose
+		// second word is a small architecture count rather than a Java
+		// major version.
+		if regions, err := classRegions(b); err == nil {
+			return "class", regions
+		}
+		return "", whole
+	}
+	return "", whole
+}
+
+func isMachO(b []byte) bool {
+	if len(b) < 4 {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(b) {
+	case macho.Magic32, macho.Magic64, 0xcefaedfe, 0xcffaedfe:
+		return true
+	}
+	return false
+}
+
+// skipSection reports whether a section of an ELF, Mach-O or PE binary
+// holds debug information, whose strings are names rather than data.
+func skipSection(name string) bool {
+	for _, p := range []string{".debug", ".zdebug", "__debug", ".gnu_debug", ".note", ".comment"} {
+		if strings.HasPrefix(name, p) {
+			return true
+		}
+	}
+	return false
+}
+
+func sectionData(b []byte, off, size uint64) ([]byte, bool) {
+	if off > uint64(len(b)) || size > uint64(len(b))-off {
+		return nil, false
+	}
+	return b[off : off+size], true
+}
+
+func elfRegions(b []byte) ([]binaryRegion, error) {
+	f, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	syms, _ := f.Symbols()
+	var regions []binaryRegion
+	for i, s := range f.Sections {
+		if s.Type != elf.SHT_PROGBITS || s.Flags&elf.SHF_EXECINSTR != 0 || s.Flags&elf.SHF_COMPRESSED != 0 || skipSection(s.Name) {
+			continue
+		}
+		data, ok := sectionData(b, s.Offset, s.Size)
+		if !ok {
+			continue
+		}
+		r := binaryRegion{section: s.Name, offset: int64(s.Offset), data: data}
+		for _, sym := range syms {
+			if int(sym.Section) != i || elf.ST_TYPE(sym.Info) == elf.STT_SECTION || sym.Name == "" || sym.Value < s.Addr {
+				continue
+			}
+			r.symbols = append(r.symbols, binarySymbol{name: sym.Name, offset: int64(sym.Value - s.Addr), size: int64(sym.Size)})
+		}
+		r.sortSymbols()
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+func machoRegions(b []byte) ([]binaryRegion, error) {
+	f, err := macho.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var regions []binaryRegion
+	for i, s := range f.Sections {
+		const typeMask, zeroFill, attrCode = 0xff, 0x1, 0x80000000 | 0x400
+		if s.Flags&typeMask == zeroFill || s.Flags&attrCode != 0 || s.Offset == 0 || skipSection(s.Name) || s.Seg == "__DWARF" {
+			continue
+		}
+		data, ok := sectionData(b, uint64(s.Offset), s.Size)
+		if !ok {
+			continue
+		}
+		r := binaryRegion{section: s.Seg + "," + s.Name, offset: int64(s.Offset), data: data}
+		if f.Symtab != nil {
+			for _, sym := range f.Symtab.Syms {
+				// Mach-O section numbers are 1-based.
+				if int(sym.Sect) != i+1 || sym.Name == "" || sym.Value < s.Addr {
+					continue
+				}
+				r.symbols = append(r.symbols, binarySymbol{name: sym.Name, offset: int64(sym.Value - s.Addr)})
+			}
+		}
+		r.sortSymbols()
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+func peRegions(b []byte) ([]binaryRegion, error) {
+	f, err := pe.NewFile(bytes.NewReader(b))
+	if err != nil {
+		return nil, err
+	}
+	var regions []binaryRegion
+	for i, s := range f.Sections {
+		const cntCode, memExecute = 0x20, 0x20000000
+		if s.Characteristics&(cntCode|memExecute) != 0 || s.Offset == 0 || skipSection(s.Name) {
+			continue
+		}
+		// Raw data is padded to the file alignment beyond VirtualSize.
+		size := s.Size
+		if s.VirtualSize != 0 && s.VirtualSize < size {
+			size = s.VirtualSize
+		}
+		data, ok := sectionData(b, uint64(s.Offset), uint64(size))
+		if !ok {
+			continue
+		}
+		r := binaryRegion{section: s.Name, offset: int64(s.Offset), data: data}
+		for _, sym := range f.Symbols {
+			// COFF section numbers are 1-based; symbol values are offsets
+			// into the section.
+			if int(sym.SectionNumber) != i+1 || sym.Name == "" || sym.StorageClass == 3 && sym.Value == 0 {
+				continue
+			}
+			r.symbols = append(r.symbols, binarySymbol{name: sym.Name, offset: int64(sym.Value)})
+		}
+		r.sortSymbols()
+		regions = append(regions, r)
+	}
+	return regions, nil
+}
+
+func (r *binaryRegion) sortSymbols() {
+	sort.SliceStable(r.symbols, func(i, j int) bool { return r.symbols[i].offset < r.symbols[j].offset })
+}
+
+var errClassFormat = errors.New("malformed class file")
+
+// classReader reads the big-endian fields of a Java class file.
+type classReader struct {
+	b   []byte
+	off int
+	err error
+}
+
+func (r *classReader) bytes(n int) []byte {
+	if r.err != nil || n < 0 || r.off+n > len(r.b) {
+		r.err = errClassFormat
+		return nil
+	}
+	out := r.b[r.off : r.off+n]
+	r.off += n
+	return out
+}
+
+func (r *classReader) u2() int {
+	b := r.bytes(2)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint16(b))
+}
+
+func (r *classReader) u4() int {
+	b := r.bytes(4)
+	if b == nil {
+		return 0
+	}
+	return int(binary.BigEndian.Uint32(b))
+}
+
+// classRegions returns a region for each string literal (CONSTANT_String)
+// in the constant pool of a Java class file, named after the static field
+// it initialises (ConstantValue) when there is one.
+func classRegions(b []byte) ([]binaryRegion, error) {
+	r := &classReader{b: b, off: 8}
+	n := r.u2()
+	type utf8Const struct {
+		off  int
+		data []byte
+	}
+	utf8s := map[int]utf8Const{}
+	var literals []int // Utf8 indexes of CONSTANT_String entries
+	stringOf := map[int]int{}
+	for i := 1; i < n && r.err == nil; i++ {
+		tag := r.bytes(1)
+		if tag == nil {
+			break
+		}
+		switch tag[0] {
+		case 1: // Utf8
+			l := r.u2()
+			off := r.off
+			utf8s[i] = utf8Const{off: off, data: r.bytes(l)}
+		case 8: // String
+			idx := r.u2()
+			literals = append(literals, idx)
+			stringOf[i] = idx
+		case 7, 16, 19, 20: // Class, MethodType, Module, Package
+			r.bytes(2)
+		case 15: // MethodHandle
+			r.bytes(3)
+		case 3, 4, 9, 10, 11, 12, 17, 18: // Integer, Float, refs, NameAndType, Dynamic, InvokeDynamic
+			r.bytes(4)
+		case 5, 6: // Long, Double take two slots
+			r.bytes(8)
+			i++
+		default:
+			return nil, fmt.Errorf("%w: constant pool tag %d", errClassFormat, tag[0])
+		}
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	// Static final String fields name their literals.
+	fieldOf := map[int]string{}
+	r.bytes(6) // access flags, this class, super class
+	r.bytes(2 * r.u2())
+	for fields := r.u2(); fields > 0 && r.err == nil; fields-- {
+		r.bytes(2)
+		name := r.u2()
+		r.bytes(2)
+		for attrs := r.u2(); attrs > 0 && r.err == nil; attrs-- {
+			attrName := r.u2()
+			info := r.bytes(r.u4())
+			if string(utf8s[attrName].data) == "ConstantValue" && len(info) == 2 {
+				if lit, ok := stringOf[int(binary.BigEndian.Uint16(info))]; ok {
+					fieldOf[lit] = string(utf8s[name].data)
+				}
+			}
+		}
+	}
+
+	seen := map[int]bool{}
+	var regions []binaryRegion
+	for _, idx := range literals {
+		c, ok := utf8s[idx]
+		if !ok || seen[idx] || len(c.data) == 0 {
+			continue
+		}
+		seen[idx] = true
+		reg := binaryRegion{section: "constant_pool", offset: int64(c.off), data: c.data}
+		if name := fieldOf[idx]; name != "" {
+			reg.symbols = []binarySymbol{{name: name}}
+		}
+		regions = append(regions, reg)
+	}
+	sort.Slice(regions, func(i, j int) bool { return regions[i].offset < regions[j].offset })
+	return regions, nil
+}
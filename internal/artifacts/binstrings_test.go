@@ -0,0 +1,152 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// binaryNeedle is linked into the test binary as read-only data.
+var binaryNeedle = "needle-in-binary-7f3a9c2e"
+
+type extractedString struct {
+	text            string
+	offset          int64
+	section, symbol string
+}
+
+func allStrings(bs BinaryStrings) []extractedString {
+	var out []extractedString
+	for i, line := range strings.Split(strings.TrimSuffix(string(bs.Data), "\n"), "\n") {
+		off, section, symbol, ok := bs.Locate(i+1, 1)
+		if ok {
+			out = append(out, extractedString{line, off, section, symbol})
+		}
+	}
+	return out
+}
+
+func TestExtractStrings_Generic(t *testing.T) {
+	b := []byte("\x00\x01short\x00printable-run-1\x02\x03")
+	wideAt := len(b)
+	for _, c := range "wide-string" {
+		b = append(b, byte(c), 0)
+	}
+	b = append(b, 0, 0)
+
+	bs := ExtractStrings(b)
+	assert.Equal(t, "", bs.Format)
+	assert.Equal(t, []extractedString{
+		{text: "printable-run-1", offset: 8},
+		{text: "wide-string", offset: int64(wideAt)},
+	}, allStrings(bs))
+
+	off, _, _, ok := bs.Locate(2, 3)
+	require.True(t, ok)
+	assert.Equal(t, int64(wideAt+4), off, "columns in wide strings are two bytes")
+	_, _, _, ok = bs.Locate(3, 1)
+	assert.False(t, ok)
+}
+
+func TestExtractStrings_Executable(t *testing.T) {
+	exe, err := os.Executable()
+	require.NoError(t, err)
+	b, err := os.ReadFile(exe)
+	require.NoError(t, err)
+
+	bs := ExtractStrings(b)
+	require.Contains(t, []string{"elf", "macho", "pe"}, bs.Format)
+	var found *extractedString
+	for _, s := range allStrings(bs) {
+		if i := strings.Index(s.text, binaryNeedle); i >= 0 {
+			s.offset += int64(i)
+			found = &s
+			break
+		}
+	}
+	require.NotNil(t, found, "string literal not extracted")
+	assert.Equal(t, binaryNeedle, string(b[found.offset:found.offset+int64(len(binaryNeedle))]))
+	assert.NotEmpty(t, found.section)
+	assert.NotContains(t, found.section, "text", "code sections are skipped")
+}
+
+// classFile builds a class with `static final String API_KEY = secret` and
+// an unnamed literal "hello".
+func classFile(secret string) (b []byte, secretAt int) {
+	var buf bytes.Buffer
+	u2 := func(v int) { _ = binary.Write(&buf, binary.BigEndian, uint16(v)) }
+	utf8 := func(s string) {
+		buf.WriteByte(1)
+		u2(len(s))
+		if s == secret {
+			secretAt = buf.Len()
+		}
+		buf.WriteString(s)
+	}
+	buf.Write([]byte{0xCA, 0xFE, 0xBA, 0xBE, 0, 0, 0, 52})
+	u2(12)
+	utf8("API_KEY")            // 1
+	utf8("Ljava/lang/String;") // 2
+	utf8("ConstantValue")      // 3
+	utf8(secret)               // 4
+	buf.WriteByte(8)           // 5: String #4
+	u2(4)
+	utf8("Example")  // 6
+	buf.WriteByte(7) // 7: Class #6
+	u2(6)
+	utf8("java/lang/Object") // 8
+	buf.WriteByte(7)         // 9: Class #8
+	u2(8)
+	utf8("hello")    // 10
+	buf.WriteByte(8) // 11: String #10
+	u2(10)
+	u2(0x21) // access flags
+	u2(7)    // this
+	u2(9)    // super
+	u2(0)    // interfaces
+	u2(1)    // fields
+	u2(0x19) // public static final
+	u2(1)    // API_KEY
+	u2(2)    // String
+	u2(1)    // attributes
+	u2(3)    // ConstantValue
+	_ = binary.Write(&buf, binary.BigEndian, uint32(2))
+	u2(5)
+	u2(0) // methods
+	u2(0) // attributes
+	return buf.Bytes(), secretAt
+}
+
+func TestExtractStrings_JavaClass(t *testing.T) {
+	b, at := classFile("sk_live_0123456789abcdef")
+	bs := ExtractStrings(b)
+	assert.Equal(t, "class", bs.Format)
+	assert.Equal(t, []extractedString{
+		{text: "sk_live_0123456789abcdef", offset: int64(at), section: "constant_pool", symbol: "API_KEY"},
+		{text: "hello", offset: int64(bytes.Index(b, []byte("hello"))), section: "constant_pool"},
+	}, allStrings(bs), "names and descriptors are not string literals")
+}
+
+func TestScanArchives_Binaries(t *testing.T) {
+	class, _ := classFile("sk_live_0123456789abcdef")
+	files := map[string][]byte{"app.zip": zipBytes(t, map[string]string{"Example.class": string(class), "logo.png": "\x89PNG\r\n\x1a\n\x00"})}
+
+	got, _ := scanArchiveFiles(t, files)
+	assert.NotContains(t, got, "app.zip::Example.class", "binaries are skipped by default")
+
+	got = map[string]string{}
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 2, Binaries: true}
+	require.NoError(t, ScanArchives(dir, lim, func(p string, b []byte) { got[p] = string(b) }))
+	assert.Equal(t, string(class), got["app.zip::Example.class"])
+	assert.NotContains(t, got, "app.zip::logo.png")
+}
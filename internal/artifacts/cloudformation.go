@@ -0,0 +1,180 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/base64"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// scanCloudFormation scans a CloudFormation template (YAML, including the
+// short-form intrinsic tags, or JSON) and emits:
+//
+//   - the Default of each parameter with NoEcho: true or a secret-looking
+//     name, at rel::Parameters.<name>.Default
+//   - UserData scripts, unwrapped from Fn::Base64, Fn::Sub and Fn::Join (or
+//     base64-decoded when given literally), at
+//     rel::Resources.<id>.Properties.<path>.UserData
+//   - literal resource properties with secret-looking names, such as
+//     MasterUserPassword
+//
+// Entries carry cfn_parameter and cfn_no_echo, or cfn_resource,
+// cfn_resource_type and cfn_property metadata, and Entry.Line points at the
+// value in the template.
+func scanCloudFormation(rel string, data []byte, emit EntryFunc) {
+	if !bytes.Contains(data, []byte("AWSTemplateFormatVersion")) &&
+		!(bytes.Contains(data, []byte("Resources")) && bytes.Contains(data, []byte("AWS::"))) {
+		return
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	root := doc.Content[0]
+
+	if params := yamlMappingValue(root, "Parameters"); params != nil && params.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(params.Content); i += 2 {
+			name, p := params.Content[i].Value, params.Content[i+1]
+			if p.Kind != yaml.MappingNode {
+				continue
+			}
+			def := yamlMappingValue(p, "Default")
+			if def == nil || def.Kind != yaml.ScalarNode || def.Value == "" {
+				continue
+			}
+			noEcho := false
+			if n := yamlMappingValue(p, "NoEcho"); n != nil {
+				noEcho, _ = strconv.ParseBool(n.Value)
+			}
+			if !noEcho && !keyLooksSensitive(name) {
+				continue
+			}
+			meta := map[string]string{"cfn_parameter": name}
+			if noEcho {
+				meta["cfn_no_echo"] = "true"
+			}
+			emit(Entry{Path: rel + "::Parameters." + name + ".Default", Data: []byte(name + "=" + def.Value), Metadata: meta, Line: yamlContentLine(def)})
+		}
+	}
+
+	resources := yamlMappingValue(root, "Resources")
+	if resources == nil || resources.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(resources.Content); i += 2 {
+		id, res := resources.Content[i].Value, resources.Content[i+1]
+		if res.Kind != yaml.MappingNode {
+			continue
+		}
+		props := yamlMappingValue(res, "Properties")
+		if props == nil {
+			continue
+		}
+		meta := map[string]string{"cfn_resource": id}
+		if t := yamlMappingValue(res, "Type"); t != nil {
+			meta["cfn_resource_type"] = t.Value
+		}
+		cfnProperties(props, rel+"::Resources."+id+".Properties", "", meta, emit)
+	}
+}
+
+func cfnProperties(n *yaml.Node, base, path string, meta map[string]string, emit EntryFunc) {
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			key, val := n.Content[i].Value, n.Content[i+1]
+			if key == "Ref" || strings.HasPrefix(key, "Fn::") {
+				continue
+			}
+			child := joinK8sLocation(path, key)
+			switch {
+			case key == "UserData":
+				if script, line := cfnUserData(val); script != "" {
+					m := copyMeta(meta)
+					m["cfn_property"] = child
+					emit(Entry{Path: base + "." + child, Data: []byte(script), Metadata: m, Line: line})
+				}
+			case val.Kind == yaml.ScalarNode:
+				if cfnSecretProperty(key) && val.Value != "" && cfnLiteral(val) {
+					m := copyMeta(meta)
+					m["cfn_property"] = child
+					emit(Entry{Path: base + "." + child, Data: []byte(key + ": " + val.Value), Metadata: m, Line: yamlContentLine(val)})
+				}
+			default:
+				cfnProperties(val, base, child, meta, emit)
+			}
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			cfnProperties(c, base, path+"["+strconv.Itoa(i)+"]", meta, emit)
+		}
+	}
+}
+
+// cfnUserData extracts the script from a UserData value: a literal (base64
+// or plain), !Base64/Fn::Base64 around it, !Sub/Fn::Sub (the template
+// string) or !Join/Fn::Join (the literal parts joined).
+func cfnUserData(n *yaml.Node) (string, int) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Value == "" || !cfnLiteral(n) && n.Tag != "!Base64" && n.Tag != "!Sub" {
+			return "", 0
+		}
+		if n.Tag != "!Base64" && n.Tag != "!Sub" {
+			if dec, err := base64.StdEncoding.DecodeString(strings.TrimSpace(n.Value)); err == nil && len(dec) > 0 && !looksBinary(dec) {
+				return string(dec), yamlContentLine(n)
+			}
+		}
+		return n.Value, yamlContentLine(n)
+	case yaml.MappingNode:
+		if len(n.Content) == 2 {
+			switch n.Content[0].Value {
+			case "Fn::Base64", "Fn::Sub":
+				return cfnUserData(n.Content[1])
+			case "Fn::Join":
+				return cfnJoin(n.Content[1])
+			}
+		}
+	case yaml.SequenceNode:
+		switch n.Tag {
+		case "!Sub":
+			if len(n.Content) > 0 {
+				return cfnUserData(n.Content[0])
+			}
+		case "!Join":
+			return cfnJoin(n)
+		}
+	}
+	return "", 0
+}
+
+// cfnJoin joins the literal parts of a Fn::Join [delimiter, [parts...]].
+func cfnJoin(n *yaml.Node) (string, int) {
+	if n.Kind != yaml.SequenceNode || len(n.Content) != 2 || n.Content[1].Kind != yaml.SequenceNode {
+		return "", 0
+	}
+	var parts []string
+	for _, p := range n.Content[1].Content {
+		if p.Kind == yaml.ScalarNode && cfnLiteral(p) {
+			parts = append(parts, p.Value)
+		}
+	}
+	if len(parts) == 0 {
+		return "", 0
+	}
+	return strings.Join(parts, n.Content[0].Value), yamlContentLine(n.Content[1].Content[0])
+}
+
+// cfnSecretProperty reports whether a property name looks like a secret.
+// Key is excluded: in templates it names tag keys and S3 object keys.
+func cfnSecretProperty(name string) bool {
+	return name != "Key" && keyLooksSensitive(name)
+}
+
+// cfnLiteral reports whether a scalar is a literal rather than a short-form
+// intrinsic function such as !Ref or !GetAtt.
+func cfnLiteral(n *yaml.Node) bool {
+	return !strings.HasPrefix(n.Tag, "!") || strings.HasPrefix(n.Tag, "!!")
+}
@@ -0,0 +1,105 @@
+package artifacts
+
+import (
+	"encoding/base64"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testCloudFormationTemplate = `AWSTemplateFormatVersion: "2010-09-09"
+Parameters:
+  DBPassword:
+    Type: String
+    NoEcho: true
+    Default: hunter2-prod
+  Opaque:
+    Type: String
+    NoEcho: "true"
+    Default: not-a-secret-name
+  Environment:
+    Type: String
+    Default: prod
+Resources:
+  DB:
+    Type: AWS::RDS::DBInstance
+    Properties:
+      MasterUsername: admin
+      MasterUserPassword: S3cr3t-Pa55w0rd
+      Tags:
+        - Key: api_token
+          Value: !Ref Environment
+  Web:
+    Type: AWS::EC2::Instance
+    Properties:
+      UserData:
+        Fn::Base64: !Sub |
+          #!/bin/bash
+          export API_KEY=sk_live_abcdefghijklmnop
+  Worker:
+    Type: AWS::EC2::LaunchTemplate
+    Properties:
+      LaunchTemplateData:
+        UserData: !Base64
+          Fn::Join:
+            - ""
+            - - "#!/bin/bash\n"
+              - "export TOKEN=tkn-123\n"
+              - !Ref Environment
+  Legacy:
+    Type: AWS::EC2::Instance
+    Properties:
+      UserData: %s
+`
+
+func TestScanCloudFormation(t *testing.T) {
+	legacy := base64.StdEncoding.EncodeToString([]byte("#!/bin/sh\necho PASSWORD=abc\n"))
+	src := []byte(fmt.Sprintf(testCloudFormationTemplate, legacy))
+	got := map[string]Entry{}
+	scanCloudFormation("stack.yaml", src, func(e Entry) { got[e.Path] = e })
+
+	pw, ok := got["stack.yaml::Parameters.DBPassword.Default"]
+	require.True(t, ok, "NoEcho default missing: %v", entryPaths(got))
+	assert.Equal(t, "DBPassword=hunter2-prod", string(pw.Data))
+	assert.Equal(t, 6, pw.Line)
+	assert.Equal(t, "true", pw.Metadata["cfn_no_echo"])
+	assert.Equal(t, "true", got["stack.yaml::Parameters.Opaque.Default"].Metadata["cfn_no_echo"])
+
+	master := got["stack.yaml::Resources.DB.Properties.MasterUserPassword"]
+	assert.Equal(t, "MasterUserPassword: S3cr3t-Pa55w0rd", string(master.Data))
+	assert.Equal(t, "DB", master.Metadata["cfn_resource"])
+	assert.Equal(t, "AWS::RDS::DBInstance", master.Metadata["cfn_resource_type"])
+	assert.Equal(t, "MasterUserPassword", master.Metadata["cfn_property"])
+
+	web := got["stack.yaml::Resources.Web.Properties.UserData"]
+	assert.Contains(t, string(web.Data), "export API_KEY=sk_live_abcdefghijklmnop")
+	assert.Equal(t, 28, web.Line)
+
+	worker := got["stack.yaml::Resources.Worker.Properties.LaunchTemplateData.UserData"]
+	assert.Equal(t, "#!/bin/bash\nexport TOKEN=tkn-123\n", string(worker.Data))
+	assert.Equal(t, "LaunchTemplateData.UserData", worker.Metadata["cfn_property"])
+
+	assert.Equal(t, "#!/bin/sh\necho PASSWORD=abc\n", string(got["stack.yaml::Resources.Legacy.Properties.UserData"].Data))
+
+	assert.NotContains(t, got, "stack.yaml::Parameters.Environment.Default")
+	assert.NotContains(t, got, "stack.yaml::Resources.DB.Properties.MasterUsername")
+	assert.Len(t, got, 6)
+}
+
+func TestScanCloudFormation_JSONAndNonTemplates(t *testing.T) {
+	src := `{"AWSTemplateFormatVersion": "2010-09-09",
+  "Parameters": {"ApiKeySecret": {"Type": "String", "Default": "abc123"}},
+  "Resources": {"Fn": {"Type": "AWS::Lambda::Function", "Properties": {"Environment": {"Variables": {"DB_PASSWORD": "p@ss", "REGION": "eu-west-1"}}}}}}`
+	got := map[string]Entry{}
+	scanCloudFormation("stack.json", []byte(src), func(e Entry) { got[e.Path] = e })
+	assert.Equal(t, "ApiKeySecret=abc123", string(got["stack.json::Parameters.ApiKeySecret.Default"].Data))
+	assert.Empty(t, got["stack.json::Parameters.ApiKeySecret.Default"].Metadata["cfn_no_echo"])
+	assert.Contains(t, got, "stack.json::Resources.Fn.Properties.Environment.Variables.DB_PASSWORD")
+	assert.Len(t, got, 2)
+
+	n := 0
+	scanCloudFormation("values.yaml", []byte("password: hunter2\n"), func(Entry) { n++ })
+	assert.Zero(t, n)
+}
@@ -0,0 +1,125 @@
+package artifacts
+
+import (
+	"path/filepath"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+func isComposePath(rel string) bool {
+	base := strings.ToLower(filepath.Base(rel))
+	ext := filepath.Ext(base)
+	if ext != ".yml" && ext != ".yaml" {
+		return false
+	}
+	return strings.HasPrefix(base, "docker-compose") || strings.HasPrefix(base, "compose")
+}
+
+// scanCompose scans a Docker Compose file and emits each literal value of a
+// service's environment: (mapping or KEY=value list) and build.args at
+// rel::services.<service>.environment.<NAME> (or build.args.<NAME>), as
+// NAME=value with Entry.Line set. Entries carry compose_service and
+// compose_env_name metadata. Values that only reference another variable
+// ($VAR, ${VAR}) are skipped; YAML anchors and merge keys are resolved.
+func scanCompose(rel string, data []byte, emit EntryFunc) {
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	services := resolveYAMLAlias(yamlMappingValue(doc.Content[0], "services"))
+	if services == nil || services.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(services.Content); i += 2 {
+		name, svc := services.Content[i].Value, resolveYAMLAlias(services.Content[i+1])
+		if svc.Kind != yaml.MappingNode {
+			continue
+		}
+		base := rel + "::services." + name
+		composeVars(composeMappingValue(svc, "environment"), base+".environment", name, emit)
+		if build := composeMappingValue(svc, "build"); build != nil && build.Kind == yaml.MappingNode {
+			composeVars(composeMappingValue(build, "args"), base+".build.args", name, emit)
+		}
+	}
+}
+
+// composeVars emits the variables of an environment: or args: node.
+func composeVars(n *yaml.Node, base, service string, emit EntryFunc) {
+	if n == nil {
+		return
+	}
+	out := func(name, value string, line int) {
+		if value == "" || isVarReference(value) {
+			return
+		}
+		emit(Entry{
+			Path:     base + "." + name,
+			Data:     []byte(name + "=" + value),
+			Metadata: map[string]string{"compose_service": service, "compose_env_name": name},
+			Line:     line,
+		})
+	}
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			v := resolveYAMLAlias(n.Content[i+1])
+			if n.Content[i].Value == "<<" || v.Kind != yaml.ScalarNode || v.Tag == "!!null" {
+				continue
+			}
+			out(n.Content[i].Value, v.Value, yamlContentLine(v))
+		}
+		for _, merged := range yamlMergedMappings(n) {
+			composeVars(merged, base, service, emit)
+		}
+	case yaml.SequenceNode:
+		for _, item := range n.Content {
+			item = resolveYAMLAlias(item)
+			if k, v, ok := strings.Cut(item.Value, "="); ok && item.Kind == yaml.ScalarNode {
+				out(k, v, item.Line)
+			}
+		}
+	}
+}
+
+// composeMappingValue looks up key in m, following merge keys (<<: *base)
+// when m does not set it itself.
+func composeMappingValue(m *yaml.Node, key string) *yaml.Node {
+	if v := yamlMappingValue(m, key); v != nil {
+		return resolveYAMLAlias(v)
+	}
+	for _, merged := range yamlMergedMappings(m) {
+		if v := composeMappingValue(merged, key); v != nil {
+			return v
+		}
+	}
+	return nil
+}
+
+// yamlMergedMappings returns the mappings merged into m with <<.
+func yamlMergedMappings(m *yaml.Node) []*yaml.Node {
+	var out []*yaml.Node
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value != "<<" {
+			continue
+		}
+		switch v := resolveYAMLAlias(m.Content[i+1]); v.Kind {
+		case yaml.MappingNode:
+			out = append(out, v)
+		case yaml.SequenceNode:
+			for _, c := range v.Content {
+				if c = resolveYAMLAlias(c); c.Kind == yaml.MappingNode {
+					out = append(out, c)
+				}
+			}
+		}
+	}
+	return out
+}
+
+func resolveYAMLAlias(n *yaml.Node) *yaml.Node {
+	for n != nil && n.Kind == yaml.AliasNode {
+		n = n.Alias
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanCompose(t *testing.T) {
+	src := `x-common: &common
+  environment:
+    SHARED_TOKEN: tkn-shared-123
+services:
+  db:
+    image: postgres
+    environment:
+      POSTGRES_PASSWORD: hunter2-prod
+      POSTGRES_USER: ${DB_USER}
+      EMPTY:
+  web:
+    <<: *common
+    build:
+      context: .
+      args:
+        - NPM_TOKEN=npm_abcdefghijklmnop
+        - NODE_ENV
+  worker:
+    environment:
+      - API_KEY=sk_live_abcdefghijklmnop
+      - REF=$API_KEY
+`
+	require.True(t, isComposePath("deploy/docker-compose.prod.yml"))
+	require.True(t, isComposePath("compose.yaml"))
+	require.False(t, isComposePath("values.yaml"))
+
+	got := map[string]Entry{}
+	scanCompose("compose.yaml", []byte(src), func(e Entry) { got[e.Path] = e })
+
+	pw, ok := got["compose.yaml::services.db.environment.POSTGRES_PASSWORD"]
+	require.True(t, ok, "environment mapping missing: %v", entryPaths(got))
+	assert.Equal(t, "POSTGRES_PASSWORD=hunter2-prod", string(pw.Data))
+	assert.Equal(t, 8, pw.Line)
+	assert.Equal(t, "db", pw.Metadata["compose_service"])
+	assert.Equal(t, "POSTGRES_PASSWORD", pw.Metadata["compose_env_name"])
+
+	list := got["compose.yaml::services.worker.environment.API_KEY"]
+	assert.Equal(t, "API_KEY=sk_live_abcdefghijklmnop", string(list.Data))
+	assert.Equal(t, 20, list.Line)
+
+	assert.Equal(t, "NPM_TOKEN=npm_abcdefghijklmnop", string(got["compose.yaml::services.web.build.args.NPM_TOKEN"].Data))
+	assert.Equal(t, "web", got["compose.yaml::services.web.environment.SHARED_TOKEN"].Metadata["compose_service"])
+	assert.Len(t, got, 4)
+}
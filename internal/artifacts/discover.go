@@ -0,0 +1,94 @@
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path/filepath"
+
+	"github.com/varalys/redactyl/internal/ignore"
+)
+
+// Handler scans one type of artifact found by Discover. Match classifies a
+// candidate path; Handle scans the candidates it matched. Handlers that need
+// to see the whole tree first (to resolve kustomization roots, or to scan in
+// parallel) also implement Finisher and defer their work to Finish.
+type Handler interface {
+	Match(c *Candidate) bool
+	Handle(c *Candidate) error
+}
+
+// Finisher is implemented by handlers that scan after the walk completes.
+type Finisher interface {
+	Finish(root string) error
+}
+
+// Candidate is a file or directory offered to handlers during discovery.
+type Candidate struct {
+	Path  string // path on disk
+	Rel   string // path relative to the discovery root
+	Entry fs.DirEntry
+
+	containerTar *bool
+}
+
+// IsDir reports whether the candidate is a directory.
+func (c *Candidate) IsDir() bool { return c.Entry.IsDir() }
+
+// isContainerTar reports whether the candidate is a `docker save` tarball.
+// The file is opened at most once per walk, however many handlers ask; files
+// that cannot be read are reported as neither container nor plain tar, so
+// both handlers skip them.
+func (c *Candidate) isContainerTar() (bool, error) {
+	if c.containerTar == nil {
+		ok, err := isContainerTar(c.Path)
+		if err != nil {
+			return false, err
+		}
+		c.containerTar = &ok
+	}
+	return *c.containerTar, nil
+}
+
+// Discover walks root once and offers every file, and every directory, to
+// each handler, skipping .git, paths matched by root's .redactylignore
+// (ignored directories are not descended into) and paths rejected by allow.
+// Handle errors do not stop the walk; they are returned together with the
+// errors of each Finisher, which run in handler order once the walk is done.
+func Discover(root string, allow PathAllowFunc, handlers ...Handler) error {
+	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
+	var errs []error
+	walkErr := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		rel, _ := filepath.Rel(root, p)
+		if d.IsDir() && (d.Name() == ".git" || (rel != "." && ign.Match(rel))) {
+			return filepath.SkipDir
+		}
+		if (!d.IsDir() && ign.Match(rel)) || (allow != nil && !allow(rel)) {
+			return nil
+		}
+		c := &Candidate{Path: p, Rel: rel, Entry: d}
+		for _, h := range handlers {
+			if !h.Match(c) {
+				continue
+			}
+			if err := h.Handle(c); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", filepath.ToSlash(rel), err))
+			}
+		}
+		return nil
+	})
+	if walkErr != nil {
+		errs = append(errs, walkErr)
+	}
+	for _, h := range handlers {
+		if f, ok := h.(Finisher); ok {
+			if err := f.Finish(root); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	return errors.Join(errs...)
+}
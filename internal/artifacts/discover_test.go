@@ -0,0 +1,121 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// recordingHandler matches paths accepted by match and records what it saw.
+type recordingHandler struct {
+	match    func(c *Candidate) bool
+	err      error
+	handled  []string
+	finished string
+}
+
+func (h *recordingHandler) Match(c *Candidate) bool { return h.match(c) }
+
+func (h *recordingHandler) Handle(c *Candidate) error {
+	h.handled = append(h.handled, filepath.ToSlash(c.Rel))
+	return h.err
+}
+
+func (h *recordingHandler) Finish(root string) error {
+	h.finished = root
+	return nil
+}
+
+func TestDiscover_DispatchesToEveryMatchingHandler(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		".redactylignore":         "vendor\nskip.yaml\n",
+		"app/values.yaml":         "a: 1\n",
+		"app/Chart.yaml":          "name: app\n",
+		"skip.yaml":               "a: 1\n",
+		"vendor/lib/values.yaml":  "a: 1\n",
+		".git/config.yaml":        "a: 1\n",
+		"excluded/values.yaml":    "a: 1\n",
+		"kustomize/kustomization": "a: 1\n",
+	})
+
+	yamls := &recordingHandler{match: func(c *Candidate) bool { return !c.IsDir() && filepath.Ext(c.Rel) == ".yaml" }}
+	dirs := &recordingHandler{match: func(c *Candidate) bool { return c.IsDir() }}
+	allow := func(rel string) bool { return filepath.Dir(rel) != "excluded" }
+
+	require.NoError(t, Discover(root, allow, yamls, dirs))
+	assert.ElementsMatch(t, []string{"app/Chart.yaml", "app/values.yaml"}, yamls.handled)
+	assert.ElementsMatch(t, []string{".", "app", "excluded", "kustomize"}, dirs.handled)
+	assert.Equal(t, root, yamls.finished)
+	assert.Equal(t, root, dirs.finished)
+}
+
+func TestDiscover_CollectsHandleErrors(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{"a.yaml": "", "b.yaml": ""})
+
+	h := &recordingHandler{match: func(c *Candidate) bool { return !c.IsDir() }, err: errors.New("boom")}
+	err := Discover(root, nil, h)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "a.yaml: boom")
+	assert.Contains(t, err.Error(), "b.yaml: boom")
+	assert.Len(t, h.handled, 2, "errors do not stop the walk")
+}
+
+func TestDiscover_ContainerTarSniffedOnce(t *testing.T) {
+	root := t.TempDir()
+	p := filepath.Join(root, "image.tar")
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	require.NoError(t, tw.WriteHeader(&tar.Header{Name: "manifest.json", Mode: 0o644, Size: 2}))
+	_, err = tw.Write([]byte("[]"))
+	require.NoError(t, err)
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+
+	c := &Candidate{Path: p, Rel: "image.tar"}
+	ok, err := c.isContainerTar()
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	// The memoised answer is used once the file is gone.
+	require.NoError(t, os.Remove(p))
+	ok, err = c.isContainerTar()
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
+
+func TestDiscover_ArchiveAndContainerHandlersSplitTars(t *testing.T) {
+	root := t.TempDir()
+	writeTar(t, filepath.Join(root, "image.tar"), map[string]string{"manifest.json": "[]"})
+	writeTar(t, filepath.Join(root, "plain.tar"), map[string]string{"config.txt": "hello"})
+
+	var archives, containers []string
+	err := Discover(root, nil,
+		NewArchiveHandler(Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 10, MaxDepth: 2}, func(p string, _ []byte) { archives = append(archives, p) }, nil),
+		NewContainerHandler(Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 10, MaxDepth: 2}, func(p string, _ []byte) { containers = append(containers, p) }, nil),
+	)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"plain.tar::config.txt"}, archives)
+	assert.Empty(t, containers, "image without layers emits nothing")
+}
+
+func writeTar(t *testing.T, p string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(p)
+	require.NoError(t, err)
+	tw := tar.NewWriter(f)
+	for _, name := range sortedKeys(files) {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0o644, Size: int64(len(files[name]))}))
+		_, err := tw.Write([]byte(files[name]))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, f.Close())
+}
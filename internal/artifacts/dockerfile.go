@@ -0,0 +1,92 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+func isDockerfilePath(rel string) bool {
+	base := strings.ToLower(filepath.Base(rel))
+	return base == "dockerfile" || base == "containerfile" ||
+		strings.HasPrefix(base, "dockerfile.") || strings.HasSuffix(base, ".dockerfile")
+}
+
+// scanDockerfile emits the values set by ENV instructions and the defaults of
+// ARG instructions at rel::ENV.<NAME> or rel::ARG.<NAME>, as NAME=value with
+// Entry.Line set to the instruction's first line. Entries carry
+// dockerfile_instruction and dockerfile_stage (the FROM alias, or the stage
+// index when it has none) metadata. Values that only reference another
+// variable are skipped.
+func scanDockerfile(rel string, data []byte, emit EntryFunc) {
+	stage, stageIndex := "", -1
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	var cur strings.Builder
+	start := 0
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if cur.Len() == 0 {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			start = lineNo
+		} else if strings.HasPrefix(line, "#") {
+			// Comments may appear between continuation lines.
+			continue
+		}
+		if strings.HasSuffix(line, "\\") {
+			cur.WriteString(strings.TrimSuffix(line, "\\"))
+			cur.WriteByte(' ')
+			continue
+		}
+		cur.WriteString(line)
+		instr := cur.String()
+		cur.Reset()
+
+		keyword, args, _ := strings.Cut(instr, " ")
+		args = strings.TrimSpace(args)
+		switch strings.ToUpper(keyword) {
+		case "FROM":
+			stageIndex++
+			stage = strconv.Itoa(stageIndex)
+			if f := strings.Fields(args); len(f) >= 3 && strings.EqualFold(f[len(f)-2], "as") {
+				stage = f[len(f)-1]
+			}
+		case "ENV", "ARG":
+			kw := strings.ToUpper(keyword)
+			for _, kv := range dockerfileAssignments(kw, args) {
+				if kv[1] == "" || isVarReference(kv[1]) {
+					continue
+				}
+				meta := map[string]string{"dockerfile_instruction": kw}
+				if stage != "" {
+					meta["dockerfile_stage"] = stage
+				}
+				emit(Entry{Path: rel + "::" + kw + "." + kv[0], Data: []byte(kv[0] + "=" + kv[1]), Metadata: meta, Line: start})
+			}
+		}
+	}
+}
+
+// dockerfileAssignments parses ENV/ARG arguments: NAME=value pairs (values
+// may be quoted) or the legacy ENV NAME value form. ARG without a default
+// yields an empty value.
+func dockerfileAssignments(keyword, args string) [][2]string {
+	words := splitQuotedFields(args)
+	if len(words) == 0 {
+		return nil
+	}
+	if keyword == "ENV" && !strings.Contains(words[0], "=") {
+		_, rest, _ := strings.Cut(args, words[0])
+		return [][2]string{{words[0], unquoteValue(strings.TrimSpace(rest))}}
+	}
+	var out [][2]string
+	for _, w := range words {
+		k, v, _ := strings.Cut(w, "=")
+		out = append(out, [2]string{k, unquoteValue(v)})
+	}
+	return out
+}
@@ -0,0 +1,159 @@
+package artifacts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-billy/v5"
+	"github.com/go-git/go-billy/v5/memfs"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/plumbing/storer"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+)
+
+// embeddedRepos collects the files of .git directories shipped inside an
+// archive or image layer, keyed by the directory's path in the archive
+// (e.g. "app/.git"), so their history can be scanned once the archive has
+// been read.
+type embeddedRepos map[string]billy.Filesystem
+
+// gitDirOf splits an archive entry name inside a .git directory into the
+// directory and the entry's path within it.
+func gitDirOf(name string) (dir, rel string, ok bool) {
+	if strings.HasPrefix(name, ".git/") {
+		return ".git", name[len(".git/"):], true
+	}
+	i := strings.Index(name, "/.git/")
+	if i < 0 {
+		return "", "", false
+	}
+	return name[:i+len("/.git")], name[i+len("/.git/"):], true
+}
+
+// scanEntry scans an archive entry with scan and, if it is inside a .git
+// directory and was read in full, keeps a copy of its content.
+func (er embeddedRepos) scanEntry(name string, size int64, r io.Reader, scan func(r io.Reader)) {
+	dir, rel, ok := gitDirOf(name)
+	if !ok {
+		scan(r)
+		return
+	}
+	var buf bytes.Buffer
+	scan(io.TeeReader(r, &buf))
+	if int64(buf.Len()) != size {
+		return
+	}
+	fs, ok := er[dir]
+	if !ok {
+		fs = memfs.New()
+		er[dir] = fs
+	}
+	// A file that cannot be stored surfaces as an error opening or reading
+	// the repository.
+	_ = writeMemFile(fs, rel, buf.Bytes())
+}
+
+func writeMemFile(fs billy.Filesystem, name string, data []byte) error {
+	f, err := fs.Create(name)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		safeClose(f)
+		return err
+	}
+	return f.Close()
+}
+
+// scan scans the history of each collected repository, joining the
+// directory to archivePath with sep as for the archive's other entries.
+func (er embeddedRepos) scan(archivePath string, sep string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) {
+	dirs := make([]string, 0, len(er))
+	for dir := range er {
+		dirs = append(dirs, dir)
+	}
+	sort.Strings(dirs)
+	for _, dir := range dirs {
+		repoPath := archivePath + sep + dir
+		if err := scanGitHistoryWithStats(repoPath, er[dir], limits, decompressed, entries, depth, deadline, emit, stats); err != nil {
+			stats.fail(fmt.Errorf("%s: %w", dir, err))
+			stats.unscannable(repoPath, err.Error())
+		}
+	}
+}
+
+// scanGitHistoryWithStats scans every blob added or modified by a commit
+// reachable from any reference of the repository stored in fs, as
+// repoPath::commit:<sha>::<file>. Each blob is scanned once, under the
+// first commit found to introduce it, and counts towards the archive's
+// entry and byte limits.
+func scanGitHistoryWithStats(repoPath string, fs billy.Filesystem, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit func(path string, data []byte), stats *Stats) error {
+	repo, err := git.Open(filesystem.NewStorage(fs, cache.NewObjectLRUDefault()), nil)
+	if err != nil {
+		return fmt.Errorf("open embedded git repository: %w", err)
+	}
+	commits, err := repo.Log(&git.LogOptions{All: true})
+	if err != nil {
+		return fmt.Errorf("read embedded git history: %w", err)
+	}
+	defer commits.Close()
+	seen := map[plumbing.Hash]bool{}
+	return commits.ForEach(func(c *object.Commit) error {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
+			return storer.ErrStop
+		}
+		tree, err := c.Tree()
+		if err != nil {
+			return fmt.Errorf("commit %s: %w", c.Hash, err)
+		}
+		var parent *object.Tree
+		if c.NumParents() > 0 {
+			p, err := c.Parent(0)
+			if err != nil {
+				return fmt.Errorf("commit %s: %w", c.Hash, err)
+			}
+			if parent, err = p.Tree(); err != nil {
+				return fmt.Errorf("commit %s: %w", p.Hash, err)
+			}
+		}
+		changes, err := object.DiffTree(parent, tree)
+		if err != nil {
+			return fmt.Errorf("commit %s: %w", c.Hash, err)
+		}
+		for _, ch := range changes {
+			to := ch.To
+			if to.Name == "" || !to.TreeEntry.Mode.IsFile() || seen[to.TreeEntry.Hash] {
+				continue
+			}
+			seen[to.TreeEntry.Hash] = true
+			name := sanitizeEntryName(to.Name)
+			if name == "" {
+				continue
+			}
+			pathChain := repoPath + "::commit:" + c.Hash.String() + "::" + name
+			blob, err := repo.BlobObject(to.TreeEntry.Hash)
+			if err != nil {
+				stats.fail(fmt.Errorf("%s: %w", name, err))
+				stats.unscannable(pathChain, unreadableReason(err))
+				continue
+			}
+			rc, err := blob.Reader()
+			if err != nil {
+				stats.fail(fmt.Errorf("%s: %w", name, err))
+				stats.unscannable(pathChain, unreadableReason(err))
+				continue
+			}
+			scanEntryWithStats(pathChain, name, rc, limits, decompressed, entries, depth, deadline, emit, stats)
+			safeClose(rc)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,111 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-billy/v5/memfs"
+	"github.com/go-git/go-billy/v5/util"
+	git "github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/cache"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/go-git/go-git/v5/storage/filesystem"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// gitDirFiles builds a repository whose first commit adds config.yml with a
+// secret and whose second commit deletes it, and returns the files of its
+// .git directory.
+func gitDirFiles(t *testing.T) (files map[string]string, added string) {
+	t.Helper()
+	dot, work := memfs.New(), memfs.New()
+	repo, err := git.Init(filesystem.NewStorage(dot, cache.NewObjectLRUDefault()), work)
+	require.NoError(t, err)
+	wt, err := repo.Worktree()
+	require.NoError(t, err)
+	sig := &object.Signature{Name: "dev", Email: "dev@example.com", When: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)}
+
+	require.NoError(t, util.WriteFile(work, "config.yml", []byte("password: hunter2\n"), 0o644))
+	_, err = wt.Add("config.yml")
+	require.NoError(t, err)
+	first, err := wt.Commit("add config", &git.CommitOptions{Author: sig})
+	require.NoError(t, err)
+
+	require.NoError(t, util.WriteFile(work, "README.md", []byte("# app\n"), 0o644))
+	_, err = wt.Add("README.md")
+	require.NoError(t, err)
+	_, err = wt.Remove("config.yml")
+	require.NoError(t, err)
+	_, err = wt.Commit("remove config", &git.CommitOptions{Author: sig})
+	require.NoError(t, err)
+
+	files = map[string]string{}
+	require.NoError(t, util.Walk(dot, "/", func(p string, fi os.FileInfo, err error) error {
+		if err != nil || fi.IsDir() {
+			return err
+		}
+		b, err := util.ReadFile(dot, p)
+		files[filepath.ToSlash(p)[1:]] = string(b)
+		return err
+	}))
+	return files, first.String()
+}
+
+func prefixed(prefix string, files map[string]string) map[string]string {
+	out := map[string]string{}
+	for name, data := range files {
+		out[prefix+name] = data
+	}
+	return out
+}
+
+func TestGitDirOf(t *testing.T) {
+	for name, want := range map[string][2]string{
+		".git/HEAD":                    {".git", "HEAD"},
+		"app/.git/objects/ab/cdef":     {"app/.git", "objects/ab/cdef"},
+		"srv/app/.git/refs/heads/main": {"srv/app/.git", "refs/heads/main"},
+	} {
+		dir, rel, ok := gitDirOf(name)
+		require.True(t, ok, name)
+		assert.Equal(t, want, [2]string{dir, rel}, name)
+	}
+	for _, name := range []string{".gitignore", "app/.github/workflows/ci.yml", "app/.git"} {
+		_, _, ok := gitDirOf(name)
+		assert.False(t, ok, name)
+	}
+}
+
+func TestScanContainers_EmbeddedGitHistory(t *testing.T) {
+	gitFiles, first := gitDirFiles(t)
+	layerFiles := prefixed("app/.git/", gitFiles)
+	layerFiles["app/README.md"] = "# app\n"
+	image := tarBytes(t, map[string]string{
+		"manifest.json": `[{"Config":"config.json","Layers":["123/layer.tar"]}]`,
+		"123/layer.tar": string(tarBytes(t, layerFiles)),
+	})
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.tar"), image, 0o600))
+
+	got := map[string]string{}
+	var stats Stats
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 1000, MaxDepth: 2, TimeBudget: 5 * time.Second}
+	require.NoError(t, ScanContainersWithStats(dir, lim, nil, func(p string, b []byte) { got[p] = string(b) }, &stats))
+
+	assert.Equal(t, "password: hunter2\n", got["image.tar::123/app/.git::commit:"+first+"::config.yml"])
+	assert.Equal(t, "# app\n", got["image.tar::123/app/README.md"])
+	assert.Empty(t, stats.Unscannable)
+}
+
+func TestScanArchives_EmbeddedGitHistoryZip(t *testing.T) {
+	gitFiles, first := gitDirFiles(t)
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "src.zip"), zipBytes(t, prefixed(".git/", gitFiles)), 0o600))
+
+	got := map[string]string{}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 1000, MaxDepth: 2, TimeBudget: 5 * time.Second}
+	require.NoError(t, ScanArchives(dir, lim, func(p string, b []byte) { got[p] = string(b) }))
+	assert.Equal(t, "password: hunter2\n", got["src.zip::.git::commit:"+first+"::config.yml"])
+}
@@ -2,16 +2,22 @@ package artifacts
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"errors"
 	"fmt"
 	"io"
 	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
 	"strings"
+	"time"
 
-	"github.com/varalys/redactyl/internal/ignore"
 	yaml "gopkg.in/yaml.v3"
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chart/loader"
 )
 
 // HelmChart represents a Helm chart metadata
@@ -114,87 +120,309 @@ func ScanHelmCharts(root string, limits Limits, emit func(path string, data []by
 
 // ScanHelmChartsWithFilter is like ScanHelmCharts but with an optional path filter
 func ScanHelmChartsWithFilter(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte)) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
+	return ScanHelmChartsWithOptions(root, limits, HelmOptions{}, allow, func(e Entry) { emit(e.Path, e.Data) })
+}
 
-	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
-		if err != nil {
-			return nil
-		}
+// ScanHelmChartsWithOptions is like ScanHelmChartsWithFilter but emits
+// entries carrying chart metadata and can render templates (see HelmOptions).
+func ScanHelmChartsWithOptions(root string, limits Limits, opts HelmOptions, allow PathAllowFunc, emit EntryFunc) error {
+	h, err := NewHelmHandler(limits, opts, emit, nil)
+	if err != nil {
+		return err
+	}
+	return Discover(root, allow, h)
+}
 
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			if d.IsDir() {
-				return filepath.SkipDir
-			}
-			return nil
-		}
+// NewHelmHandler returns the Discover handler for chart directories,
+// packaged charts (.tgz) and manifests holding stored Helm releases. Each
+// chart and release file is reported in stats, which may be nil. It fails
+// only if the renderer requested by opts cannot be set up.
+func NewHelmHandler(limits Limits, opts HelmOptions, emit EntryFunc, stats *Stats) (Handler, error) {
+	renderer, err := newHelmRenderer(opts)
+	if err != nil {
+		return nil, err
+	}
+	return &helmHandler{limits: limits, renderer: renderer, emit: emit, stats: stats}, nil
+}
 
-		if allow != nil && !allow(rel) {
-			return nil
-		}
+type helmHandler struct {
+	limits   Limits
+	renderer *helmRenderer
+	emit     EntryFunc
+	stats    *Stats
+}
 
-		if !d.IsDir() && strings.HasSuffix(strings.ToLower(rel), ".tgz") {
-			return scanHelmArchive(p, rel, limits, emit)
-		}
+func (h *helmHandler) Match(c *Candidate) bool {
+	if c.IsDir() {
+		_, err := os.Stat(filepath.Join(c.Path, "Chart.yaml"))
+		return err == nil
+	}
+	if strings.HasSuffix(strings.ToLower(c.Rel), ".tgz") {
+		return !isDirectoryChartSubchart(c.Path, c.Rel)
+	}
+	return isHelmReleaseCandidate(c.Rel) && sniffHelmRelease(c.Path)
+}
 
-		if d.IsDir() {
-			chartPath := filepath.Join(p, "Chart.yaml")
-			if _, err := os.Stat(chartPath); err == nil {
-				return scanHelmDirectory(p, rel, limits, emit)
-			}
-		}
+// isDirectoryChartSubchart reports whether a .tgz is a vendored subchart of
+// an unpacked chart (<chart>/charts/<name>.tgz next to <chart>/Chart.yaml),
+// which scanHelmDirectory scans under the chart's virtual path.
+func isDirectoryChartSubchart(p, rel string) bool {
+	if !isHelmSubchartArchive(filepath.ToSlash(rel)) {
+		return false
+	}
+	_, err := os.Stat(filepath.Join(filepath.Dir(filepath.Dir(p)), "Chart.yaml"))
+	return err == nil
+}
 
-		return nil
+func (h *helmHandler) Handle(c *Candidate) error {
+	return scanCandidate(h.stats, h.limits, c.Rel, "helm", func(stats *Stats) (int, int64, error) {
+		var n int
+		var size int64
+		emit := countEntries(h.emit, &n, &size)
+		var err error
+		switch {
+		case (c.IsDir() || strings.HasSuffix(strings.ToLower(c.Rel), ".tgz")) && pastGlobalDeadline(stats, h.limits):
+		case c.IsDir():
+			err = scanHelmDirectory(c.Path, c.Rel, h.limits, h.renderer, emit, stats)
+		case strings.HasSuffix(strings.ToLower(c.Rel), ".tgz"):
+			err = scanHelmArchiveFile(c.Path, c.Rel, h.limits, h.renderer, emit, stats)
+		default:
+			err = scanHelmReleaseFile(c.Path, c.Rel, c.Entry, h.limits, emit, stats)
+		}
+		return n, size, err
 	})
 }
 
-// scanHelmArchive scans a .tgz Helm chart archive
-func scanHelmArchive(archivePath, relPath string, limits Limits, emit func(path string, data []byte)) error {
+// errCorruptGzip is returned for a chart archive that is not valid gzip.
+var errCorruptGzip = errors.New("corrupt gzip")
+
+// scanHelmArchiveFile opens a .tgz Helm chart archive on disk and scans it.
+// Unlike a downloaded chart, a .tgz file that is not valid gzip is skipped
+// as not being a chart.
+func scanHelmArchiveFile(archivePath, relPath string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) error {
 	f, err := os.Open(archivePath)
 	if err != nil {
-		return nil // Skip on error
+		return err
+	}
+	defer safeClose(f)
+	fi, err := f.Stat()
+	if err != nil {
+		return err
+	}
+	err = scanHelmArchive(f, fi.Size(), relPath, limits, renderer, emit, stats)
+	if errors.Is(err, errCorruptGzip) {
+		return nil
+	}
+	return err
+}
+
+// isHelmReleaseCandidate reports whether a file may hold stored Helm releases
+// (manifests or `kubectl get secrets -o yaml` dumps).
+func isHelmReleaseCandidate(rel string) bool {
+	switch strings.ToLower(filepath.Ext(rel)) {
+	case ".yaml", ".yml", ".json":
+		return true
+	}
+	return false
+}
+
+// helmReleaseSniffBytes is how much of a release candidate sniffHelmRelease
+// looks at.
+const helmReleaseSniffBytes = 64 << 10
+
+// helmReleaseMarkerRe matches what a Helm release Secret or ConfigMap shows
+// near its start: its name or type, its owner: helm label, or the gzip
+// header of its data.release value, which kubectl writes ahead of kind and
+// metadata since it sorts keys.
+var helmReleaseMarkerRe = regexp.MustCompile(`sh\.helm\.release\.v1\.|helm\.sh/release\.v1|"?owner"?\s*:\s*"?helm\b|"?release"?\s*:\s*"?(?:H4sI|SDRzSU)`)
+
+// sniffHelmRelease reports whether the first helmReleaseSniffBytes of the
+// file at p look like a Helm release Secret or ConfigMap, so that other YAML
+// and JSON files are not read in full. Files that cannot be read are passed
+// on for their error to be reported.
+func sniffHelmRelease(p string) bool {
+	f, err := os.Open(p)
+	if err != nil {
+		return true
 	}
 	defer safeClose(f)
+	buf := make([]byte, helmReleaseSniffBytes)
+	n, err := io.ReadFull(f, buf)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return true
+	}
+	return helmReleaseMarkerRe.Match(buf[:n])
+}
+
+// scanHelmReleaseFile decodes any Helm release Secrets or ConfigMaps in a
+// manifest file. Files larger than Limits.MaxArchiveBytes are skipped and
+// recorded as aborted by bytes.
+func scanHelmReleaseFile(p, rel string, d fs.DirEntry, limits Limits, emit EntryFunc, stats *Stats) error {
+	info, err := d.Info()
+	if err != nil {
+		return err
+	}
+	if limits.MaxArchiveBytes > 0 && info.Size() > limits.MaxArchiveBytes {
+		stats.add("bytes")
+		return nil
+	}
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return err
+	}
+	if containsHelmRelease(data) && !pastGlobalDeadline(stats, limits) {
+		scanHelmReleases(data, rel, limits, emit, stats)
+	}
+	return nil
+}
+
+// scanHelmArchive scans the gzipped Helm chart archive of size bytes in ra,
+// starting the byte, entry and time budgets that its vendored subcharts share.
+// With a renderer the chart is also loaded and rendered, provided it was read
+// completely and is no larger than Limits.MaxArchiveBytes. Guardrail aborts,
+// corrupt entries and charts that fail to load or render are recorded in
+// stats.
+func scanHelmArchive(ra io.ReaderAt, size int64, relPath string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) error {
+	deadline := time.Time{}
+	if limits.TimeBudget > 0 {
+		deadline = time.Now().Add(limits.TimeBudget)
+	}
+	var decompressed int64
+	var entries int
+	complete, meta, err := scanHelmArchiveWithStats(ra, size, relPath, limits, &decompressed, &entries, 0, deadline, emit, stats)
+	if err != nil || renderer == nil || !complete {
+		return err
+	}
+	if limits.MaxArchiveBytes > 0 && size > limits.MaxArchiveBytes {
+		return nil
+	}
+	chrt, err := loader.LoadArchive(io.NewSectionReader(ra, 0, size))
+	renderChart(renderer, chrt, err, relPath, meta, emit, stats)
+	return nil
+}
 
-	gzr, err := gzip.NewReader(f)
+// scanHelmArchiveWithStats scans a chart archive, or a subchart found in one,
+// against the budgets of the outermost archive. Entries are held until the
+// archive is exhausted so that the chart metadata parsed from the top-level
+// Chart.yaml, which it returns, can be attached to every emitted entry.
+// Vendored subcharts (charts/*.tgz) are scanned recursively under the nested
+// virtual path while depth < Limits.MaxDepth; subcharts are rendered as part
+// of their parent. It reports whether the archive was read to the end.
+func scanHelmArchiveWithStats(ra io.ReaderAt, size int64, relPath string, limits Limits, decompressed *int64, entries *int, depth int, deadline time.Time, emit EntryFunc, stats *Stats) (bool, map[string]string, error) {
+	limits, err := enterArchive(limits, ra, size, *decompressed)
 	if err != nil {
-		return nil // Not a valid gzip
+		stats.ratioExceeded(fmt.Errorf("%s: %w", baseName(relPath), err))
+		return false, nil, nil
+	}
+	gzr, err := gzip.NewReader(io.NewSectionReader(ra, 0, size))
+	if err != nil {
+		return false, nil, fmt.Errorf("%w: %w", errCorruptGzip, err)
 	}
 	defer safeClose(gzr)
 
-	tr := tar.NewReader(gzr)
+	var pending []Entry
+	var meta map[string]string
+	defer func() {
+		for _, e := range pending {
+			e.Metadata = meta
+			emit(e)
+		}
+	}()
+
+	tr := tar.NewReader(limitEntryRatio(gzr, size, limits))
 	for {
+		if r := limitsExceededReason(limits, *decompressed, *entries, depth, deadline); r != "" {
+			stats.add(r)
+			return false, meta, nil
+		}
 		hdr, err := tr.Next()
 		if err == io.EOF {
-			break
+			return true, meta, nil
 		}
 		if err != nil {
-			return nil
+			if errors.Is(err, errCompressionRatio) {
+				stats.ratioExceeded(fmt.Errorf("%s: %w", baseName(relPath), err))
+				return false, meta, nil
+			}
+			err = fmt.Errorf("corrupt tar: %w", err)
+			stats.fail(err)
+			stats.unscannable(relPath, err.Error())
+			return false, meta, nil
 		}
 
 		if hdr.FileInfo().IsDir() {
 			continue
 		}
 
-		name := hdr.Name
-		if shouldScanHelmFile(name) {
-			data, err := io.ReadAll(io.LimitReader(tr, limits.MaxArchiveBytes))
+		name := sanitizeEntryName(hdr.Name)
+		if name == "" {
+			continue
+		}
+		pathChain := relPath + "::" + name
+		if isHelmSubchartArchive(name) {
+			if depth >= limits.MaxDepth {
+				stats.add("depth")
+				continue
+			}
+			blob, err := readAllBounded(tr, limits, decompressed, deadline)
 			if err != nil {
+				readFailed(stats, limits, *decompressed, *entries, depth, deadline, pathChain, name, err)
+				continue
+			}
+			if _, _, err := scanHelmArchiveWithStats(bytes.NewReader(blob), int64(len(blob)), pathChain, limits, decompressed, entries, depth+1, deadline, emit, stats); err != nil {
+				stats.fail(fmt.Errorf("%s: %w", name, err))
+				stats.unscannable(pathChain, err.Error())
+			}
+			continue
+		}
+		if !shouldScanHelmFile(name) {
+			continue
+		}
+		data, err := readAllBounded(tr, limits, decompressed, deadline)
+		if err != nil {
+			readFailed(stats, limits, *decompressed, *entries, depth, deadline, pathChain, name, err)
+			if len(data) == 0 {
 				continue
 			}
-			vpath := relPath + "::" + name
-			emit(vpath, data)
 		}
+		if meta == nil && isTopLevelChartYAML(name) {
+			var chart HelmChart
+			if yaml.Unmarshal(data, &chart) == nil {
+				meta = ExtractChartMetadata(&chart)
+			}
+		}
+		pending = append(pending, Entry{Path: pathChain, Data: data})
+		*entries++
 	}
+}
 
-	return nil
+// isTopLevelChartYAML reports whether an archive entry is the Chart.yaml of
+// the packaged chart itself (e.g. "app/Chart.yaml") rather than a subchart.
+func isTopLevelChartYAML(name string) bool {
+	dir, base := path.Split(name)
+	return strings.EqualFold(base, "Chart.yaml") && strings.Count(strings.Trim(dir, "/"), "/") == 0 && dir != ""
 }
 
-func scanHelmDirectory(chartDir, relPath string, limits Limits, emit func(path string, data []byte)) error {
+// scanHelmDirectory scans the templates, values files, Chart.yaml and
+// vendored subcharts of an unpacked chart, rendering it with a renderer. Files that cannot be read
+// are recorded in stats.
+func scanHelmDirectory(chartDir, relPath string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) error {
+	var meta map[string]string
+	if chart, err := ParseChartYAML(filepath.Join(chartDir, "Chart.yaml")); err == nil {
+		meta = ExtractChartMetadata(chart)
+	}
+	emitFile := func(vpath string, data []byte) {
+		emit(Entry{Path: vpath, Data: data, Metadata: meta})
+	}
+
 	templatesDir := filepath.Join(chartDir, "templates")
 	if info, err := os.Stat(templatesDir); err == nil && info.IsDir() {
-		_ = filepath.WalkDir(templatesDir, func(p string, d fs.DirEntry, err error) error {
-			if err != nil || d.IsDir() {
+		err := filepath.WalkDir(templatesDir, func(p string, d fs.DirEntry, err error) error {
+			if err != nil {
+				stats.fail(err)
+				return nil
+			}
+			if d.IsDir() {
 				return nil
 			}
 			if !strings.HasSuffix(strings.ToLower(p), ".yaml") && !strings.HasSuffix(strings.ToLower(p), ".yml") {
@@ -202,36 +430,113 @@ func scanHelmDirectory(chartDir, relPath string, limits Limits, emit func(path s
 			}
 			data, err := os.ReadFile(p)
 			if err != nil {
+				stats.fail(err)
 				return nil
 			}
 			rel, _ := filepath.Rel(chartDir, p)
 			vpath := relPath + "::" + rel
-			emit(vpath, data)
+			emitFile(vpath, data)
 			return nil
 		})
+		stats.fail(err)
 	}
 
-	for _, valuesFile := range []string{"values.yaml", "values.yml"} {
-		valuesPath := filepath.Join(chartDir, valuesFile)
-		if data, err := os.ReadFile(valuesPath); err == nil {
-			vpath := relPath + "::" + valuesFile
-			emit(vpath, data)
+	// values.yaml plus overlays such as values-prod.yaml and ci/*.yaml
+	var valuesFiles []string
+	for _, pattern := range []string{"values*.yaml", "values*.yml", "ci/*.yaml", "ci/*.yml"} {
+		matches, _ := filepath.Glob(filepath.Join(chartDir, pattern))
+		valuesFiles = append(valuesFiles, matches...)
+	}
+	for _, valuesPath := range valuesFiles {
+		data, err := os.ReadFile(valuesPath)
+		if err != nil {
+			stats.fail(err)
+			continue
 		}
+		rel, _ := filepath.Rel(chartDir, valuesPath)
+		vpath := relPath + "::" + filepath.ToSlash(rel)
+		emitFile(vpath, data)
 	}
 
 	chartYAML := filepath.Join(chartDir, "Chart.yaml")
 	if data, err := os.ReadFile(chartYAML); err == nil {
 		vpath := relPath + "::" + "Chart.yaml"
-		emit(vpath, data)
+		emitFile(vpath, data)
+	} else {
+		stats.fail(err)
+	}
+
+	scanHelmDirectorySubcharts(chartDir, relPath, limits, emit, stats)
+
+	if renderer != nil {
+		chrt, err := loader.LoadDir(chartDir)
+		renderChart(renderer, chrt, err, relPath, meta, emit, stats)
 	}
 
 	return nil
 }
 
+// scanHelmDirectorySubcharts scans the vendored subcharts (charts/*.tgz) of
+// an unpacked chart under relPath::charts/<name>.tgz, sharing one byte,
+// entry and time budget as the subcharts of a chart archive do.
+func scanHelmDirectorySubcharts(chartDir, relPath string, limits Limits, emit EntryFunc, stats *Stats) {
+	matches, _ := filepath.Glob(filepath.Join(chartDir, "charts", "*.tgz"))
+	if len(matches) == 0 {
+		return
+	}
+	deadline := time.Time{}
+	if limits.TimeBudget > 0 {
+		deadline = time.Now().Add(limits.TimeBudget)
+	}
+	var decompressed int64
+	var entries int
+	for _, p := range matches {
+		name := "charts/" + filepath.Base(p)
+		pathChain := relPath + "::" + name
+		if r := limitsExceededReason(limits, decompressed, entries, 0, deadline); r != "" {
+			stats.add(r)
+			return
+		}
+		err := func() error {
+			f, err := os.Open(p)
+			if err != nil {
+				return err
+			}
+			defer safeClose(f)
+			fi, err := f.Stat()
+			if err != nil {
+				return err
+			}
+			_, _, err = scanHelmArchiveWithStats(f, fi.Size(), pathChain, limits, &decompressed, &entries, 0, deadline, emit, stats)
+			return err
+		}()
+		if err != nil {
+			stats.fail(fmt.Errorf("%s: %w", name, err))
+			stats.unscannable(pathChain, err.Error())
+		}
+	}
+}
+
+// renderChart renders a chart loaded with loadErr. A chart that failed to
+// load or render is recorded as a problem and as unscannable in stats; its
+// raw files were still scanned.
+func renderChart(renderer *helmRenderer, chrt *chart.Chart, loadErr error, relPath string, meta map[string]string, emit EntryFunc, stats *Stats) {
+	err := loadErr
+	if err != nil {
+		err = fmt.Errorf("failed to load chart %s: %w", relPath, err)
+	} else {
+		err = renderer.render(chrt, relPath, meta, emit)
+	}
+	if err != nil {
+		stats.fail(err)
+		stats.unscannable(relPath, err.Error())
+	}
+}
+
 func shouldScanHelmFile(name string) bool {
 	lower := strings.ToLower(name)
 
-	if strings.HasSuffix(lower, "/values.yaml") || strings.HasSuffix(lower, "/values.yml") {
+	if isHelmValuesFile(lower) {
 		return true
 	}
 	if strings.HasSuffix(lower, "/chart.yaml") || strings.HasSuffix(lower, "/chart.yml") {
@@ -250,6 +555,36 @@ func shouldScanHelmFile(name string) bool {
 	return false
 }
 
+// isHelmValuesFile reports whether a file in a chart archive, such as
+// "app/values.yaml", holds values: values.yaml and overlays such as
+// values-prod.yaml or values.staging.yml at the chart root, and the
+// ci/*.yaml files used by chart-testing. Unpacked subcharts under charts/
+// have values at their own root.
+func isHelmValuesFile(name string) bool {
+	lower := strings.ToLower(name)
+	if !strings.HasSuffix(lower, ".yaml") && !strings.HasSuffix(lower, ".yml") {
+		return false
+	}
+	parts := strings.Split(strings.Trim(lower, "/"), "/")
+	for len(parts) > 3 && parts[1] == "charts" {
+		parts = parts[2:]
+	}
+	switch len(parts) {
+	case 2:
+		return strings.HasPrefix(parts[1], "values")
+	case 3:
+		return parts[1] == "ci"
+	}
+	return false
+}
+
+// isHelmSubchartArchive reports whether an archive entry is a vendored
+// subchart, e.g. "app/charts/redis-17.0.0.tgz".
+func isHelmSubchartArchive(name string) bool {
+	dir, base := path.Split(strings.ToLower(name))
+	return strings.HasSuffix(base, ".tgz") && path.Base(strings.TrimSuffix(dir, "/")) == "charts"
+}
+
 // ExtractChartMetadata extracts useful metadata from a Helm chart for reporting
 func ExtractChartMetadata(chart *HelmChart) map[string]string {
 	metadata := make(map[string]string)
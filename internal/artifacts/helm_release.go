@@ -0,0 +1,196 @@
+package artifacts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// HelmReleasePrefix is the name prefix of the Secrets and ConfigMaps in which
+// Helm 3 stores releases (sh.helm.release.v1.<release>.v<revision>).
+const HelmReleasePrefix = "sh.helm.release.v1."
+
+// HelmRelease is the subset of a stored Helm release that can carry secrets:
+// the user-supplied values, the rendered manifest and the packaged chart.
+type HelmRelease struct {
+	Name      string                 `json:"name"`
+	Namespace string                 `json:"namespace"`
+	Version   int                    `json:"version"`
+	Config    map[string]interface{} `json:"config"`
+	Manifest  string                 `json:"manifest"`
+	Chart     *HelmReleaseChart      `json:"chart"`
+}
+
+// HelmReleaseChart is the chart embedded in a stored Helm release.
+type HelmReleaseChart struct {
+	Metadata  HelmChart              `json:"metadata"`
+	Values    map[string]interface{} `json:"values"`
+	Templates []HelmReleaseFile      `json:"templates"`
+}
+
+// HelmReleaseFile is a chart file embedded in a stored Helm release. Data is
+// base64 in the JSON encoding, which encoding/json decodes into []byte.
+type HelmReleaseFile struct {
+	Name string `json:"name"`
+	Data []byte `json:"data"`
+}
+
+// helmReleaseObject is the part of a Secret, ConfigMap or List needed to
+// find stored releases in manifests and `kubectl get -o yaml` dumps.
+type helmReleaseObject struct {
+	Kind     string              `yaml:"kind"`
+	Type     string              `yaml:"type"`
+	Metadata K8sMetadata         `yaml:"metadata"`
+	Data     map[string]string   `yaml:"data"`
+	Items    []helmReleaseObject `yaml:"items"`
+}
+
+func (o helmReleaseObject) isHelmRelease() bool {
+	if o.Kind != "Secret" && o.Kind != "ConfigMap" {
+		return false
+	}
+	if _, ok := o.Data["release"]; !ok {
+		return false
+	}
+	return o.Type == "helm.sh/release.v1" || strings.HasPrefix(o.Metadata.Name, HelmReleasePrefix)
+}
+
+// DecodeHelmRelease decodes the data.release value of a Helm release Secret or
+// ConfigMap. Helm stores base64(gzip(json)); Secrets add another layer of
+// base64 when the object is serialized, so base64 is peeled until the gzip
+// header appears. At most limits.MaxArchiveBytes are decompressed.
+func DecodeHelmRelease(encoded string, limits Limits) (*HelmRelease, error) {
+	b := []byte(strings.TrimSpace(encoded))
+	for i := 0; i < 3 && !isGzip(b) && !bytes.HasPrefix(b, []byte("{")); i++ {
+		dec, err := base64.StdEncoding.DecodeString(string(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode helm release: %w", err)
+		}
+		b = dec
+	}
+	if isGzip(b) {
+		gz, err := gzip.NewReader(bytes.NewReader(b))
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress helm release: %w", err)
+		}
+		defer safeClose(gz)
+		var decompressed int64
+		deadline := time.Time{}
+		if limits.TimeBudget > 0 {
+			deadline = time.Now().Add(limits.TimeBudget)
+		}
+		b, err = readAllBounded(gz, limits, &decompressed, deadline)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decompress helm release: %w", err)
+		}
+	}
+	var rel HelmRelease
+	if err := json.Unmarshal(b, &rel); err != nil {
+		return nil, fmt.Errorf("failed to parse helm release: %w", err)
+	}
+	return &rel, nil
+}
+
+func isGzip(b []byte) bool {
+	return len(b) > 2 && b[0] == 0x1f && b[1] == 0x8b
+}
+
+// containsHelmRelease is a cheap pre-check before parsing a file for stored
+// releases.
+func containsHelmRelease(data []byte) bool {
+	return bytes.Contains(data, []byte(HelmReleasePrefix)) || bytes.Contains(data, []byte("helm.sh/release.v1"))
+}
+
+// scanHelmReleases finds Helm release Secrets and ConfigMaps in a YAML or JSON
+// document stream (including `kind: List` dumps) and emits the values,
+// manifest and chart files of each release under
+// relPath::<Kind>/<namespace>/<name>::release/... A release that cannot be
+// decoded is recorded in stats as unscannable.
+func scanHelmReleases(data []byte, relPath string, limits Limits, emit EntryFunc, stats *Stats) {
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var obj helmReleaseObject
+		err := dec.Decode(&obj)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			break // Not YAML/JSON; nothing more to find
+		}
+		for _, o := range append([]helmReleaseObject{obj}, obj.Items...) {
+			if !o.isHelmRelease() {
+				continue
+			}
+			rel, err := DecodeHelmRelease(o.Data["release"], limits)
+			if err != nil {
+				objPath := relPath + "::" + K8sObjectPath(o.Kind, o.Metadata.Namespace, o.Metadata.Name)
+				stats.fail(fmt.Errorf("%s: %w", objPath, err))
+				stats.unscannable(objPath, err.Error())
+				continue
+			}
+			base := relPath + "::" + K8sObjectPath(o.Kind, helmReleaseNamespace(rel, o), o.Metadata.Name) + "::release/"
+			emitHelmRelease(rel, o, base, emit)
+		}
+	}
+}
+
+// helmReleaseNamespace is the namespace of the object holding a release,
+// falling back to the namespace recorded in the release itself.
+func helmReleaseNamespace(rel *HelmRelease, o helmReleaseObject) string {
+	if o.Metadata.Namespace != "" {
+		return o.Metadata.Namespace
+	}
+	return rel.Namespace
+}
+
+// emitHelmRelease emits the values, manifest and chart files of a release
+// under base (which ends in "::release/").
+func emitHelmRelease(rel *HelmRelease, o helmReleaseObject, base string, emit EntryFunc) {
+	ns := helmReleaseNamespace(rel, o)
+	meta := map[string]string{
+		"k8s_kind":              o.Kind,
+		"k8s_name":              o.Metadata.Name,
+		"helm_release":          rel.Name,
+		"helm_release_revision": strconv.Itoa(rel.Version),
+	}
+	if ns != "" {
+		meta["k8s_namespace"] = ns
+	}
+	if rel.Chart != nil {
+		for k, v := range ExtractChartMetadata(&rel.Chart.Metadata) {
+			meta[k] = v
+		}
+	}
+
+	if len(rel.Config) > 0 {
+		if b, err := yaml.Marshal(rel.Config); err == nil {
+			emit(Entry{Path: base + "values.yaml", Data: b, Metadata: meta})
+		}
+	}
+	if strings.TrimSpace(rel.Manifest) != "" {
+		emit(Entry{Path: base + "manifest.yaml", Data: []byte(rel.Manifest), Metadata: meta})
+	}
+	if rel.Chart == nil {
+		return
+	}
+	if len(rel.Chart.Values) > 0 {
+		if b, err := yaml.Marshal(rel.Chart.Values); err == nil {
+			emit(Entry{Path: base + "chart/values.yaml", Data: b, Metadata: meta})
+		}
+	}
+	for _, f := range rel.Chart.Templates {
+		name := sanitizeEntryName(f.Name)
+		if name == "" || len(f.Data) == 0 || looksBinary(f.Data) {
+			continue
+		}
+		emit(Entry{Path: base + "chart/" + name, Data: f.Data, Metadata: meta})
+	}
+}
@@ -0,0 +1,132 @@
+package artifacts
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodeHelmRelease encodes a release the way Helm's storage driver does:
+// base64(gzip(json)).
+func encodeHelmRelease(t *testing.T, rel map[string]interface{}) string {
+	t.Helper()
+	b, err := json.Marshal(rel)
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err = gz.Write(b)
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return base64.StdEncoding.EncodeToString(buf.Bytes())
+}
+
+var testHelmRelease = map[string]interface{}{
+	"name":      "web",
+	"namespace": "prod",
+	"version":   3,
+	"config":    map[string]interface{}{"db": map[string]interface{}{"password": "hunter2-prod"}},
+	"manifest":  "---\nkind: Secret\nstringData:\n  token: abc\n",
+	"chart": map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "web", "version": "1.4.0", "appVersion": "2.1"},
+		"values":   map[string]interface{}{"db": map[string]interface{}{"password": ""}},
+		"templates": []map[string]interface{}{
+			{"name": "templates/secret.yaml", "data": []byte("kind: Secret")},
+		},
+	},
+}
+
+func TestDecodeHelmRelease(t *testing.T) {
+	enc := encodeHelmRelease(t, testHelmRelease)
+	for name, in := range map[string]string{
+		"configmap (single base64)": enc,
+		"secret (double base64)":    base64.StdEncoding.EncodeToString([]byte(enc)),
+	} {
+		t.Run(name, func(t *testing.T) {
+			rel, err := DecodeHelmRelease(in, Limits{})
+			require.NoError(t, err)
+			assert.Equal(t, "web", rel.Name)
+			assert.Equal(t, 3, rel.Version)
+			require.NotNil(t, rel.Chart)
+			assert.Equal(t, "1.4.0", rel.Chart.Metadata.Version)
+			require.Len(t, rel.Chart.Templates, 1)
+			assert.Equal(t, "kind: Secret", string(rel.Chart.Templates[0].Data))
+		})
+	}
+
+	_, err := DecodeHelmRelease("not base64!", Limits{})
+	assert.Error(t, err)
+}
+
+func TestScanHelmCharts_ReleaseSecretDump(t *testing.T) {
+	secretData := base64.StdEncoding.EncodeToString([]byte(encodeHelmRelease(t, testHelmRelease)))
+	dump := `apiVersion: v1
+kind: List
+items:
+- apiVersion: v1
+  kind: Secret
+  type: helm.sh/release.v1
+  metadata:
+    name: sh.helm.release.v1.web.v3
+    namespace: prod
+  data:
+    release: ` + secretData + `
+- apiVersion: v1
+  kind: Secret
+  metadata:
+    name: unrelated
+  data:
+    release: aGVsbG8=
+`
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secrets.yaml"), []byte(dump), 0644))
+
+	got := map[string]Entry{}
+	err := ScanHelmChartsWithOptions(root, Limits{}, HelmOptions{}, nil, func(e Entry) { got[e.Path] = e })
+	require.NoError(t, err)
+
+	base := "secrets.yaml::Secret/prod/sh.helm.release.v1.web.v3::release/"
+	values, ok := got[base+"values.yaml"]
+	require.True(t, ok, "release values missing: %v", entryPaths(got))
+	assert.Equal(t, "db:\n    password: hunter2-prod\n", string(values.Data))
+	assert.Equal(t, "web", values.Metadata["helm_release"])
+	assert.Equal(t, "3", values.Metadata["helm_release_revision"])
+	assert.Equal(t, "prod", values.Metadata["k8s_namespace"])
+	assert.Equal(t, "1.4.0", values.Metadata["chart_version"])
+
+	assert.Contains(t, got, base+"manifest.yaml")
+	assert.Contains(t, got, base+"chart/values.yaml")
+	assert.Equal(t, "kind: Secret", string(got[base+"chart/templates/secret.yaml"].Data))
+	assert.Len(t, got, 4)
+}
+
+func TestScanHelmCharts_UndecodableReleaseIsUnscannable(t *testing.T) {
+	dump := `apiVersion: v1
+kind: Secret
+type: helm.sh/release.v1
+metadata:
+  name: sh.helm.release.v1.web.v3
+  namespace: prod
+data:
+  release: bm90IGEgcmVsZWFzZQ==
+`
+	root := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(root, "secrets.yaml"), []byte(dump), 0644))
+
+	var stats Stats
+	h, err := NewHelmHandler(Limits{}, HelmOptions{}, func(Entry) {}, &stats)
+	require.NoError(t, err)
+	require.NoError(t, Discover(root, nil, h))
+
+	require.Len(t, stats.Unscannable, 1)
+	assert.Equal(t, "secrets.yaml::Secret/prod/sh.helm.release.v1.web.v3", stats.Unscannable[0].Path)
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "secrets.yaml", stats.Artifacts[0].Path)
+	assert.NotEmpty(t, stats.Artifacts[0].Errors)
+}
@@ -0,0 +1,305 @@
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/blang/semver/v4"
+	"github.com/google/go-containerregistry/pkg/authn"
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	yaml "gopkg.in/yaml.v3"
+)
+
+// Media types used by Helm when pushing charts to OCI registries.
+const (
+	HelmChartContentMediaType = "application/vnd.cncf.helm.chart.content.v1.tar+gzip"
+	HelmChartConfigMediaType  = "application/vnd.cncf.helm.config.v1+json"
+)
+
+// helmHTTPClient is used for Helm repository index and chart downloads.
+var helmHTTPClient = &http.Client{Timeout: 60 * time.Second}
+
+// HelmRepoIndex is the subset of a Helm repository index.yaml used for scanning.
+type HelmRepoIndex struct {
+	APIVersion string                           `yaml:"apiVersion"`
+	Entries    map[string][]HelmChartVersionRef `yaml:"entries"`
+}
+
+// HelmChartVersionRef describes one published version of a chart in a Helm repository index.
+type HelmChartVersionRef struct {
+	Name       string   `yaml:"name"`
+	Version    string   `yaml:"version"`
+	AppVersion string   `yaml:"appVersion,omitempty"`
+	URLs       []string `yaml:"urls"`
+	Digest     string   `yaml:"digest,omitempty"`
+}
+
+// ScanHelmChartRef scans a single remote chart. oci:// references are pulled
+// from an OCI registry; http(s):// references are downloaded as .tgz archives.
+// The chart is reported in stats under ref; stats may be nil.
+func ScanHelmChartRef(ref string, limits Limits, opts HelmOptions, emit EntryFunc, stats *Stats) error {
+	renderer, err := newHelmRenderer(opts)
+	if err != nil {
+		return err
+	}
+	switch {
+	case strings.HasPrefix(ref, "oci://"):
+		return scanHelmOCIChart(ref, limits, renderer, emit, stats)
+	case strings.HasPrefix(ref, "http://") || strings.HasPrefix(ref, "https://"):
+		return scanHelmChartURL(ref, limits, renderer, emit, stats)
+	default:
+		return fmt.Errorf("unsupported helm chart reference %q (expected oci:// or http(s)://)", ref)
+	}
+}
+
+// ScanHelmOCIChart pulls a chart artifact such as oci://host/charts/app:1.2.3
+// from an OCI registry and scans its chart layer, spooled to a temporary
+// file, with the Helm archive scanner. Entries use the reference as the virtual path root, which is also
+// the path the chart is reported under in stats; stats may be nil.
+func ScanHelmOCIChart(ref string, limits Limits, opts HelmOptions, emit EntryFunc, stats *Stats) error {
+	renderer, err := newHelmRenderer(opts)
+	if err != nil {
+		return err
+	}
+	return scanHelmOCIChart(ref, limits, renderer, emit, stats)
+}
+
+func scanHelmOCIChart(ref string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) error {
+	return scanArtifact(stats, limits, ref, "helm", func(stats *Stats) (int, int64, error) {
+		r, err := name.ParseReference(strings.TrimPrefix(ref, "oci://"))
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid helm chart reference %q: %w", ref, err)
+		}
+		img, err := remote.Image(r, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to fetch helm chart manifest for %q: %w", ref, err)
+		}
+		layers, err := img.Layers()
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to get layers for %q: %w", ref, err)
+		}
+		for _, layer := range layers {
+			mt, err := layer.MediaType()
+			if err != nil || string(mt) != HelmChartContentMediaType {
+				continue
+			}
+			rc, err := layer.Compressed()
+			if err != nil {
+				return 0, 0, fmt.Errorf("failed to read chart layer for %q: %w", ref, err)
+			}
+			defer safeClose(rc)
+			return scanRemoteHelmArchive(rc, ref, limits, renderer, emit, stats)
+		}
+		return 0, 0, fmt.Errorf("no helm chart content layer found in %q", ref)
+	})
+}
+
+// ScanHelmRepository reads a Helm repository's index.yaml and scans the
+// selected chart versions. Each selector is "name", "name@version" or
+// "name@*"; a bare name selects the latest version. With no selectors the
+// latest version of every chart in the index is scanned.
+//
+// Each chart is reported in stats under its URL; stats may be nil. The
+// repository itself is reported under repoURL only when its index cannot be
+// fetched or a selector or chart URL cannot be resolved.
+func ScanHelmRepository(repoURL string, selectors []string, limits Limits, opts HelmOptions, emit EntryFunc, stats *Stats) error {
+	renderer, err := newHelmRenderer(opts)
+	if err != nil {
+		return err
+	}
+	var chartURLs []string
+	err = scanArtifact(stats, limits, repoURL, "helm", func(*Stats) (int, int64, error) {
+		index, err := FetchHelmRepoIndex(repoURL)
+		if err != nil {
+			return 0, 0, err
+		}
+		versions, err := SelectHelmChartVersions(index, selectors)
+		if err != nil {
+			return 0, 0, err
+		}
+		var errs []error
+		for _, v := range versions {
+			if len(v.URLs) == 0 {
+				continue
+			}
+			chartURL, err := resolveHelmChartURL(repoURL, v.URLs[0])
+			if err != nil {
+				errs = append(errs, err)
+				continue
+			}
+			chartURLs = append(chartURLs, chartURL)
+		}
+		return 0, 0, errors.Join(errs...)
+	})
+	errs := []error{err}
+	for _, chartURL := range chartURLs {
+		if strings.HasPrefix(chartURL, "oci://") {
+			err = scanHelmOCIChart(chartURL, limits, renderer, emit, stats)
+		} else {
+			err = scanHelmChartURL(chartURL, limits, renderer, emit, stats)
+		}
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
+
+// FetchHelmRepoIndex downloads and parses <repoURL>/index.yaml.
+func FetchHelmRepoIndex(repoURL string) (*HelmRepoIndex, error) {
+	indexURL, err := resolveHelmChartURL(repoURL, "index.yaml")
+	if err != nil {
+		return nil, err
+	}
+	resp, err := helmHTTPClient.Get(indexURL)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch helm repository index %s: %w", indexURL, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch helm repository index %s: %s", indexURL, resp.Status)
+	}
+	// Index files for large repositories can be sizeable; cap at 64 MiB.
+	b, err := io.ReadAll(io.LimitReader(resp.Body, 64<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read helm repository index %s: %w", indexURL, err)
+	}
+	var index HelmRepoIndex
+	if err := yaml.Unmarshal(b, &index); err != nil {
+		return nil, fmt.Errorf("failed to parse helm repository index %s: %w", indexURL, err)
+	}
+	return &index, nil
+}
+
+// SelectHelmChartVersions picks chart versions from an index according to
+// selectors (see ScanHelmRepository). Results are ordered by chart name and
+// then by descending version.
+func SelectHelmChartVersions(index *HelmRepoIndex, selectors []string) ([]HelmChartVersionRef, error) {
+	if index == nil {
+		return nil, nil
+	}
+	if len(selectors) == 0 {
+		for chartName := range index.Entries {
+			selectors = append(selectors, chartName)
+		}
+		sort.Strings(selectors)
+	}
+	var out []HelmChartVersionRef
+	for _, sel := range selectors {
+		sel = strings.TrimSpace(sel)
+		if sel == "" {
+			continue
+		}
+		chartName, want, _ := strings.Cut(sel, "@")
+		versions := append([]HelmChartVersionRef(nil), index.Entries[chartName]...)
+		if len(versions) == 0 {
+			return nil, fmt.Errorf("chart %q not found in helm repository index", chartName)
+		}
+		sortHelmVersionsDesc(versions)
+		switch want {
+		case "", "latest":
+			out = append(out, versions[0])
+		case "*":
+			out = append(out, versions...)
+		default:
+			found := false
+			for _, v := range versions {
+				if v.Version == want || strings.TrimPrefix(v.Version, "v") == strings.TrimPrefix(want, "v") {
+					out = append(out, v)
+					found = true
+					break
+				}
+			}
+			if !found {
+				return nil, fmt.Errorf("chart %q has no version %q in helm repository index", chartName, want)
+			}
+		}
+	}
+	return out, nil
+}
+
+func sortHelmVersionsDesc(versions []HelmChartVersionRef) {
+	sort.SliceStable(versions, func(i, j int) bool {
+		vi, ei := semver.ParseTolerant(versions[i].Version)
+		vj, ej := semver.ParseTolerant(versions[j].Version)
+		if ei == nil && ej == nil {
+			return vi.GT(vj)
+		}
+		if (ei == nil) != (ej == nil) {
+			// Valid semver sorts ahead of unparsable versions.
+			return ei == nil
+		}
+		return versions[i].Version > versions[j].Version
+	})
+}
+
+// resolveHelmChartURL resolves a possibly relative chart URL from an index
+// against the repository URL.
+func resolveHelmChartURL(repoURL, ref string) (string, error) {
+	if strings.HasPrefix(ref, "oci://") {
+		return ref, nil
+	}
+	base, err := url.Parse(strings.TrimSuffix(repoURL, "/") + "/")
+	if err != nil {
+		return "", fmt.Errorf("invalid helm repository URL %q: %w", repoURL, err)
+	}
+	u, err := base.Parse(ref)
+	if err != nil {
+		return "", fmt.Errorf("invalid chart URL %q: %w", ref, err)
+	}
+	return u.String(), nil
+}
+
+// scanHelmChartURL downloads the chart archive at chartURL and scans it with
+// chartURL as the virtual path root, reporting it in stats.
+func scanHelmChartURL(chartURL string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) error {
+	return scanArtifact(stats, limits, chartURL, "helm", func(stats *Stats) (int, int64, error) {
+		resp, err := helmHTTPClient.Get(chartURL)
+		if err != nil {
+			return 0, 0, fmt.Errorf("failed to download helm chart %s: %w", chartURL, err)
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			return 0, 0, fmt.Errorf("failed to download helm chart %s: %s", chartURL, resp.Status)
+		}
+		return scanRemoteHelmArchive(resp.Body, chartURL, limits, renderer, emit, stats)
+	})
+}
+
+// scanRemoteHelmArchive downloads a chart archive into a temporary file and
+// scans it, returning the entries emitted and the bytes they carry. The
+// archive scanner reads the gzip stream once and, to render the chart,
+// reads it again from the start, so the download is spooled to disk rather
+// than held in memory. An archive larger than Limits.MaxArchiveBytes is not
+// scanned and is recorded as aborted by bytes.
+func scanRemoteHelmArchive(r io.Reader, vpBase string, limits Limits, renderer *helmRenderer, emit EntryFunc, stats *Stats) (int, int64, error) {
+	if limits.MaxArchiveBytes > 0 {
+		r = io.LimitReader(r, limits.MaxArchiveBytes+1)
+	}
+	f, err := os.CreateTemp("", "redactyl-chart-*.tgz")
+	if err != nil {
+		return 0, 0, err
+	}
+	defer func() {
+		safeClose(f)
+		_ = os.Remove(f.Name())
+	}()
+	written, err := io.Copy(f, r)
+	if err != nil {
+		return 0, 0, fmt.Errorf("failed to download helm chart %s: %w", vpBase, err)
+	}
+	if limits.MaxArchiveBytes > 0 && written > limits.MaxArchiveBytes {
+		stats.add("bytes")
+		return 0, 0, nil
+	}
+	var n int
+	var size int64
+	err = scanHelmArchive(f, written, vpBase, limits, renderer, countEntries(emit, &n, &size), stats)
+	return n, size, err
+}
@@ -0,0 +1,187 @@
+package artifacts
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/static"
+	"github.com/google/go-containerregistry/pkg/v1/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// makeChartTgz packages a minimal chart named chartName at version into .tgz bytes.
+func makeChartTgz(t *testing.T, chartName, version string, files map[string]string) []byte {
+	t.Helper()
+	all := map[string]string{
+		chartName + "/Chart.yaml": "apiVersion: v2\nname: " + chartName + "\nversion: " + version + "\nappVersion: \"2.0\"\n",
+	}
+	for k, v := range files {
+		all[chartName+"/"+k] = v
+	}
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for n, c := range all {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: n, Mode: 0644, Size: int64(len(c))}))
+		_, err := tw.Write([]byte(c))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+func TestScanHelmOCIChart(t *testing.T) {
+	host := newTestRegistry(t)
+	tgz := makeChartTgz(t, "app", "1.2.3", map[string]string{
+		"values.yaml":           "password: hunter2",
+		"templates/secret.yaml": "kind: Secret",
+		"README.md":             "ignored",
+	})
+	img, err := mutate.AppendLayers(empty.Image, static.NewLayer(tgz, types.MediaType(HelmChartContentMediaType)))
+	require.NoError(t, err)
+	img = mutate.ConfigMediaType(img, types.MediaType(HelmChartConfigMediaType))
+	ref, err := name.ParseReference(host + "/charts/app:1.2.3")
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(ref, img))
+
+	// The chart is spooled to a temporary file that is removed afterwards.
+	tmp := t.TempDir()
+	t.Setenv("TMPDIR", tmp)
+
+	chartRef := "oci://" + host + "/charts/app:1.2.3"
+	got := map[string]Entry{}
+	err = ScanHelmChartRef(chartRef, Limits{MaxArchiveBytes: 1 << 20}, HelmOptions{}, func(e Entry) { got[e.Path] = e }, nil)
+	require.NoError(t, err)
+	spooled, err := os.ReadDir(tmp)
+	require.NoError(t, err)
+	assert.Empty(t, spooled)
+
+	require.Contains(t, got, chartRef+"::app/values.yaml")
+	require.Contains(t, got, chartRef+"::app/templates/secret.yaml")
+	assert.NotContains(t, got, chartRef+"::app/README.md")
+	values := got[chartRef+"::app/values.yaml"]
+	assert.Equal(t, "password: hunter2", string(values.Data))
+	assert.Equal(t, "app", values.Metadata["chart_name"])
+	assert.Equal(t, "1.2.3", values.Metadata["chart_version"])
+	assert.Equal(t, "2.0", values.Metadata["app_version"])
+}
+
+func TestScanHelmOCIChart_NoChartLayer(t *testing.T) {
+	host := newTestRegistry(t)
+	pushTestImage(t, host+"/charts/notachart:1", map[string]string{"a.txt": "x"})
+	var stats Stats
+	err := ScanHelmOCIChart("oci://"+host+"/charts/notachart:1", Limits{}, HelmOptions{}, func(Entry) {}, &stats)
+	assert.ErrorContains(t, err, "no helm chart content layer")
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "oci://"+host+"/charts/notachart:1", stats.Artifacts[0].Path)
+	assert.NotEmpty(t, stats.Artifacts[0].Errors)
+}
+
+func newHelmRepoServer(t *testing.T, index string, charts map[string][]byte) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/stable/index.yaml" {
+			_, _ = w.Write([]byte(index))
+			return
+		}
+		if b, ok := charts[r.URL.Path]; ok {
+			_, _ = w.Write(b)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestScanHelmRepository(t *testing.T) {
+	index := `apiVersion: v1
+entries:
+  app:
+    - name: app
+      version: 1.10.0
+      urls: [app-1.10.0.tgz]
+    - name: app
+      version: 1.9.0
+      urls: [charts/app-1.9.0.tgz]
+  db:
+    - name: db
+      version: 0.1.0
+      urls: [db-0.1.0.tgz]
+`
+	charts := map[string][]byte{
+		"/stable/app-1.10.0.tgz":       makeChartTgz(t, "app", "1.10.0", map[string]string{"values.yaml": "v: new"}),
+		"/stable/charts/app-1.9.0.tgz": makeChartTgz(t, "app", "1.9.0", map[string]string{"values.yaml": "v: old"}),
+		"/stable/db-0.1.0.tgz":         makeChartTgz(t, "db", "0.1.0", map[string]string{"values.yaml": "v: db"}),
+	}
+	srv := newHelmRepoServer(t, index, charts)
+	repo := srv.URL + "/stable"
+
+	t.Run("latest of every chart", func(t *testing.T) {
+		var values []string
+		var stats Stats
+		err := ScanHelmRepository(repo, nil, Limits{}, HelmOptions{}, func(e Entry) {
+			if strings.HasSuffix(e.Path, "values.yaml") {
+				values = append(values, e.Metadata["chart_name"]+"@"+e.Metadata["chart_version"]+"="+string(e.Data))
+			}
+		}, &stats)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"app@1.10.0=v: new", "db@0.1.0=v: db"}, values)
+		require.Len(t, stats.Artifacts, 2)
+		assert.Equal(t, repo+"/app-1.10.0.tgz", stats.Artifacts[0].Path)
+		assert.Equal(t, "helm", stats.Artifacts[0].Type)
+		assert.NotZero(t, stats.Artifacts[0].Entries)
+	})
+
+	t.Run("selected version", func(t *testing.T) {
+		var paths []string
+		err := ScanHelmRepository(repo, []string{"app@1.9.0"}, Limits{}, HelmOptions{}, func(e Entry) {
+			paths = append(paths, e.Path)
+		}, nil)
+		require.NoError(t, err)
+		assert.Contains(t, paths, repo+"/charts/app-1.9.0.tgz::app/values.yaml")
+	})
+
+	t.Run("unknown chart", func(t *testing.T) {
+		var stats Stats
+		err := ScanHelmRepository(repo, []string{"nope"}, Limits{}, HelmOptions{}, func(Entry) {}, &stats)
+		assert.ErrorContains(t, err, `chart "nope" not found`)
+		require.Len(t, stats.Artifacts, 1)
+		assert.Equal(t, repo, stats.Artifacts[0].Path)
+		assert.NotEmpty(t, stats.Artifacts[0].Errors)
+	})
+}
+
+func TestScanHelmChartRef_CorruptDownload(t *testing.T) {
+	srv := newHelmRepoServer(t, "", map[string][]byte{"/stable/app-1.0.0.tgz": []byte("<html>not found</html>")})
+	var stats Stats
+	err := ScanHelmChartRef(srv.URL+"/stable/app-1.0.0.tgz", Limits{}, HelmOptions{}, func(Entry) {}, &stats)
+	assert.ErrorContains(t, err, "corrupt gzip: gzip: invalid header")
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, srv.URL+"/stable/app-1.0.0.tgz", stats.Artifacts[0].Path)
+	assert.Contains(t, stats.Artifacts[0].Errors[0], "corrupt gzip")
+}
+
+func TestSelectHelmChartVersions_AllVersions(t *testing.T) {
+	index := &HelmRepoIndex{Entries: map[string][]HelmChartVersionRef{
+		"app": {{Name: "app", Version: "1.2.0"}, {Name: "app", Version: "1.10.0"}, {Name: "app", Version: "dev"}},
+	}}
+	got, err := SelectHelmChartVersions(index, []string{"app@*"})
+	require.NoError(t, err)
+	require.Len(t, got, 3)
+	assert.Equal(t, "1.10.0", got[0].Version)
+	assert.Equal(t, "1.2.0", got[1].Version)
+	assert.Equal(t, "dev", got[2].Version)
+}
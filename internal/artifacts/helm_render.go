@@ -0,0 +1,261 @@
+package artifacts
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+
+	"helm.sh/helm/v3/pkg/chart"
+	"helm.sh/helm/v3/pkg/chartutil"
+	"helm.sh/helm/v3/pkg/engine"
+)
+
+// HelmOptions controls optional processing of Helm charts.
+type HelmOptions struct {
+	// Render runs the Helm template engine over each chart and scans the
+	// rendered manifests in addition to the raw chart files.
+	Render bool
+	// ValuesFiles are merged over the chart's own values when rendering,
+	// later files taking precedence (like `helm template -f`).
+	ValuesFiles []string
+}
+
+// helmValuesLayer is one parsed values file supplied by the user.
+type helmValuesLayer struct {
+	file   string
+	values map[string]interface{}
+}
+
+// helmValueSource records which values file supplied a given values key.
+type helmValueSource struct {
+	key   string
+	value string
+	file  string
+}
+
+// minHelmValueLen is the shortest values entry that is attributed in rendered
+// output; shorter strings ("true", "80", "app") match far too often.
+const minHelmValueLen = 6
+
+// valueMask replaces attributed values in the rendered manifest. It is low
+// entropy so it cannot trigger a detector on its own.
+const valueMask = "********"
+
+// helmRenderer holds the parsed user values for a rendering scan. A nil
+// *helmRenderer disables rendering.
+type helmRenderer struct {
+	layers []helmValuesLayer
+}
+
+// newHelmRenderer reads the user-supplied values files once per scan. It
+// returns nil when opts.Render is false.
+func newHelmRenderer(opts HelmOptions) (*helmRenderer, error) {
+	if !opts.Render {
+		return nil, nil
+	}
+	r := &helmRenderer{}
+	for _, f := range opts.ValuesFiles {
+		vals, err := chartutil.ReadValuesFile(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read helm values file %s: %w", f, err)
+		}
+		r.layers = append(r.layers, helmValuesLayer{file: f, values: vals.AsMap()})
+	}
+	return r, nil
+}
+
+// render renders chrt with its values merged with the user layers and emits
+// the rendered manifests under relPath::rendered/<template>. Any values entry
+// that appears in a manifest, verbatim or base64 encoded, is masked in the
+// manifest and emitted as a separate entry at
+// relPath::rendered/<template>::values.<key> whose metadata names the values
+// file that supplied it. Secrets that remain in the masked manifest come from
+// the templates themselves (literals or _helpers.tpl defaults).
+func (h *helmRenderer) render(chrt *chart.Chart, relPath string, meta map[string]string, emit EntryFunc) error {
+	userVals := map[string]interface{}{}
+	for _, l := range h.layers {
+		userVals = chartutil.MergeTables(copyHelmValues(l.values), userVals)
+	}
+	vals, err := chartutil.ToRenderValues(chrt, userVals, chartutil.ReleaseOptions{
+		Name:      "release-name",
+		Namespace: "default",
+		IsInstall: true,
+	}, chartutil.DefaultCapabilities)
+	if err != nil {
+		return fmt.Errorf("failed to prepare values for chart %s: %w", relPath, err)
+	}
+	// LintMode keeps `required` and `fail` from aborting the render when a
+	// chart expects values that only exist at deploy time.
+	rendered, err := engine.Engine{LintMode: true}.Render(chrt, vals)
+	if err != nil {
+		return fmt.Errorf("failed to render chart %s: %w", relPath, err)
+	}
+
+	sources := helmValueSources(chrt, h.layers)
+	names := make([]string, 0, len(rendered))
+	for n := range rendered {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		out := rendered[n]
+		if strings.TrimSpace(out) == "" || strings.EqualFold(path.Base(n), "NOTES.txt") {
+			continue
+		}
+		vp := relPath + "::rendered/" + n
+		masked, refs := attributeHelmValues(out, sources)
+		for _, r := range refs {
+			m := copyMeta(meta)
+			m["helm_template"] = n
+			m["helm_values_key"] = r.src.key
+			m["helm_values_file"] = r.src.file
+			m["helm_rendered_lines"] = joinInts(r.lines)
+			if r.encoding != "" {
+				m["helm_values_encoding"] = r.encoding
+			}
+			emit(Entry{Path: vp + "::values." + r.src.key, Data: []byte(strings.Join(r.text, "\n")), Metadata: m})
+		}
+		m := copyMeta(meta)
+		m["helm_template"] = n
+		m["helm_rendered"] = "true"
+		emit(Entry{Path: vp, Data: []byte(masked), Metadata: m})
+	}
+	return nil
+}
+
+// helmValueRef is every line of one rendered manifest that contains a given
+// values entry, with the value shown in plain text.
+type helmValueRef struct {
+	src      helmValueSource
+	encoding string
+	lines    []int
+	text     []string
+}
+
+// attributeHelmValues finds values entries in a rendered manifest. It returns
+// the manifest with those values masked and one reference per matched key.
+// Longer values are matched first so a value that contains another is
+// attributed to the more specific key.
+func attributeHelmValues(out string, sources []helmValueSource) (string, []helmValueRef) {
+	lines := strings.Split(out, "\n")
+	var refs []helmValueRef
+	for _, src := range sources {
+		ref := helmValueRef{src: src}
+		for _, enc := range []string{"", "base64"} {
+			needle := src.value
+			if enc == "base64" {
+				needle = base64.StdEncoding.EncodeToString([]byte(src.value))
+			}
+			for i, line := range lines {
+				if !strings.Contains(line, needle) {
+					continue
+				}
+				ref.lines = append(ref.lines, i+1)
+				ref.text = append(ref.text, strings.ReplaceAll(line, needle, src.value))
+				if enc != "" {
+					ref.encoding = enc
+				}
+				lines[i] = strings.ReplaceAll(line, needle, valueMask)
+			}
+		}
+		if len(ref.lines) > 0 {
+			refs = append(refs, ref)
+		}
+	}
+	sort.SliceStable(refs, func(i, j int) bool { return refs[i].lines[0] < refs[j].lines[0] })
+	return strings.Join(lines, "\n"), refs
+}
+
+// helmValueSources flattens the chart's values, its subcharts' values and the
+// user layers into string leaves keyed by dotted path, recording the file that
+// supplied the effective value. Results are ordered longest value first.
+func helmValueSources(chrt *chart.Chart, layers []helmValuesLayer) []helmValueSource {
+	byKey := map[string]helmValueSource{}
+	var addChart func(c *chart.Chart, prefix, dir string)
+	addChart = func(c *chart.Chart, prefix, dir string) {
+		flattenHelmValues(c.Values, prefix, func(k, v string) {
+			byKey[k] = helmValueSource{key: k, value: v, file: dir + "values.yaml"}
+		})
+		for _, dep := range c.Dependencies() {
+			addChart(dep, prefix+dep.Name()+".", dir+"charts/"+dep.Name()+"/")
+		}
+	}
+	// Subchart defaults are added first so the parent's overrides win.
+	for _, dep := range chrt.Dependencies() {
+		addChart(dep, dep.Name()+".", "charts/"+dep.Name()+"/")
+	}
+	flattenHelmValues(chrt.Values, "", func(k, v string) {
+		byKey[k] = helmValueSource{key: k, value: v, file: "values.yaml"}
+	})
+	for _, l := range layers {
+		flattenHelmValues(l.values, "", func(k, v string) {
+			byKey[k] = helmValueSource{key: k, value: v, file: l.file}
+		})
+	}
+
+	out := make([]helmValueSource, 0, len(byKey))
+	for _, s := range byKey {
+		if len(s.value) < minHelmValueLen {
+			continue
+		}
+		out = append(out, s)
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if len(out[i].value) != len(out[j].value) {
+			return len(out[i].value) > len(out[j].value)
+		}
+		return out[i].key < out[j].key
+	})
+	return out
+}
+
+func flattenHelmValues(v interface{}, prefix string, fn func(key, value string)) {
+	switch t := v.(type) {
+	case map[string]interface{}:
+		for k, child := range t {
+			flattenHelmValues(child, prefix+k+".", fn)
+		}
+	case chartutil.Values:
+		flattenHelmValues(map[string]interface{}(t), prefix, fn)
+	case []interface{}:
+		base := strings.TrimSuffix(prefix, ".")
+		for i, child := range t {
+			flattenHelmValues(child, base+"["+strconv.Itoa(i)+"].", fn)
+		}
+	case string:
+		fn(strings.TrimSuffix(prefix, "."), t)
+	}
+}
+
+// copyHelmValues returns a deep copy of a values tree so that merging does
+// not mutate the parsed user layers.
+func copyHelmValues(src map[string]interface{}) map[string]interface{} {
+	dst := make(map[string]interface{}, len(src))
+	for k, v := range src {
+		if m, ok := v.(map[string]interface{}); ok {
+			dst[k] = copyHelmValues(m)
+			continue
+		}
+		dst[k] = v
+	}
+	return dst
+}
+
+func copyMeta(meta map[string]string) map[string]string {
+	out := make(map[string]string, len(meta)+4)
+	for k, v := range meta {
+		out[k] = v
+	}
+	return out
+}
+
+func joinInts(v []int) string {
+	parts := make([]string, len(v))
+	for i, n := range v {
+		parts[i] = strconv.Itoa(n)
+	}
+	return strings.Join(parts, ",")
+}
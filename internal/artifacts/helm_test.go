@@ -2,13 +2,17 @@ package artifacts
 
 import (
 	"archive/tar"
+	"bytes"
 	"compress/gzip"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/varalys/redactyl/internal/types"
 )
 
 func TestIsHelmChart(t *testing.T) {
@@ -245,6 +249,193 @@ func TestScanHelmArchive(t *testing.T) {
 	assert.True(t, foundTemplate, "Expected to scan template from archive")
 }
 
+func TestScanHelmArchive_Subcharts(t *testing.T) {
+	redis := makeChartTgz(t, "redis", "17.0.0", map[string]string{"values.yaml": "auth:\n  password: redis-pass"})
+	app := makeChartTgz(t, "app", "1.0.0", map[string]string{
+		"values.yaml":             "v: app",
+		"charts/redis-17.0.0.tgz": string(redis),
+	})
+
+	got := map[string]Entry{}
+	err := scanHelmArchive(bytes.NewReader(app), int64(len(app)), "app.tgz", Limits{MaxDepth: 2}, nil, func(e Entry) { got[e.Path] = e }, nil)
+	require.NoError(t, err)
+
+	sub, ok := got["app.tgz::app/charts/redis-17.0.0.tgz::redis/values.yaml"]
+	require.True(t, ok, "subchart values missing: %v", entryPaths(got))
+	assert.Equal(t, "auth:\n  password: redis-pass", string(sub.Data))
+	assert.Equal(t, "redis", sub.Metadata["chart_name"])
+	assert.Equal(t, "app", got["app.tgz::app/values.yaml"].Metadata["chart_name"])
+
+	t.Run("depth limit", func(t *testing.T) {
+		got = map[string]Entry{}
+		var stats Stats
+		err := scanHelmArchive(bytes.NewReader(app), int64(len(app)), "app.tgz", Limits{MaxDepth: 0}, nil, func(e Entry) { got[e.Path] = e }, &stats)
+		require.NoError(t, err)
+		assert.Contains(t, got, "app.tgz::app/values.yaml")
+		assert.NotContains(t, got, "app.tgz::app/charts/redis-17.0.0.tgz::redis/values.yaml")
+		assert.Equal(t, 1, stats.AbortedByDepth)
+	})
+}
+
+func TestScanHelmArchive_RecordsProblems(t *testing.T) {
+	// Four subcharts whose values each decompress to 1 MiB.
+	big := strings.Repeat("k: v\n", 200<<10)
+	files := map[string]string{"values.yaml": "a: 1"}
+	for i := 0; i < 4; i++ {
+		files[fmt.Sprintf("charts/sub%d-1.0.0.tgz", i)] = string(makeChartTgz(t, fmt.Sprintf("sub%d", i), "1.0.0", map[string]string{"values.yaml": big}))
+	}
+	umbrella := makeChartTgz(t, "app", "1.0.0", files)
+
+	t.Run("entry limit", func(t *testing.T) {
+		app := makeChartTgz(t, "app", "1.0.0", map[string]string{"values.yaml": "a: 1", "templates/cm.yaml": "b: 2"})
+		var stats Stats
+		require.NoError(t, scanHelmArchive(bytes.NewReader(app), int64(len(app)), "app.tgz", Limits{MaxEntries: 1}, nil, func(Entry) {}, &stats))
+		assert.Equal(t, "entries", stats.aborted)
+	})
+
+	t.Run("subcharts share the byte budget", func(t *testing.T) {
+		var stats Stats
+		var size int
+		limit := 2 * int64(len(big))
+		require.NoError(t, scanHelmArchive(bytes.NewReader(umbrella), int64(len(umbrella)), "app.tgz", Limits{MaxDepth: 2, MaxArchiveBytes: limit}, nil, func(e Entry) { size += len(e.Data) }, &stats))
+		assert.Equal(t, "bytes", stats.aborted)
+		assert.LessOrEqual(t, int64(size), limit)
+	})
+
+	t.Run("subcharts share the ratio budget", func(t *testing.T) {
+		var stats Stats
+		require.NoError(t, scanHelmArchive(bytes.NewReader(umbrella), int64(len(umbrella)), "app.tgz", Limits{MaxDepth: 2, MaxArchiveRatio: 10}, nil, func(Entry) {}, &stats))
+		assert.Equal(t, "ratio", stats.aborted)
+	})
+
+	t.Run("corrupt subchart", func(t *testing.T) {
+		app := makeChartTgz(t, "app", "1.0.0", map[string]string{"values.yaml": "a: 1", "charts/redis-1.0.0.tgz": "not gzip"})
+		var stats Stats
+		require.NoError(t, scanHelmArchive(bytes.NewReader(app), int64(len(app)), "app.tgz", Limits{MaxDepth: 2}, nil, func(Entry) {}, &stats))
+		require.Len(t, stats.Unscannable, 1)
+		assert.Equal(t, "app.tgz::app/charts/redis-1.0.0.tgz", stats.Unscannable[0].Path)
+		assert.Contains(t, stats.Unscannable[0].Reason, "corrupt gzip")
+	})
+
+	t.Run("truncated archive", func(t *testing.T) {
+		var values bytes.Buffer
+		for i := 0; values.Len() < 64<<10; i++ {
+			fmt.Fprintf(&values, "key%d: %x\n", i, i*i*7919)
+		}
+		app := makeChartTgz(t, "app", "1.0.0", map[string]string{"values.yaml": values.String()})
+		var stats Stats
+		truncated := app[:len(app)/2]
+		require.NoError(t, scanHelmArchive(bytes.NewReader(truncated), int64(len(truncated)), "app.tgz", Limits{}, nil, func(Entry) {}, &stats))
+		assert.NotEmpty(t, stats.Unscannable)
+		assert.NotEmpty(t, stats.errs)
+	})
+}
+
+func TestScanHelmArchiveFile_SkipsNonGzip(t *testing.T) {
+	p := filepath.Join(t.TempDir(), "notes.tgz")
+	require.NoError(t, os.WriteFile(p, []byte("not gzip"), 0o600))
+	assert.NoError(t, scanHelmArchiveFile(p, "notes.tgz", Limits{}, nil, func(Entry) {}, nil))
+}
+
+func TestHelmHandler_RecordsProblems(t *testing.T) {
+	root := t.TempDir()
+	chartDir := filepath.Join(root, "app")
+	require.NoError(t, os.MkdirAll(filepath.Join(chartDir, "templates"), 0o755))
+	require.NoError(t, os.WriteFile(filepath.Join(chartDir, "Chart.yaml"), []byte("name: app"), 0o644))
+	require.NoError(t, os.Symlink(filepath.Join(root, "missing"), filepath.Join(chartDir, "templates", "gone.yaml")))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "big.yaml"), []byte("metadata:\n  labels:\n    owner: helm\n"+strings.Repeat("k: v\n", 64)), 0o644))
+	require.NoError(t, os.WriteFile(filepath.Join(root, "plain.yaml"), []byte(strings.Repeat("k: v\n", 64)), 0o644))
+
+	var stats Stats
+	h, err := NewHelmHandler(Limits{MaxArchiveBytes: 128}, HelmOptions{}, func(Entry) {}, &stats)
+	require.NoError(t, err)
+	// The dangling template is also a release candidate that cannot be read.
+	assert.ErrorContains(t, Discover(root, nil, h), "gone.yaml")
+
+	reports := map[string]types.ArtifactReport{}
+	for _, r := range stats.Artifacts {
+		reports[r.Path] = r
+	}
+	require.Contains(t, reports, "app")
+	require.Len(t, reports["app"].Errors, 1)
+	assert.Contains(t, reports["app"].Errors[0], "gone.yaml")
+	assert.Equal(t, "bytes", reports["big.yaml"].Aborted)
+	// Files that do not look like releases are not release candidates.
+	assert.NotContains(t, reports, "plain.yaml")
+
+	assert.Error(t, scanHelmArchiveFile(filepath.Join(root, "missing.tgz"), "missing.tgz", Limits{}, nil, func(Entry) {}, nil))
+}
+
+func TestScanHelmDirectory_ValuesOverlays(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/Chart.yaml":          "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"app/values.yaml":         "a: 1",
+		"app/values-prod.yaml":    "a: 2",
+		"app/ci/test-values.yaml": "a: 3",
+		"app/README.md":           "ignored",
+	})
+
+	var paths []string
+	err := ScanHelmChartsWithFilter(root, Limits{}, nil, func(path string, data []byte) { paths = append(paths, path) })
+	require.NoError(t, err)
+	assert.Contains(t, paths, "app::values.yaml")
+	assert.Contains(t, paths, "app::values-prod.yaml")
+	assert.Contains(t, paths, "app::ci/test-values.yaml")
+	assert.NotContains(t, paths, "app::README.md")
+}
+
+func TestScanHelmDirectory_Subcharts(t *testing.T) {
+	root := t.TempDir()
+	redis := makeChartTgz(t, "redis", "17.0.0", map[string]string{"values.yaml": "auth:\n  password: redis-secret\n"})
+	writeFiles(t, root, map[string]string{
+		"app/Chart.yaml":              "apiVersion: v2\nname: app\nversion: 0.1.0\n",
+		"app/values.yaml":             "a: 1",
+		"app/charts/redis-17.0.0.tgz": string(redis),
+		"vendor/redis-17.0.0.tgz":     string(redis),
+	})
+
+	var stats Stats
+	got := map[string]Entry{}
+	h, err := NewHelmHandler(Limits{MaxDepth: 2}, HelmOptions{}, func(e Entry) { got[e.Path] = e }, &stats)
+	require.NoError(t, err)
+	require.NoError(t, Discover(root, nil, h))
+
+	sub, ok := got["app::charts/redis-17.0.0.tgz::redis/values.yaml"]
+	require.True(t, ok, "subchart values missing: %v", entryPaths(got))
+	assert.Equal(t, "redis", sub.Metadata["chart_name"])
+	// A .tgz outside a chart's charts/ directory is still a chart of its own.
+	assert.Contains(t, got, "vendor/redis-17.0.0.tgz::redis/values.yaml")
+
+	var reported []string
+	for _, r := range stats.Artifacts {
+		reported = append(reported, r.Path)
+	}
+	assert.ElementsMatch(t, []string{"app", "vendor/redis-17.0.0.tgz"}, reported)
+}
+
+func TestSniffHelmRelease(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		want    bool
+	}{
+		"kubectl yaml":      {"apiVersion: v1\ndata:\n  release: SDRzSUFBQUFBQUFBLw==\nkind: Secret\n", true},
+		"kubectl json":      {`{"apiVersion": "v1", "data": {"release": "SDRzSUFBQUFBQUFBLw=="}, "kind": "Secret"}`, true},
+		"configmap release": {"apiVersion: v1\ndata:\n  release: H4sIAAAAAAAA/\nkind: ConfigMap\n", true},
+		"owner label":       {"kind: Secret\nmetadata:\n  labels:\n    owner: helm\n", true},
+		"release name":      {"kind: Secret\nmetadata:\n  name: sh.helm.release.v1.web.v3\n", true},
+		"plain yaml":        {"name: app\nrelease: stable\nowner: helmsman\n", false},
+		"marker too late":   {strings.Repeat("# padding\n", helmReleaseSniffBytes/10+1) + "type: helm.sh/release.v1\n", false},
+	}
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			p := filepath.Join(t.TempDir(), "x.yaml")
+			require.NoError(t, os.WriteFile(p, []byte(tc.content), 0o644))
+			assert.Equal(t, tc.want, sniffHelmRelease(p))
+		})
+	}
+}
+
 func TestShouldScanHelmFile(t *testing.T) {
 	tests := []struct {
 		name     string
@@ -252,6 +443,8 @@ func TestShouldScanHelmFile(t *testing.T) {
 		expected bool
 	}{
 		{"values.yaml", "my-chart/values.yaml", true},
+		{"values overlay", "my-chart/values-prod.yaml", true},
+		{"ci values", "my-chart/ci/test-values.yaml", true},
 		{"Chart.yaml", "my-chart/Chart.yaml", true},
 		{"template YAML", "my-chart/templates/deployment.yaml", true},
 		{"secret template", "my-chart/templates/secret.yaml", true},
@@ -269,6 +462,25 @@ func TestShouldScanHelmFile(t *testing.T) {
 	}
 }
 
+func TestIsHelmValuesFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"app/values.yaml":               true,
+		"app/values.staging.yml":        true,
+		"app/ci/test-values.yaml":       true,
+		"app/ci/default.yaml":           true,
+		"app/templates/values-cm.yaml":  false,
+		"app/docs/values.yaml":          false,
+		"app/templates/ci/job.yaml":     false,
+		"app/values.schema.json":        false,
+		"app/charts/redis/values.yaml":  true,
+		"app/charts/redis/ci/x.yaml":    true,
+		"app/charts/values.yaml":        false,
+		"app/files/ci/pipeline-ci.yaml": false,
+	} {
+		assert.Equal(t, want, isHelmValuesFile(name), name)
+	}
+}
+
 func TestExtractChartMetadata(t *testing.T) {
 	chart := &HelmChart{
 		Name:        "my-app",
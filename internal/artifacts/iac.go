@@ -0,0 +1,108 @@
+package artifacts
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// iacFormat scans one kind of IaC file for ScanIaCWithMetadata. match looks
+// at the path only; scan gets the file contents (bounded by Limits) and
+// returns without emitting when the contents are not of its kind.
+type iacFormat struct {
+	match func(rel string) bool
+	scan  func(rel string, data []byte, emit EntryFunc)
+}
+
+var iacFormats = []iacFormat{
+	{match: isTerraformSourcePath, scan: func(rel string, data []byte, emit EntryFunc) {
+		scanTerraformHCL(rel, data, strings.HasSuffix(strings.ToLower(rel), ".tfvars"), emit)
+	}},
+	{match: hasIaCExt(".json"), scan: func(rel string, data []byte, emit EntryFunc) {
+		switch {
+		case isTerraformPlan(data):
+			scanTerraformPlan(rel, data, emit)
+		case isPulumiCheckpoint(data):
+			scanPulumiCheckpoint(rel, data, emit)
+		}
+	}},
+	{match: hasIaCExt(".yaml", ".yml", ".json", ".template"), scan: scanCloudFormation},
+	{match: isPulumiPath, scan: scanPulumiStackConfig},
+	{match: isAnsiblePath, scan: scanAnsible},
+	{match: isComposePath, scan: scanCompose},
+	{match: isDockerfilePath, scan: scanDockerfile},
+}
+
+// iacFormatsFor returns the formats whose match accepts rel.
+func iacFormatsFor(rel string) []iacFormat {
+	var out []iacFormat
+	for _, h := range iacFormats {
+		if h.match(rel) {
+			out = append(out, h)
+		}
+	}
+	return out
+}
+
+func isTerraformSourcePath(rel string) bool {
+	lower := strings.ToLower(rel)
+	return strings.HasSuffix(lower, ".tf") || strings.HasSuffix(lower, ".tfvars")
+}
+
+func hasIaCExt(exts ...string) func(rel string) bool {
+	return func(rel string) bool {
+		ext := strings.ToLower(filepath.Ext(rel))
+		for _, e := range exts {
+			if ext == e {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+// varReferenceRe matches values that only reference another variable ($VAR,
+// ${VAR}); they carry no secret themselves.
+var varReferenceRe = regexp.MustCompile(`^\$\{?[A-Za-z_][A-Za-z0-9_]*\}?$`)
+
+func isVarReference(v string) bool {
+	return varReferenceRe.MatchString(strings.TrimSpace(v))
+}
+
+// splitQuotedFields splits on whitespace outside single or double quotes.
+func splitQuotedFields(line string) []string {
+	var out []string
+	var cur strings.Builder
+	var quote rune
+	for _, r := range line {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			}
+			cur.WriteRune(r)
+		case r == '"' || r == '\'':
+			quote = r
+			cur.WriteRune(r)
+		case r == ' ' || r == '\t':
+			if cur.Len() > 0 {
+				out = append(out, cur.String())
+				cur.Reset()
+			}
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if cur.Len() > 0 {
+		out = append(out, cur.String())
+	}
+	return out
+}
+
+// unquoteValue strips one pair of matching single or double quotes.
+func unquoteValue(v string) string {
+	if len(v) >= 2 && (v[0] == '"' || v[0] == '\'') && v[len(v)-1] == v[0] {
+		return v[1 : len(v)-1]
+	}
+	return v
+}
@@ -0,0 +1,45 @@
+package artifacts
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanIaCWithMetadata_Handlers(t *testing.T) {
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{
+		"cfn/stack.yaml":                 "AWSTemplateFormatVersion: \"2010-09-09\"\nParameters:\n  DBPassword:\n    NoEcho: true\n    Default: hunter2\n",
+		"infra/Pulumi.dev.yaml":          "config:\n  app:token: tkn-123\n",
+		"ansible/group_vars/all.yml":     "ansible_become_pass: pw\n",
+		"docker-compose.yml":             "services:\n  db:\n    environment:\n      DB_PASSWORD: hunter2\n",
+		"Dockerfile":                     "FROM alpine\nENV API_TOKEN=tkn-456\n",
+		"charts/app/values.yaml":         "password: not-iac\n",
+		"ansible/group_vars/ignored.yml": "ansible_ssh_pass: pw\n",
+	})
+	writeFiles(t, dir, map[string]string{".redactylignore": "ansible/group_vars/ignored.yml\n"})
+
+	got := map[string]Entry{}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 1, TimeBudget: time.Second}
+	require.NoError(t, ScanIaCWithMetadata(dir, lim, nil, func(e Entry) { got[e.Path] = e }))
+
+	assert.ElementsMatch(t, []string{
+		"cfn/stack.yaml::Parameters.DBPassword.Default",
+		"infra/Pulumi.dev.yaml::config.app:token",
+		"ansible/group_vars/all.yml::ansible_become_pass",
+		"docker-compose.yml::services.db.environment.DB_PASSWORD",
+		"Dockerfile::ENV.API_TOKEN",
+	}, entryPaths(got))
+	assert.Equal(t, "db", got["docker-compose.yml::services.db.environment.DB_PASSWORD"].Metadata["compose_service"])
+}
+
+func TestIsVarReference(t *testing.T) {
+	for _, v := range []string{"$DB_PASSWORD", "${DB_PASSWORD}", " $X "} {
+		assert.True(t, isVarReference(v), v)
+	}
+	for _, v := range []string{"hunter2", "${A}-${B}", "pa$$word", "${A:-default}"} {
+		assert.False(t, isVarReference(v), v)
+	}
+}
@@ -2,14 +2,14 @@ package artifacts
 
 import (
 	"bytes"
+	"encoding/base64"
 	"fmt"
 	"io"
-	"io/fs"
 	"os"
 	"path/filepath"
 	"strings"
+	"unicode/utf8"
 
-	"github.com/varalys/redactyl/internal/ignore"
 	yaml "gopkg.in/yaml.v3"
 )
 
@@ -117,41 +117,274 @@ func ScanK8sManifests(root string, limits Limits, emit func(path string, data []
 
 // ScanK8sManifestsWithFilter is like ScanK8sManifests but with an optional path filter
 func ScanK8sManifestsWithFilter(root string, limits Limits, allow PathAllowFunc, emit func(path string, data []byte)) error {
-	ign, _ := ignore.Load(filepath.Join(root, ".redactylignore"))
+	return ScanK8sManifestsWithMetadata(root, limits, allow, func(e Entry) { emit(e.Path, e.Data) })
+}
+
+// ScanK8sManifestsWithMetadata is like ScanK8sManifestsWithFilter but emits
+// entries carrying Kubernetes metadata. Secret values, container env/args and
+// other embedded payloads are scanned one at a time (see
+// ScanK8sResourceValues); the manifest itself is scanned with those values
+// masked.
+func ScanK8sManifestsWithMetadata(root string, limits Limits, allow PathAllowFunc, emit EntryFunc) error {
+	return Discover(root, allow, NewK8sHandler(limits, emit, nil))
+}
+
+// NewK8sHandler returns the Discover handler for Kubernetes manifests. Each
+// manifest holding a resource is reported in stats, which may be nil.
+func NewK8sHandler(limits Limits, emit EntryFunc, stats *Stats) Handler {
+	return &k8sHandler{limits: limits, emit: emit, stats: stats}
+}
+
+type k8sHandler struct {
+	limits Limits
+	emit   EntryFunc
+	stats  *Stats
+}
+
+func (h *k8sHandler) Match(c *Candidate) bool {
+	return !c.IsDir() && isK8sManifestFile(c.Rel)
+}
 
-	return filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+func (h *k8sHandler) Handle(c *Candidate) error {
+	return scanCandidate(h.stats, h.limits, c.Rel, "k8s", func(stats *Stats) (int, int64, error) {
+		data, err := os.ReadFile(c.Path)
 		if err != nil {
-			return nil
+			return 0, 0, err
 		}
+		if !containsK8sResource(data) || pastGlobalDeadline(stats, h.limits) {
+			return 0, 0, nil
+		}
+		var n int
+		var size int64
+		emit := countEntries(h.emit, &n, &size)
+		emit(Entry{Path: c.Rel, Data: ScanK8sResourceValues(data, c.Rel, emit)})
+		return n, int64(len(data)), nil
+	})
+}
+
+// K8sObjectPath formats the virtual path segment for a Kubernetes object:
+// Kind/namespace/name, or Kind/name for objects without a namespace.
+func K8sObjectPath(kind, namespace, name string) string {
+	if namespace == "" {
+		return kind + "/" + name
+	}
+	return kind + "/" + namespace + "/" + name
+}
+
+// ScanK8sResourceValues extracts individually scannable values from the
+// resources in a (possibly multi-document) YAML or JSON manifest and emits
+// each as its own entry under relPath::<Kind>/<namespace>/<name>::<location>:
+//
+//   - Secret data values (base64-decoded; values that do not decode are
+//     emitted as written and binary values are skipped) and stringData values,
+//     at data.<key> and stringData.<key>
+//   - container env values, args and command entries in any pod template (see
+//     scanK8sWorkload)
+//   - the kubectl.kubernetes.io/last-applied-configuration annotation, whose
+//     embedded object is scanned the same way
+//   - inline Helm values and parameters of Argo CD Applications
+//
+// Each entry carries ExtractK8sMetadata fields plus "k8s_location" and, for
+// Secrets, "k8s_secret_key"; Entry.Line points at the value in the manifest.
+//
+// The manifest is returned with those values masked (line numbers are kept)
+// so the same secret is not reported twice. Input that is not valid YAML is
+// returned unchanged.
+func ScanK8sResourceValues(data []byte, relPath string, emit EntryFunc) []byte {
+	return scanK8sResourceValues(data, relPath, 0, emit)
+}
 
-		if d.IsDir() {
-			return nil
+// k8sValueScan holds the state for extracting values from one manifest.
+type k8sValueScan struct {
+	lines  []string
+	masked bool
+	depth  int
+	emit   EntryFunc
+}
+
+// value masks val in the manifest and emits data as an entry at
+// base+location. Binary data is masked but not emitted.
+func (s *k8sValueScan) value(base, location string, meta map[string]string, data []byte, val *yaml.Node, extra map[string]string) {
+	if maskYAMLNode(s.lines, val) {
+		s.masked = true
+	}
+	if looksBinary(data) {
+		return
+	}
+	m := copyMeta(meta)
+	m["k8s_location"] = location
+	for k, v := range extra {
+		m[k] = v
+	}
+	s.emit(Entry{Path: base + location, Data: data, Metadata: m, Line: yamlContentLine(val)})
+}
+
+func scanK8sResourceValues(data []byte, relPath string, depth int, emit EntryFunc) []byte {
+	return scanK8sValues(data, depth, emit, func(res *K8sResource) string {
+		return relPath + "::" + K8sObjectPath(res.Kind, res.Metadata.Namespace, res.Metadata.Name) + "::"
+	})
+}
+
+// scanK8sValues is scanK8sResourceValues with the virtual path prefix of
+// each object's values supplied by base.
+func scanK8sValues(data []byte, depth int, emit EntryFunc, base func(res *K8sResource) string) []byte {
+	s := &k8sValueScan{lines: strings.Split(string(data), "\n"), depth: depth, emit: emit}
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if err := dec.Decode(&doc); err != nil {
+			break // io.EOF or a document we cannot parse
+		}
+		if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+			continue
+		}
+		root := doc.Content[0]
+		var res K8sResource
+		if root.Decode(&res) != nil || res.APIVersion == "" || res.Kind == "" {
+			continue
+		}
+		meta := ExtractK8sMetadata(&res)
+		b := base(&res)
+		if res.Kind == "Secret" {
+			s.secretValues(root, b, meta)
 		}
+		s.workloadValues(root, &res, b, meta)
+		s.lastApplied(root, b, meta)
+	}
+	if !s.masked {
+		return data
+	}
+	return []byte(strings.Join(s.lines, "\n"))
+}
 
-		rel, _ := filepath.Rel(root, p)
-		if ign.Match(rel) {
-			return nil
+func (s *k8sValueScan) secretValues(root *yaml.Node, base string, meta map[string]string) {
+	for _, field := range []string{"data", "stringData"} {
+		values := yamlMappingValue(root, field)
+		if values == nil || values.Kind != yaml.MappingNode {
+			continue
 		}
+		for i := 0; i+1 < len(values.Content); i += 2 {
+			key, val := values.Content[i], values.Content[i+1]
+			if val.Kind != yaml.ScalarNode || val.Value == "" {
+				continue
+			}
+			plain := []byte(val.Value)
+			if field == "data" {
+				if dec, err := base64.StdEncoding.DecodeString(strings.Join(strings.Fields(val.Value), "")); err == nil {
+					plain = dec
+				}
+			}
+			location := field + "." + key.Value
+			s.value(base, location, meta, plain, val, map[string]string{"k8s_secret_key": location})
+		}
+	}
+}
 
-		if allow != nil && !allow(rel) {
-			return nil
+// yamlMappingValue returns the value node for key in a mapping node.
+func yamlMappingValue(m *yaml.Node, key string) *yaml.Node {
+	for i := 0; i+1 < len(m.Content); i += 2 {
+		if m.Content[i].Value == key {
+			return m.Content[i+1]
 		}
+	}
+	return nil
+}
 
-		if !isK8sManifestFile(rel) {
-			return nil
+// maskYAMLNode overwrites a value in lines with asterisks of the same length,
+// so both line and column numbers of the rest of the manifest are kept. Block
+// scalars, mappings and sequences have every content line masked.
+func maskYAMLNode(lines []string, val *yaml.Node) bool {
+	idx := val.Line - 1
+	if idx < 0 || idx >= len(lines) {
+		return false
+	}
+	if val.Kind == yaml.ScalarNode && val.Style&(yaml.LiteralStyle|yaml.FoldedStyle) == 0 {
+		line := lines[idx]
+		col := yamlColumnOffset(line, val.Column)
+		if col < 0 || strings.Contains(val.Value, "\n") {
+			return false // multi-line flow scalars are left as is
 		}
+		end := yamlScalarEnd(line, col, val)
+		lines[idx] = line[:col] + strings.Repeat("*", end-col) + line[end:]
+		return true
+	}
+	first, last := yamlContentLine(val), yamlLastLine(val)
+	for i := first - 1; i < last && i < len(lines); i++ {
+		trimmed := strings.TrimLeft(lines[i], " \t")
+		lines[i] = lines[i][:len(lines[i])-len(trimmed)] + strings.Repeat("*", len(trimmed))
+	}
+	return true
+}
 
-		data, err := os.ReadFile(p)
-		if err != nil {
-			return nil
+// yamlColumnOffset returns the byte offset in line of the 1-based column
+// reported by yaml.v3, which counts characters rather than bytes, or -1 if
+// the line is shorter than that.
+func yamlColumnOffset(line string, column int) int {
+	off := 0
+	for i := 1; i < column; i++ {
+		if off >= len(line) {
+			return -1
 		}
+		_, size := utf8.DecodeRuneInString(line[off:])
+		off += size
+	}
+	return off
+}
 
-		if containsK8sResource(data) {
-			emit(rel, data)
+// yamlScalarEnd returns the offset just past a single-line flow scalar that
+// starts at col, including its quotes.
+func yamlScalarEnd(line string, col int, val *yaml.Node) int {
+	switch {
+	case val.Style&yaml.DoubleQuotedStyle != 0:
+		for i := col + 1; i < len(line); i++ {
+			switch line[i] {
+			case '\\':
+				i++
+			case '"':
+				return i + 1
+			}
 		}
+	case val.Style&yaml.SingleQuotedStyle != 0:
+		for i := col + 1; i < len(line); i++ {
+			if line[i] == '\'' {
+				if i+1 < len(line) && line[i+1] == '\'' {
+					i++
+					continue
+				}
+				return i + 1
+			}
+		}
+	default:
+		if strings.HasPrefix(line[col:], val.Value) {
+			return col + len(val.Value)
+		}
+	}
+	return len(line)
+}
 
-		return nil
-	})
+// yamlContentLine returns the first line holding a node's content: the line
+// below the | or > indicator for block scalars, the node's own line otherwise.
+func yamlContentLine(n *yaml.Node) int {
+	if n.Kind == yaml.ScalarNode && n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		return n.Line + 1
+	}
+	return n.Line
+}
+
+// yamlLastLine returns the last line covered by a node and its children.
+func yamlLastLine(n *yaml.Node) int {
+	last := n.Line
+	if n.Kind == yaml.ScalarNode && n.Style&(yaml.LiteralStyle|yaml.FoldedStyle) != 0 {
+		// Trailing blank lines are not part of the value; interior ones are
+		// counted by the newlines in it.
+		last = n.Line + strings.Count(strings.TrimRight(n.Value, "\n"), "\n") + 1
+	}
+	for _, c := range n.Content {
+		if l := yamlLastLine(c); l > last {
+			last = l
+		}
+	}
+	return last
 }
 
 func isK8sManifestFile(path string) bool {
@@ -163,7 +396,7 @@ func isK8sManifestFile(path string) bool {
 
 	k8sDirs := []string{
 		"k8s/", "kubernetes/", "manifests/", ".k8s/",
-		"deploy/", "deployment/", "kube/",
+		"deploy/", "deployment/", "kube/", "argocd/",
 	}
 	for _, dir := range k8sDirs {
 		if strings.Contains(lower, dir) {
@@ -177,6 +410,7 @@ func isK8sManifestFile(path string) bool {
 		"ingress", "pod", "statefulset", "daemonset",
 		"job", "cronjob", "pvc", "pv", "namespace",
 		"rbac", "role", "clusterrole", "serviceaccount",
+		"application", "rollout",
 	}
 
 	for _, pattern := range k8sPatterns {
@@ -0,0 +1,293 @@
+package artifacts
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v3"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/tools/clientcmd"
+	sigsyaml "sigs.k8s.io/yaml"
+)
+
+// K8sClusterOptions selects the cluster and namespace read by ScanK8sCluster.
+type K8sClusterOptions struct {
+	Kubeconfig string // Kubeconfig file; empty uses $KUBECONFIG, ~/.kube/config or the in-cluster config
+	Context    string // Kubeconfig context; empty uses the current context
+	Namespace  string // Namespace to scan; empty scans all namespaces
+}
+
+// k8sClusterPageSize is the number of objects requested per list call.
+const k8sClusterPageSize = 250
+
+// k8sClusterObject is a typed API object.
+type k8sClusterObject interface {
+	runtime.Object
+	metav1.Object
+}
+
+// k8sClusterKind lists one kind of object, a page at a time.
+type k8sClusterKind struct {
+	gvk      schema.GroupVersionKind
+	resource string
+	// objects whose controller is of a listed kind (pods of a ReplicaSet,
+	// jobs of a CronJob) are skipped because their controller's template is
+	// scanned instead
+	skipOwned bool
+	list      func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error)
+}
+
+var k8sClusterKinds = []k8sClusterKind{
+	{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Secret"}, resource: "secrets",
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.CoreV1().Secrets(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"}, resource: "configmaps",
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.CoreV1().ConfigMaps(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Version: "v1", Kind: "Pod"}, resource: "pods", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.CoreV1().Pods(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, resource: "deployments", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.AppsV1().Deployments(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "ReplicaSet"}, resource: "replicasets", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.AppsV1().ReplicaSets(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "StatefulSet"}, resource: "statefulsets", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.AppsV1().StatefulSets(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "DaemonSet"}, resource: "daemonsets", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.AppsV1().DaemonSets(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "CronJob"}, resource: "cronjobs", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.BatchV1().CronJobs(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+	{gvk: schema.GroupVersionKind{Group: "batch", Version: "v1", Kind: "Job"}, resource: "jobs", skipOwned: true,
+		list: func(ctx context.Context, c kubernetes.Interface, ns string, opts metav1.ListOptions) ([]k8sClusterObject, string, error) {
+			l, err := c.BatchV1().Jobs(ns).List(ctx, opts)
+			if err != nil {
+				return nil, "", err
+			}
+			return k8sClusterItems(l.Items), l.Continue, nil
+		}},
+}
+
+// k8sClusterItems converts the items of a typed list.
+func k8sClusterItems[T any, P interface {
+	*T
+	k8sClusterObject
+}](items []T) []k8sClusterObject {
+	out := make([]k8sClusterObject, len(items))
+	for i := range items {
+		out[i] = P(&items[i])
+	}
+	return out
+}
+
+// k8sClusterControlled reports whether obj is controlled by an object of a
+// kind in k8sClusterKinds. Objects controlled by anything else, such as an
+// operator's custom resource, are the only place their spec is found.
+func k8sClusterControlled(obj metav1.Object) bool {
+	ref := metav1.GetControllerOf(obj)
+	if ref == nil {
+		return false
+	}
+	gv, err := schema.ParseGroupVersion(ref.APIVersion)
+	if err != nil {
+		return false
+	}
+	for _, kind := range k8sClusterKinds {
+		if kind.gvk.Group == gv.Group && kind.gvk.Kind == ref.Kind {
+			return true
+		}
+	}
+	return false
+}
+
+// ScanK8sCluster lists Secrets, ConfigMaps, workloads and Helm release
+// Secrets from a running API server and scans them like manifests on disk
+// (see ScanK8sResourceValues and the Helm release handling of
+// ScanHelmChartsWithOptions). Virtual paths start at the kubeconfig context:
+// cluster:<context>::<namespace>/<Kind>/<name>::data.<key>.
+//
+// Only list calls are made. Kinds the credentials may not list (RBAC
+// forbidden or unauthorized) are skipped and reported in the returned error
+// once the remaining kinds have been scanned. Objects larger than
+// limits.MaxArchiveBytes are skipped and limits.GlobalDeadline bounds the
+// whole scan; both are recorded in stats as aborts. The cluster is reported in stats as cluster:<context>; stats
+// may be nil.
+func ScanK8sCluster(opts K8sClusterOptions, limits Limits, emit EntryFunc, stats *Stats) error {
+	client, name, err := newK8sClusterClient(opts)
+	if err != nil {
+		return err
+	}
+	return scanK8sCluster(client, name, opts.Namespace, limits, emit, stats)
+}
+
+// newK8sClusterClient loads a client for the selected kubeconfig context and
+// returns it with the context name used in virtual paths.
+func newK8sClusterClient(opts K8sClusterOptions) (kubernetes.Interface, string, error) {
+	rules := clientcmd.NewDefaultClientConfigLoadingRules()
+	if opts.Kubeconfig != "" {
+		rules.ExplicitPath = opts.Kubeconfig
+	}
+	cc := clientcmd.NewNonInteractiveDeferredLoadingClientConfig(rules, &clientcmd.ConfigOverrides{CurrentContext: opts.Context})
+	rc, err := cc.ClientConfig()
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to load kubeconfig: %w", err)
+	}
+	name := opts.Context
+	if name == "" {
+		if raw, err := cc.RawConfig(); err == nil {
+			name = raw.CurrentContext
+		}
+	}
+	if name == "" {
+		name = "in-cluster"
+	}
+	client, err := kubernetes.NewForConfig(rc)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create kubernetes client: %w", err)
+	}
+	return client, name, nil
+}
+
+func scanK8sCluster(client kubernetes.Interface, cluster, namespace string, limits Limits, emit EntryFunc, stats *Stats) error {
+	return scanArtifact(stats, limits, "cluster:"+cluster, "k8s-cluster", func(stats *Stats) (int, int64, error) {
+		var n int
+		var size int64
+		err := scanK8sClusterKinds(client, cluster, namespace, limits, countEntries(emit, &n, &size), stats)
+		return n, size, err
+	})
+}
+
+// scanK8sClusterKinds does the work of scanK8sCluster.
+func scanK8sClusterKinds(client kubernetes.Interface, cluster, namespace string, limits Limits, emit EntryFunc, stats *Stats) error {
+	ctx := context.Background()
+	if !limits.GlobalDeadline.IsZero() {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithDeadline(ctx, limits.GlobalDeadline)
+		defer cancel()
+	}
+	prefix := "cluster:" + cluster + "::"
+
+	var errs []error
+	for _, kind := range k8sClusterKinds {
+		opts := metav1.ListOptions{Limit: k8sClusterPageSize}
+		for {
+			objs, next, err := kind.list(ctx, client, namespace, opts)
+			if apierrors.IsForbidden(err) || apierrors.IsUnauthorized(err) {
+				errs = append(errs, fmt.Errorf("cluster:%s: cannot list %s: %w", cluster, kind.resource, err))
+				break
+			}
+			if errors.Is(err, context.DeadlineExceeded) || ctx.Err() != nil {
+				stats.add("time")
+				return errors.Join(errs...)
+			}
+			if err != nil {
+				return errors.Join(append(errs, fmt.Errorf("cluster:%s: failed to list %s: %w", cluster, kind.resource, err))...)
+			}
+			for _, obj := range objs {
+				if kind.skipOwned && k8sClusterControlled(obj) {
+					continue
+				}
+				obj.GetObjectKind().SetGroupVersionKind(kind.gvk)
+				obj.SetManagedFields(nil)
+				scanK8sClusterObject(obj, prefix, cluster, limits, emit, stats)
+			}
+			if next == "" {
+				break
+			}
+			opts.Continue = next
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// scanK8sClusterObject scans one object under prefix<namespace>/<Kind>/<name>.
+// Helm release Secrets and ConfigMaps are decoded instead of scanned as is;
+// a release that cannot be decoded is recorded in stats as unscannable.
+// Objects larger than limits.MaxArchiveBytes are skipped and recorded as
+// aborted by bytes.
+func scanK8sClusterObject(obj k8sClusterObject, prefix, cluster string, limits Limits, emit EntryFunc, stats *Stats) {
+	kind := obj.GetObjectKind().GroupVersionKind().Kind
+	base := prefix + obj.GetNamespace() + "/" + kind + "/" + obj.GetName()
+	data, err := sigsyaml.Marshal(obj)
+	if err != nil {
+		stats.fail(fmt.Errorf("%s: %w", base, err))
+		return
+	}
+	if limits.MaxArchiveBytes > 0 && int64(len(data)) > limits.MaxArchiveBytes {
+		stats.add("bytes")
+		return
+	}
+	emitCluster := func(e Entry) {
+		e.Metadata["k8s_cluster"] = cluster
+		emit(e)
+	}
+
+	var rel helmReleaseObject
+	if containsHelmRelease(data) && yaml.Unmarshal(data, &rel) == nil && rel.isHelmRelease() {
+		release, err := DecodeHelmRelease(rel.Data["release"], limits)
+		if err == nil {
+			emitHelmRelease(release, rel, base+"::release/", emitCluster)
+			return
+		}
+		stats.fail(fmt.Errorf("%s: %w", base, err))
+		stats.unscannable(base, err.Error())
+	}
+
+	masked := scanK8sValues(data, 0, emitCluster, func(*K8sResource) string { return base + "::" })
+	meta := map[string]string{
+		"k8s_cluster":   cluster,
+		"k8s_kind":      kind,
+		"k8s_name":      obj.GetName(),
+		"k8s_namespace": obj.GetNamespace(),
+	}
+	emit(Entry{Path: base, Data: masked, Metadata: meta})
+}
@@ -0,0 +1,215 @@
+package artifacts
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+func TestScanK8sCluster(t *testing.T) {
+	isController := true
+	release := encodeHelmRelease(t, testHelmRelease)
+	client := fake.NewClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "db", Namespace: "prod"},
+			Data:       map[string][]byte{"password": []byte("hunter2-prod-password")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.web.v3", Namespace: "prod"},
+			Type:       "helm.sh/release.v1",
+			Data:       map[string][]byte{"release": []byte(release)},
+		},
+		&appsv1.Deployment{
+			ObjectMeta: metav1.ObjectMeta{Name: "web", Namespace: "prod"},
+			Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "web", Env: []corev1.EnvVar{{Name: "API_KEY", Value: "sk_live_abcdefghijklmnop"}}}},
+			}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: metav1.ObjectMeta{Name: "web-abc", Namespace: "prod", OwnerReferences: []metav1.OwnerReference{
+				{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "web-5d9", Controller: &isController},
+			}},
+			Spec: corev1.PodSpec{Containers: []corev1.Container{{Name: "web", Env: []corev1.EnvVar{{Name: "API_KEY", Value: "sk_live_abcdefghijklmnop"}}}}},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "sh.helm.release.v1.api.v1", Namespace: "prod"},
+			Type:       "helm.sh/release.v1",
+			Data:       map[string][]byte{"release": []byte("not a release")},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "other", Namespace: "dev"},
+			StringData: map[string]string{"token": "abc"},
+		},
+	)
+	client.PrependReactor("list", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, apierrors.NewForbidden(schema.GroupResource{Resource: "configmaps"}, "", errors.New("rbac"))
+	})
+
+	got := map[string]Entry{}
+	var stats Stats
+	err := scanK8sCluster(client, "prod-ctx", "prod", Limits{}, func(e Entry) { got[e.Path] = e }, &stats)
+	assert.ErrorContains(t, err, "cluster:prod-ctx: cannot list configmaps")
+
+	require.Len(t, stats.Artifacts, 1)
+	report := stats.Artifacts[0]
+	assert.Equal(t, "cluster:prod-ctx", report.Path)
+	assert.Equal(t, "k8s-cluster", report.Type)
+	assert.Equal(t, len(got), report.Entries)
+	assert.Len(t, report.Errors, 2)
+	require.Len(t, stats.Unscannable, 1)
+	assert.Equal(t, "cluster:prod-ctx::prod/Secret/sh.helm.release.v1.api.v1", stats.Unscannable[0].Path)
+
+	pw, ok := got["cluster:prod-ctx::prod/Secret/db::data.password"]
+	require.True(t, ok, "secret value missing: %v", entryPaths(got))
+	assert.Equal(t, "hunter2-prod-password", string(pw.Data))
+	assert.Equal(t, "prod-ctx", pw.Metadata["k8s_cluster"])
+	assert.Equal(t, "prod", pw.Metadata["k8s_namespace"])
+
+	secret := got["cluster:prod-ctx::prod/Secret/db"]
+	assert.NotContains(t, string(secret.Data), base64.StdEncoding.EncodeToString([]byte("hunter2-prod-password")))
+	assert.NotContains(t, string(secret.Data), "managedFields")
+
+	env, ok := got["cluster:prod-ctx::prod/Deployment/web::spec.template.spec.containers[0].env[0].value"]
+	require.True(t, ok, "workload env missing: %v", entryPaths(got))
+	assert.Equal(t, "API_KEY=sk_live_abcdefghijklmnop", string(env.Data))
+
+	values, ok := got["cluster:prod-ctx::prod/Secret/sh.helm.release.v1.web.v3::release/values.yaml"]
+	require.True(t, ok, "helm release missing: %v", entryPaths(got))
+	assert.Equal(t, "web", values.Metadata["helm_release"])
+	assert.Equal(t, "prod-ctx", values.Metadata["k8s_cluster"])
+	assert.NotContains(t, got, "cluster:prod-ctx::prod/Secret/sh.helm.release.v1.web.v3")
+
+	// Controller-owned pods are covered by their controller; other namespaces
+	// are not listed.
+	for p := range got {
+		assert.NotContains(t, p, "Pod/web-abc")
+		assert.NotContains(t, p, "dev/")
+	}
+
+	for _, a := range client.Actions() {
+		assert.Equal(t, "list", a.GetVerb())
+	}
+}
+
+func TestScanK8sCluster_ControlledPods(t *testing.T) {
+	isController := true
+	env := []corev1.EnvVar{{Name: "API_KEY", Value: "sk_live_abcdefghijklmnop"}}
+	owned := func(name, apiVersion, kind, owner string) metav1.ObjectMeta {
+		return metav1.ObjectMeta{Name: name, Namespace: "prod", OwnerReferences: []metav1.OwnerReference{
+			{APIVersion: apiVersion, Kind: kind, Name: owner, Controller: &isController},
+		}}
+	}
+	client := fake.NewClientset(
+		&appsv1.ReplicaSet{
+			ObjectMeta: metav1.ObjectMeta{Name: "worker", Namespace: "prod"},
+			Spec: appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "worker", Env: env}},
+			}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: owned("worker-abc", "apps/v1", "ReplicaSet", "worker"),
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "worker", Env: env}}},
+		},
+		&appsv1.ReplicaSet{
+			ObjectMeta: owned("web-5d9", "apps/v1", "Deployment", "web"),
+			Spec: appsv1.ReplicaSetSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+				Containers: []corev1.Container{{Name: "web", Env: env}},
+			}}},
+		},
+		&corev1.Pod{
+			ObjectMeta: owned("canary-xyz", "argoproj.io/v1alpha1", "Rollout", "canary"),
+			Spec:       corev1.PodSpec{Containers: []corev1.Container{{Name: "canary", Env: env}}},
+		},
+	)
+
+	got := map[string]Entry{}
+	require.NoError(t, scanK8sCluster(client, "prod-ctx", "prod", Limits{}, func(e Entry) { got[e.Path] = e }, nil))
+
+	// A bare ReplicaSet is scanned in place of its pods.
+	assert.Contains(t, got, "cluster:prod-ctx::prod/ReplicaSet/worker::spec.template.spec.containers[0].env[0].value")
+	// Pods of a controller that is not listed are scanned themselves.
+	assert.Contains(t, got, "cluster:prod-ctx::prod/Pod/canary-xyz::spec.containers[0].env[0].value")
+	for p := range got {
+		assert.NotContains(t, p, "Pod/worker-abc")
+		assert.NotContains(t, p, "ReplicaSet/web-5d9")
+	}
+}
+
+func TestScanK8sCluster_Aborts(t *testing.T) {
+	client := fake.NewClientset(
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "small", Namespace: "prod"},
+			StringData: map[string]string{"token": "abc"},
+		},
+		&corev1.Secret{
+			ObjectMeta: metav1.ObjectMeta{Name: "large", Namespace: "prod"},
+			Data:       map[string][]byte{"blob": bytes.Repeat([]byte("x"), 4096)},
+		},
+	)
+	client.PrependReactor("list", "configmaps", func(k8stesting.Action) (bool, runtime.Object, error) {
+		return true, nil, fmt.Errorf("list configmaps: %w", context.DeadlineExceeded)
+	})
+
+	got := map[string]Entry{}
+	var stats Stats
+	err := scanK8sCluster(client, "prod-ctx", "prod", Limits{MaxArchiveBytes: 1024}, func(e Entry) { got[e.Path] = e }, &stats)
+	require.NoError(t, err)
+
+	assert.Contains(t, got, "cluster:prod-ctx::prod/Secret/small")
+	assert.NotContains(t, got, "cluster:prod-ctx::prod/Secret/large")
+	assert.Equal(t, 1, stats.AbortedByBytes)
+	assert.Equal(t, 1, stats.AbortedByTime)
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "bytes", stats.Artifacts[0].Aborted)
+
+	// Nothing is listed once the deadline has passed.
+	for _, a := range client.Actions() {
+		assert.NotEqual(t, "pods", a.GetResource().Resource)
+	}
+}
+
+func TestNewK8sClusterClient(t *testing.T) {
+	kubeconfig := filepath.Join(t.TempDir(), "config")
+	require.NoError(t, os.WriteFile(kubeconfig, []byte(`apiVersion: v1
+kind: Config
+current-context: staging
+clusters:
+- name: c
+  cluster: {server: "https://127.0.0.1:6443"}
+users:
+- name: u
+  user: {token: t}
+contexts:
+- name: staging
+  context: {cluster: c, user: u}
+- name: prod
+  context: {cluster: c, user: u}
+`), 0600))
+
+	_, name, err := newK8sClusterClient(K8sClusterOptions{Kubeconfig: kubeconfig})
+	require.NoError(t, err)
+	assert.Equal(t, "staging", name)
+
+	_, name, err = newK8sClusterClient(K8sClusterOptions{Kubeconfig: kubeconfig, Context: "prod"})
+	require.NoError(t, err)
+	assert.Equal(t, "prod", name)
+
+	_, _, err = newK8sClusterClient(K8sClusterOptions{Kubeconfig: kubeconfig, Context: "missing"})
+	assert.ErrorContains(t, err, "failed to load kubeconfig")
+}
@@ -0,0 +1,167 @@
+package artifacts
+
+import (
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// LastAppliedAnnotation is set by `kubectl apply` to the full JSON of the
+// applied object; for Secrets it holds the data in plain base64.
+const LastAppliedAnnotation = "kubectl.kubernetes.io/last-applied-configuration"
+
+// maxK8sNesting bounds how deep last-applied payloads are unpacked.
+const maxK8sNesting = 2
+
+// k8sContainerLists are the pod spec fields that hold containers.
+var k8sContainerLists = map[string]bool{
+	"containers":          true,
+	"initContainers":      true,
+	"ephemeralContainers": true,
+}
+
+// workloadValues walks a resource looking for pod specs and, for Argo CD
+// resources, inline Helm sources. The walk is structural rather than
+// kind-based, so Deployments, CronJobs and CRDs embedding pod templates (Argo
+// Rollouts, Knative services, ...) are all covered. Locations are JSONPath-like,
+// e.g. spec.template.spec.containers[0].env[2].value.
+func (s *k8sValueScan) workloadValues(root *yaml.Node, res *K8sResource, base string, meta map[string]string) {
+	argo := strings.HasPrefix(res.APIVersion, "argoproj.io/")
+	var walk func(n *yaml.Node, location string)
+	walk = func(n *yaml.Node, location string) {
+		switch n.Kind {
+		case yaml.MappingNode:
+			for i := 0; i+1 < len(n.Content); i += 2 {
+				key, val := n.Content[i].Value, n.Content[i+1]
+				child := joinK8sLocation(location, key)
+				switch {
+				case location == "" && (key == "metadata" || key == "data" || key == "stringData" || key == "status"):
+					// Handled elsewhere or not user-authored.
+				case k8sContainerLists[key] && val.Kind == yaml.SequenceNode:
+					s.containerValues(val, child, base, meta)
+				case argo && key == "helm" && val.Kind == yaml.MappingNode:
+					s.argoHelmValues(val, child, base, meta)
+				default:
+					walk(val, child)
+				}
+			}
+		case yaml.SequenceNode:
+			for i, c := range n.Content {
+				walk(c, location+"["+strconv.Itoa(i)+"]")
+			}
+		}
+	}
+	walk(root, "")
+}
+
+func (s *k8sValueScan) containerValues(containers *yaml.Node, location, base string, meta map[string]string) {
+	for i, c := range containers.Content {
+		if c.Kind != yaml.MappingNode {
+			continue
+		}
+		cloc := location + "[" + strconv.Itoa(i) + "]"
+		extra := map[string]string{}
+		if name := yamlMappingValue(c, "name"); name != nil && name.Value != "" {
+			extra["k8s_container"] = name.Value
+		}
+		if env := yamlMappingValue(c, "env"); env != nil && env.Kind == yaml.SequenceNode {
+			for j, e := range env.Content {
+				if e.Kind != yaml.MappingNode {
+					continue
+				}
+				val := yamlMappingValue(e, "value")
+				if val == nil || val.Kind != yaml.ScalarNode || val.Value == "" {
+					continue
+				}
+				name := ""
+				if n := yamlMappingValue(e, "name"); n != nil {
+					name = n.Value
+				}
+				x := copyMeta(extra)
+				x["k8s_env_name"] = name
+				// Prefix the variable name so rules keyed on names like
+				// DB_PASSWORD still have their context.
+				s.value(base, cloc+".env["+strconv.Itoa(j)+"].value", meta, []byte(name+"="+val.Value), val, x)
+			}
+		}
+		for _, field := range []string{"command", "args"} {
+			seq := yamlMappingValue(c, field)
+			if seq == nil || seq.Kind != yaml.SequenceNode {
+				continue
+			}
+			for j, a := range seq.Content {
+				if a.Kind != yaml.ScalarNode || a.Value == "" {
+					continue
+				}
+				s.value(base, cloc+"."+field+"["+strconv.Itoa(j)+"]", meta, []byte(a.Value), a, extra)
+			}
+		}
+	}
+}
+
+// argoHelmValues handles the helm block of an Argo CD Application source:
+// the values string, the valuesObject map and parameters.
+func (s *k8sValueScan) argoHelmValues(helm *yaml.Node, location, base string, meta map[string]string) {
+	if v := yamlMappingValue(helm, "values"); v != nil && v.Kind == yaml.ScalarNode && v.Value != "" {
+		s.value(base, location+".values", meta, []byte(v.Value), v, nil)
+	}
+	if v := yamlMappingValue(helm, "valuesObject"); v != nil && v.Kind == yaml.MappingNode {
+		if b, err := yaml.Marshal(v); err == nil {
+			s.value(base, location+".valuesObject", meta, b, v, nil)
+		}
+	}
+	if params := yamlMappingValue(helm, "parameters"); params != nil && params.Kind == yaml.SequenceNode {
+		for i, p := range params.Content {
+			if p.Kind != yaml.MappingNode {
+				continue
+			}
+			val := yamlMappingValue(p, "value")
+			if val == nil || val.Kind != yaml.ScalarNode || val.Value == "" {
+				continue
+			}
+			name := ""
+			if n := yamlMappingValue(p, "name"); n != nil {
+				name = n.Value
+			}
+			s.value(base, location+".parameters["+strconv.Itoa(i)+"].value", meta, []byte(name+"="+val.Value), val, nil)
+		}
+	}
+}
+
+// lastApplied emits the last-applied-configuration annotation and scans the
+// object embedded in it, so a Secret applied with kubectl is decoded even when
+// the manifest on disk only shows the annotation.
+func (s *k8sValueScan) lastApplied(root *yaml.Node, base string, meta map[string]string) {
+	md := yamlMappingValue(root, "metadata")
+	if md == nil || md.Kind != yaml.MappingNode {
+		return
+	}
+	ann := yamlMappingValue(md, "annotations")
+	if ann == nil || ann.Kind != yaml.MappingNode {
+		return
+	}
+	val := yamlMappingValue(ann, LastAppliedAnnotation)
+	if val == nil || val.Kind != yaml.ScalarNode || strings.TrimSpace(val.Value) == "" {
+		return
+	}
+	location := "metadata.annotations[" + LastAppliedAnnotation + "]"
+	payload := []byte(val.Value)
+	if s.depth < maxK8sNesting {
+		first := yamlContentLine(val)
+		payload = scanK8sResourceValues(payload, base+location, s.depth+1, func(e Entry) {
+			if e.Line > 0 {
+				e.Line += first - 1
+			}
+			s.emit(e)
+		})
+	}
+	s.value(base, location, meta, payload, val, nil)
+}
+
+func joinK8sLocation(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
@@ -0,0 +1,469 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/subtle"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+
+	"golang.org/x/crypto/ssh"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// DetectorCredentialStore reports a binary or otherwise opaque credential
+// store: a PKCS#12 file, Java keystore, KeePass database, PuTTY key or
+// DER-encoded private key.
+const DetectorCredentialStore = "credential-store"
+
+// defaultStorePasswords are tried, after the empty password, against
+// keystores whose password can be checked offline.
+var defaultStorePasswords = []string{"changeit", "changeme", "password", "secret"}
+
+var (
+	jksMagic   = []byte{0xfe, 0xed, 0xfe, 0xed}
+	jceksMagic = []byte{0xce, 0xce, 0xce, 0xce}
+	kdbxMagic  = []byte{0x03, 0xd9, 0xa2, 0x9a}
+	ppkMagic   = []byte("PuTTY-User-Key-File-")
+)
+
+// pkcs7Data is the DER-encoded OID of PKCS#7 data (1.2.840.113549.1.7.1),
+// the content type of the AuthenticatedSafe in a PKCS#12 file.
+var pkcs7Data = []byte{0x06, 0x09, 0x2a, 0x86, 0x48, 0x86, 0xf7, 0x0d, 0x01, 0x07, 0x01}
+
+// credentialStore is what could be learned about a credential store
+// without its password.
+type credentialStore struct {
+	typ      string // e.g. "JKS keystore"
+	keyType  string // e.g. "RSA 2048"; "" if unknown
+	keys     int    // private and secret keys held
+	entries  int
+	password string // "empty", "default", "none" (unencrypted) or "protected"
+	// defaultPassword is the well-known password that opened the store.
+	defaultPassword string
+	cert            *x509.Certificate
+}
+
+// InspectCredentialStore recognises the credential store formats that the
+// text scanners cannot look into by their magic bytes and returns a
+// DetectorCredentialStore entry for data at rel describing the store: its
+// type, key type, whether it is protected by an empty or well-known default
+// password and the subject and expiry of its certificate. Stores holding
+// keys that are unencrypted or open with such a password are reported with
+// high confidence; password-protected and certificate-only stores with low
+// confidence.
+func InspectCredentialStore(rel string, data []byte) (Entry, bool) {
+	var s *credentialStore
+	switch {
+	case bytes.HasPrefix(data, jksMagic):
+		s = inspectJKS("JKS keystore", data)
+	case bytes.HasPrefix(data, jceksMagic):
+		s = inspectJKS("JCEKS keystore", data)
+	case bytes.HasPrefix(data, kdbxMagic):
+		s = inspectKeePass(data)
+	case bytes.HasPrefix(data, ppkMagic):
+		s = inspectPPK(data)
+	case isPKCS12(rel, data):
+		s = inspectPKCS12(data)
+	case len(data) > 0 && data[0] == 0x30:
+		s = inspectDERKey(data)
+	}
+	if s == nil {
+		return Entry{}, false
+	}
+	return s.entry(rel), true
+}
+
+func (s *credentialStore) entry(rel string) Entry {
+	meta := map[string]string{
+		"store_type": s.typ,
+		"password":   s.password,
+	}
+	desc := []string{s.typ}
+	if s.keyType != "" {
+		meta["key_type"] = s.keyType
+		desc = append(desc, s.keyType+" key")
+	}
+	if s.entries > 0 {
+		meta["entries"] = fmt.Sprint(s.entries)
+	}
+	switch s.password {
+	case "empty":
+		desc = append(desc, "empty password")
+	case "default":
+		meta["default_password"] = s.defaultPassword
+		desc = append(desc, "default password "+s.defaultPassword)
+	case "none":
+		desc = append(desc, "not encrypted")
+	default:
+		desc = append(desc, "password protected")
+	}
+	if s.cert != nil {
+		for k, v := range CertificateMetadata(s.cert) {
+			meta[k] = v
+		}
+	}
+	confidence := 0.6
+	switch {
+	case s.keys == 0:
+		confidence = 0.3
+	case s.password != "protected":
+		confidence = 0.95
+	}
+	return Entry{
+		Path:       rel,
+		Data:       []byte(strings.Join(desc, ", ")),
+		Metadata:   meta,
+		Detector:   DetectorCredentialStore,
+		Confidence: confidence,
+	}
+}
+
+// openedWith records that the store's password is pw.
+func (s *credentialStore) openedWith(pw string) {
+	if pw == "" {
+		s.password = "empty"
+		return
+	}
+	s.password, s.defaultPassword = "default", pw
+}
+
+// inspectJKS reads a JKS or JCEKS keystore. Certificates are stored in
+// plaintext; the password is checked against the keyed SHA-1 digest that
+// ends the file.
+func inspectJKS(typ string, b []byte) *credentialStore {
+	s := &credentialStore{typ: typ, password: "protected"}
+	r := &jksReader{b: b[len(jksMagic):]}
+	version := r.u32()
+	count := r.u32()
+	s.entries = count
+	var first *x509.Certificate
+	for i := 0; i < count && !r.bad; i++ {
+		tag := r.u32()
+		r.next(r.u16()) // alias
+		r.next(8)       // creation time
+		switch tag {
+		case 1: // private key followed by its certificate chain
+			r.next(r.u32())
+			chain := r.u32()
+			for j := 0; j < chain && !r.bad; j++ {
+				if c := r.cert(version); j == 0 && c != nil && s.keys == 0 {
+					s.cert = c
+				}
+			}
+			s.keys++
+		case 2: // trusted certificate
+			if c := r.cert(version); first == nil {
+				first = c
+			}
+		case 3: // JCEKS secret key, a serialized Java object of unknown length
+			s.keys++
+			r.bad = true
+		default:
+			r.bad = true
+		}
+	}
+	if s.cert == nil {
+		s.cert = first
+	}
+	if s.cert != nil {
+		s.keyType = keyType(s.cert.PublicKey)
+	}
+	if len(b) > 32 {
+		data, digest := b[:len(b)-sha1.Size], b[len(b)-sha1.Size:]
+		for _, pw := range append([]string{""}, defaultStorePasswords...) {
+			if subtle.ConstantTimeCompare(jksDigest(pw, data), digest) == 1 {
+				s.openedWith(pw)
+				break
+			}
+		}
+	}
+	return s
+}
+
+// jksDigest is the integrity digest of a JKS or JCEKS keystore: SHA-1 over
+// the UTF-16BE password, the string "Mighty Aphrodite" and the keystore.
+func jksDigest(password string, data []byte) []byte {
+	h := sha1.New()
+	for _, c := range password {
+		_, _ = h.Write([]byte{byte(c >> 8), byte(c)})
+	}
+	_, _ = h.Write([]byte("Mighty Aphrodite"))
+	_, _ = h.Write(data)
+	return h.Sum(nil)
+}
+
+// jksReader reads the big-endian fields of a Java keystore; once a read
+// runs past the end, bad is set and further reads return zero values.
+type jksReader struct {
+	b   []byte
+	bad bool
+}
+
+func (r *jksReader) next(n int) []byte {
+	if r.bad || n < 0 || n > len(r.b) {
+		r.bad = true
+		return nil
+	}
+	p := r.b[:n]
+	r.b = r.b[n:]
+	return p
+}
+
+func (r *jksReader) u16() int {
+	if p := r.next(2); p != nil {
+		return int(binary.BigEndian.Uint16(p))
+	}
+	return 0
+}
+
+func (r *jksReader) u32() int {
+	if p := r.next(4); p != nil {
+		return int(binary.BigEndian.Uint32(p))
+	}
+	return 0
+}
+
+// cert reads a certificate, which version 2 keystores precede with its type.
+func (r *jksReader) cert(version int) *x509.Certificate {
+	if version == 2 {
+		r.next(r.u16())
+	}
+	der := r.next(r.u32())
+	if der == nil {
+		return nil
+	}
+	c, err := x509.ParseCertificate(der)
+	if err != nil {
+		return nil
+	}
+	return c
+}
+
+// maxPKCS12Iterations caps the MAC iteration count of PKCS#12 files that
+// passwords are tried against. The count is set by the file and each trial
+// derives a key with that many rounds.
+const maxPKCS12Iterations = 100000
+
+// oidPBMAC1 identifies a PBMAC1 (RFC 9579) MAC, whose iteration count is in
+// its PBKDF2 parameters rather than in MacData.
+var oidPBMAC1 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 14}
+
+// pfxHeader is the part of a PKCS#12 PFX that holds the MAC parameters.
+type pfxHeader struct {
+	Version  int
+	AuthSafe asn1.RawValue
+	MacData  struct {
+		Mac struct {
+			Algorithm pkix.AlgorithmIdentifier
+			Digest    []byte
+		}
+		MacSalt    []byte
+		Iterations int `asn1:"optional,default:1"`
+	} `asn1:"optional"`
+}
+
+// pkcs12MacIterations returns the iteration count of the MAC of a PKCS#12
+// file, or false if the file cannot be parsed.
+func pkcs12MacIterations(b []byte) (int, bool) {
+	var pfx pfxHeader
+	if _, err := asn1.Unmarshal(b, &pfx); err != nil {
+		return 0, false
+	}
+	alg := pfx.MacData.Mac.Algorithm
+	if !alg.Algorithm.Equal(oidPBMAC1) {
+		return pfx.MacData.Iterations, true
+	}
+	var params struct {
+		Kdf    pkix.AlgorithmIdentifier
+		MacAlg pkix.AlgorithmIdentifier
+	}
+	var kdf struct {
+		Salt       asn1.RawValue
+		Iterations int
+	}
+	if _, err := asn1.Unmarshal(alg.Parameters.FullBytes, &params); err != nil {
+		return 0, false
+	}
+	if _, err := asn1.Unmarshal(params.Kdf.Parameters.FullBytes, &kdf); err != nil {
+		return 0, false
+	}
+	return kdf.Iterations, true
+}
+
+// inspectPKCS12 tries to open a PKCS#12 file with the empty and default
+// passwords, unless its MAC takes more than maxPKCS12Iterations rounds to
+// check.
+func inspectPKCS12(b []byte) *credentialStore {
+	s := &credentialStore{typ: "PKCS#12 keystore", password: "protected"}
+	if n, ok := pkcs12MacIterations(b); !ok || n > maxPKCS12Iterations {
+		s.keys = 1
+		return s
+	}
+	for _, pw := range append([]string{""}, defaultStorePasswords...) {
+		key, cert, cas, err := pkcs12.DecodeChain(b, pw)
+		if errors.Is(err, pkcs12.ErrIncorrectPassword) {
+			continue
+		}
+		if err == nil {
+			s.openedWith(pw)
+			s.keys, s.entries, s.cert = 1, 2+len(cas), cert
+			if signer, ok := key.(crypto.Signer); ok {
+				s.keyType = keyType(signer.Public())
+			}
+			return s
+		}
+		// No single private key: try it as a trust store.
+		certs, err := pkcs12.DecodeTrustStore(b, pw)
+		if err != nil {
+			break
+		}
+		s.openedWith(pw)
+		s.entries = len(certs)
+		if len(certs) > 0 {
+			s.cert = certs[0]
+		}
+		return s
+	}
+	// Without the password the contents are unknown; assume a key.
+	s.keys = 1
+	return s
+}
+
+// isPKCS12 reports whether b looks like a PKCS#12 file: a DER SEQUENCE
+// starting with version 3 and followed by PKCS#7 data. Files named .p12 or
+// .pfx only need to be a DER SEQUENCE.
+func isPKCS12(name string, b []byte) bool {
+	if len(b) < 4 || b[0] != 0x30 {
+		return false
+	}
+	switch strings.ToLower(path.Ext(name)) {
+	case ".p12", ".pfx":
+		return true
+	}
+	hdr := 2
+	if b[1] > 0x80 {
+		hdr += int(b[1] & 0x7f)
+	}
+	if len(b) < hdr+3 || !bytes.Equal(b[hdr:hdr+3], []byte{0x02, 0x01, 0x03}) {
+		return false
+	}
+	return bytes.Contains(b[hdr+3:min(len(b), hdr+32)], pkcs7Data)
+}
+
+// inspectKeePass recognises a KeePass database. Its key derivation is too
+// expensive to try passwords against, so it is always reported as protected.
+func inspectKeePass(b []byte) *credentialStore {
+	s := &credentialStore{typ: "KeePass database", password: "protected", keys: 1}
+	switch {
+	case len(b) >= 12 && bytes.Equal(b[4:8], []byte{0x67, 0xfb, 0x4b, 0xb5}):
+		s.typ = fmt.Sprintf("KeePass database (KDBX %d)", binary.LittleEndian.Uint16(b[10:12]))
+	case len(b) >= 8 && bytes.Equal(b[4:8], []byte{0x65, 0xfb, 0x4b, 0xb5}):
+		s.typ = "KeePass database (KDB)"
+	default:
+		return nil
+	}
+	return s
+}
+
+// inspectPPK reads the header of a PuTTY private key file.
+func inspectPPK(b []byte) *credentialStore {
+	s := &credentialStore{typ: "PuTTY private key", password: "protected", keys: 1, entries: 1}
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	var public strings.Builder
+	lines := 0
+	for sc.Scan() {
+		k, v, ok := strings.Cut(sc.Text(), ": ")
+		switch {
+		case lines > 0:
+			public.WriteString(strings.TrimSpace(sc.Text()))
+			lines--
+			continue
+		case !ok:
+			continue
+		case strings.HasPrefix(k, string(ppkMagic)):
+			s.typ = "PuTTY private key (v" + strings.TrimPrefix(k, string(ppkMagic)) + ")"
+			s.keyType = v
+		case k == "Encryption" && v == "none":
+			s.password = "none"
+		case k == "Public-Lines":
+			_, _ = fmt.Sscan(v, &lines)
+		}
+	}
+	if blob, err := base64.StdEncoding.DecodeString(public.String()); err == nil {
+		if pub, err := ssh.ParsePublicKey(blob); err == nil {
+			if cpk, ok := pub.(ssh.CryptoPublicKey); ok {
+				if t := keyType(cpk.CryptoPublicKey()); t != "" {
+					s.keyType = t
+				}
+			}
+		}
+	}
+	return s
+}
+
+// encryptedPrivateKeyInfo is a PKCS#8 EncryptedPrivateKeyInfo.
+type encryptedPrivateKeyInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Data      []byte
+}
+
+var (
+	oidPBES2     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPKCS12PBE = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 1}
+)
+
+// inspectDERKey recognises a DER-encoded private key: unencrypted PKCS#8,
+// PKCS#1 RSA or SEC 1 EC keys, and password-encrypted PKCS#8 keys.
+func inspectDERKey(b []byte) *credentialStore {
+	s := &credentialStore{password: "none", keys: 1, entries: 1}
+	var key any
+	var err error
+	if key, err = x509.ParsePKCS8PrivateKey(b); err == nil {
+		s.typ = "DER private key (PKCS#8)"
+	} else if key, err = x509.ParsePKCS1PrivateKey(b); err == nil {
+		s.typ = "DER private key (PKCS#1)"
+	} else if key, err = x509.ParseECPrivateKey(b); err == nil {
+		s.typ = "DER private key (SEC 1)"
+	} else {
+		var info encryptedPrivateKeyInfo
+		rest, err := asn1.Unmarshal(b, &info)
+		algo := info.Algorithm.Algorithm
+		if err != nil || len(rest) > 0 || len(info.Data) == 0 ||
+			!(algo.Equal(oidPBES2) || len(algo) == len(oidPKCS12PBE)+1 && algo[:len(oidPKCS12PBE)].Equal(oidPKCS12PBE)) {
+			return nil
+		}
+		s.typ, s.password = "DER private key (encrypted PKCS#8)", "protected"
+		return s
+	}
+	if signer, ok := key.(crypto.Signer); ok {
+		s.keyType = keyType(signer.Public())
+	}
+	return s
+}
+
+// keyType describes a public key by algorithm and size, e.g. "RSA 2048" or
+// "ECDSA P-256".
+func keyType(pub any) string {
+	switch k := pub.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA %d", k.N.BitLen())
+	case *ecdsa.PublicKey:
+		return "ECDSA " + k.Curve.Params().Name
+	case ed25519.PublicKey:
+		return "Ed25519"
+	}
+	return ""
+}
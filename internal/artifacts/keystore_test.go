@@ -0,0 +1,237 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"math/big"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ssh"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+var testNotAfter = time.Date(2031, 1, 2, 3, 4, 5, 0, time.UTC)
+
+// selfSigned returns an ECDSA P-256 key and a self-signed certificate for cn.
+func selfSigned(t *testing.T, cn string) (*ecdsa.PrivateKey, *x509.Certificate) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: cn},
+		NotBefore:    testNotAfter.AddDate(-1, 0, 0),
+		NotAfter:     testNotAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	return key, cert
+}
+
+// jksBytes builds a version 2 keystore with magic holding one private key
+// entry with cert as its chain (if key is set) or one trusted certificate
+// entry, sealed with password.
+func jksBytes(magic []byte, password string, key bool, cert *x509.Certificate) []byte {
+	var b bytes.Buffer
+	u16 := func(n int) { _ = binary.Write(&b, binary.BigEndian, uint16(n)) }
+	u32 := func(n int) { _ = binary.Write(&b, binary.BigEndian, uint32(n)) }
+	writeCert := func() {
+		u16(5)
+		b.WriteString("X.509")
+		u32(len(cert.Raw))
+		b.Write(cert.Raw)
+	}
+	b.Write(magic)
+	u32(2)
+	u32(1)
+	if key {
+		u32(1)
+	} else {
+		u32(2)
+	}
+	u16(6)
+	b.WriteString("server")
+	b.Write(make([]byte, 8))
+	if key {
+		u32(16)
+		b.Write(bytes.Repeat([]byte{0xaa}, 16)) // stands in for the protected key
+		u32(1)
+	}
+	writeCert()
+	b.Write(jksDigest(password, b.Bytes()))
+	return b.Bytes()
+}
+
+func inspect(t *testing.T, rel string, data []byte) Entry {
+	t.Helper()
+	e, ok := InspectCredentialStore(rel, data)
+	require.True(t, ok, rel)
+	assert.Equal(t, DetectorCredentialStore, e.Detector)
+	assert.Equal(t, rel, e.Path)
+	return e
+}
+
+func TestInspectCredentialStore_JKS(t *testing.T) {
+	_, cert := selfSigned(t, "app.example.com")
+
+	e := inspect(t, "conf/keystore.jks", jksBytes(jksMagic, "changeit", true, cert))
+	assert.Equal(t, map[string]string{
+		"store_type":       "JKS keystore",
+		"key_type":         "ECDSA P-256",
+		"password":         "default",
+		"default_password": "changeit",
+		"entries":          "1",
+		"cert_subject":     "CN=app.example.com",
+		"cert_issuer":      "CN=app.example.com",
+		"cert_not_after":   "2031-01-02T03:04:05Z",
+	}, e.Metadata)
+	assert.Equal(t, "JKS keystore, ECDSA P-256 key, default password changeit", string(e.Data))
+	assert.Equal(t, 0.95, e.Confidence)
+
+	e = inspect(t, "keystore.jceks", jksBytes(jceksMagic, "", true, cert))
+	assert.Equal(t, "JCEKS keystore", e.Metadata["store_type"])
+	assert.Equal(t, "empty", e.Metadata["password"])
+
+	e = inspect(t, "keystore.jks", jksBytes(jksMagic, "s3cr3t-Pa55", true, cert))
+	assert.Equal(t, "protected", e.Metadata["password"])
+	assert.Equal(t, "CN=app.example.com", e.Metadata["cert_subject"], "certificates are readable without the password")
+	assert.Equal(t, 0.6, e.Confidence)
+
+	e = inspect(t, "cacerts", jksBytes(jksMagic, "changeit", false, cert))
+	assert.Equal(t, "default", e.Metadata["password"])
+	assert.Equal(t, 0.3, e.Confidence, "trust stores hold no keys")
+}
+
+func TestInspectCredentialStore_PKCS12(t *testing.T) {
+	key, cert := selfSigned(t, "client")
+	for pw, want := range map[string]string{"": "empty", "changeit": "default", "hunter2!": "protected"} {
+		p12, err := pkcs12.Modern.Encode(key, cert, nil, pw)
+		require.NoError(t, err)
+		e := inspect(t, "client.bin", p12)
+		assert.Equal(t, "PKCS#12 keystore", e.Metadata["store_type"], pw)
+		assert.Equal(t, want, e.Metadata["password"], pw)
+		if want == "protected" {
+			assert.NotContains(t, e.Metadata, "cert_subject")
+			continue
+		}
+		assert.Equal(t, "ECDSA P-256", e.Metadata["key_type"])
+		assert.Equal(t, "CN=client", e.Metadata["cert_subject"])
+		assert.Equal(t, "2031-01-02T03:04:05Z", e.Metadata["cert_not_after"])
+		assert.Equal(t, 0.95, e.Confidence)
+	}
+
+	trust, err := pkcs12.Modern.EncodeTrustStore([]*x509.Certificate{cert}, "changeit")
+	require.NoError(t, err)
+	e := inspect(t, "truststore.p12", trust)
+	assert.Equal(t, "default", e.Metadata["password"])
+	assert.Equal(t, "CN=client", e.Metadata["cert_subject"])
+	assert.Equal(t, 0.3, e.Confidence)
+}
+
+func TestInspectCredentialStore_PKCS12IterationCap(t *testing.T) {
+	key, cert := selfSigned(t, "client")
+	for _, enc := range []*pkcs12.Encoder{pkcs12.Modern2023, pkcs12.Modern2026} {
+		p12, err := enc.WithIterations(maxPKCS12Iterations+1).Encode(key, cert, nil, "changeit")
+		require.NoError(t, err)
+		n, ok := pkcs12MacIterations(p12)
+		require.True(t, ok)
+		assert.Equal(t, maxPKCS12Iterations+1, n)
+		assert.Equal(t, "protected", inspect(t, "client.p12", p12).Metadata["password"], "passwords are not tried")
+	}
+	_, ok := pkcs12MacIterations([]byte{0x30, 0x03, 0x02, 0x01})
+	assert.False(t, ok)
+}
+
+func TestInspectCredentialStore_KeePass(t *testing.T) {
+	kdbx := append([]byte{0x03, 0xd9, 0xa2, 0x9a, 0x67, 0xfb, 0x4b, 0xb5, 0x01, 0x00, 0x04, 0x00}, make([]byte, 32)...)
+	e := inspect(t, "vault.kdbx", kdbx)
+	assert.Equal(t, "KeePass database (KDBX 4)", e.Metadata["store_type"])
+	assert.Equal(t, "protected", e.Metadata["password"])
+
+	_, ok := InspectCredentialStore("x.bin", []byte{0x03, 0xd9, 0xa2, 0x9a, 0, 0, 0, 0})
+	assert.False(t, ok)
+}
+
+func TestInspectCredentialStore_PPK(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	pub, err := ssh.NewPublicKey(&key.PublicKey)
+	require.NoError(t, err)
+	b64 := base64.StdEncoding.EncodeToString(pub.Marshal())
+	var lines []string
+	for len(b64) > 64 {
+		lines, b64 = append(lines, b64[:64]), b64[64:]
+	}
+	lines = append(lines, b64)
+	ppk := func(enc string) []byte {
+		return []byte("PuTTY-User-Key-File-3: ssh-rsa\nEncryption: " + enc + "\nComment: deploy\nPublic-Lines: " +
+			string(rune('0'+len(lines))) + "\n" + strings.Join(lines, "\n") + "\nPrivate-Lines: 1\nAAAA\n")
+	}
+
+	e := inspect(t, "deploy.ppk", ppk("none"))
+	assert.Equal(t, "PuTTY private key (v3)", e.Metadata["store_type"])
+	assert.Equal(t, "RSA 2048", e.Metadata["key_type"])
+	assert.Equal(t, "none", e.Metadata["password"])
+	assert.Equal(t, 0.95, e.Confidence)
+
+	e = inspect(t, "deploy.ppk", ppk("aes256-cbc"))
+	assert.Equal(t, "protected", e.Metadata["password"])
+}
+
+func TestInspectCredentialStore_DERKeys(t *testing.T) {
+	key, _ := selfSigned(t, "unused")
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+	e := inspect(t, "id.der", pkcs8)
+	assert.Equal(t, "DER private key (PKCS#8)", e.Metadata["store_type"])
+	assert.Equal(t, "ECDSA P-256", e.Metadata["key_type"])
+	assert.Equal(t, "none", e.Metadata["password"])
+
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	assert.Equal(t, "DER private key (SEC 1)", inspect(t, "ec.key", sec1).Metadata["store_type"])
+
+	enc, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.NullRawValue},
+		Data:      bytes.Repeat([]byte{0x5a}, 64),
+	})
+	require.NoError(t, err)
+	e = inspect(t, "id.key", enc)
+	assert.Equal(t, "DER private key (encrypted PKCS#8)", e.Metadata["store_type"])
+	assert.Equal(t, "protected", e.Metadata["password"])
+
+	_, cert := selfSigned(t, "not a key")
+	_, ok := InspectCredentialStore("cert.der", cert.Raw)
+	assert.False(t, ok, "certificates are not credential stores")
+	_, ok = InspectCredentialStore("notes.txt", []byte("0 items\n"))
+	assert.False(t, ok)
+}
+
+func TestScanArchives_ReportsCredentialStores(t *testing.T) {
+	_, cert := selfSigned(t, "app.example.com")
+	z := zipBytes(t, map[string]string{
+		"WEB-INF/keystore.jks": string(jksBytes(jksMagic, "changeit", true, cert)),
+		"app.properties":       "name=app\n",
+	})
+	got, stats := scanArchiveFiles(t, map[string][]byte{"app.war": z})
+	assert.Equal(t, map[string]string{"app.war::app.properties": "name=app\n"}, got)
+	require.Len(t, stats.Detected, 1)
+	e := stats.Detected[0]
+	assert.Equal(t, "app.war::WEB-INF/keystore.jks", e.Path)
+	assert.Equal(t, "default", e.Metadata["password"])
+	assert.Empty(t, stats.Unscannable)
+}
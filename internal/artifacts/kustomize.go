@@ -0,0 +1,449 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+	"sigs.k8s.io/kustomize/api/krusty"
+	"sigs.k8s.io/kustomize/api/resource"
+	"sigs.k8s.io/kustomize/kyaml/filesys"
+)
+
+// kustomizationFileNames are the file names kustomize recognises, in the
+// order it looks for them.
+var kustomizationFileNames = []string{"kustomization.yaml", "kustomization.yml", "Kustomization"}
+
+// kustomizeGeneratorKinds maps kustomization fields to the generator kind
+// kustomize records in a generated resource's origin.
+var kustomizeGeneratorKinds = [][2]string{
+	{"secretGenerator", "SecretGenerator"},
+	{"configMapGenerator", "ConfigMapGenerator"},
+}
+
+// ScanKustomizations finds kustomization roots under root (kustomizations that
+// no other kustomization references as a resource, base or component), builds
+// each in-process and scans the rendered resources with
+// ScanK8sResourceValues, so values from secretGenerator literals, env files
+// and overlay patches are decoded like any other Secret.
+//
+// Entries are emitted under <kustomization file>::<Kind>/<namespace>/<name>
+// using the rendered (prefixed and hashed) names, with metadata:
+//
+//   - kustomize_root: the directory that was built
+//   - kustomize_origin: the file the resource was loaded from, for resources
+//   - kustomize_configured_in and kustomize_generator: the kustomization and
+//     generator kind, for generated resources
+//   - kustomize_source and kustomize_source_line: where a generated Secret key
+//     was defined (the literal in the kustomization, or the env file line)
+//
+// Remote bases are dropped rather than fetched, so scans stay offline. At most
+// limits.MaxEntries resources are scanned per root. Build errors are
+// collected and returned once every root has been tried.
+func ScanKustomizations(root string, limits Limits, allow PathAllowFunc, emit EntryFunc) error {
+	return Discover(root, allow, NewKustomizeHandler(limits, emit, nil))
+}
+
+// NewKustomizeHandler returns the Discover handler for kustomizations. Files
+// are collected during the walk; roots are resolved and built in Finish, and
+// each root is reported in stats, which may be nil.
+func NewKustomizeHandler(limits Limits, emit EntryFunc, stats *Stats) Handler {
+	return &kustomizeHandler{limits: limits, emit: emit, stats: stats}
+}
+
+type kustomizeHandler struct {
+	limits Limits
+	emit   EntryFunc
+	stats  *Stats
+	found  []string
+}
+
+func (h *kustomizeHandler) Match(c *Candidate) bool {
+	return !c.IsDir() && isKustomizationFile(c.Entry.Name())
+}
+
+func (h *kustomizeHandler) Handle(c *Candidate) error {
+	h.found = append(h.found, c.Path)
+	return nil
+}
+
+func (h *kustomizeHandler) Finish(root string) error {
+	var errs []error
+	for _, file := range kustomizationRoots(h.found) {
+		rel, _ := filepath.Rel(root, file)
+		err := scanArtifact(h.stats, h.limits, rel, "kustomize", func(stats *Stats) (int, int64, error) {
+			var n int
+			var size int64
+			err := scanKustomization(root, file, h.limits, countEntries(h.emit, &n, &size), stats)
+			return n, size, err
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", filepath.ToSlash(rel), err))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func isKustomizationFile(name string) bool {
+	for _, n := range kustomizationFileNames {
+		if name == n {
+			return true
+		}
+	}
+	return false
+}
+
+// kustomization is the part of a kustomization file needed to find roots.
+type kustomization struct {
+	Resources  []string `yaml:"resources"`
+	Bases      []string `yaml:"bases"`
+	Components []string `yaml:"components"`
+}
+
+// kustomizationRoots returns the kustomization files whose directory is not
+// referenced by any other kustomization in the set.
+func kustomizationRoots(files []string) []string {
+	dirs := make(map[string]bool, len(files))
+	for _, f := range files {
+		dirs[filepath.Dir(f)] = true
+	}
+	referenced := map[string]bool{}
+	for _, f := range files {
+		data, err := os.ReadFile(f)
+		if err != nil {
+			continue
+		}
+		var k kustomization
+		if yaml.Unmarshal(data, &k) != nil {
+			continue
+		}
+		for _, ref := range append(append(k.Resources, k.Bases...), k.Components...) {
+			dir := filepath.Clean(filepath.Join(filepath.Dir(f), ref))
+			if dirs[dir] {
+				referenced[dir] = true
+			}
+		}
+	}
+	var roots []string
+	for _, f := range files {
+		if !referenced[filepath.Dir(f)] {
+			roots = append(roots, f)
+		}
+	}
+	sort.Strings(roots)
+	return roots
+}
+
+// scanKustomization builds the kustomization in file's directory and emits
+// its resources. Resources past limits.MaxEntries and those that cannot be
+// serialized are recorded in stats.
+func scanKustomization(root, file string, limits Limits, emit EntryFunc, stats *Stats) error {
+	dir := filepath.Dir(file)
+	fsys := kustomizeFS{FileSystem: filesys.MakeFsOnDisk(), root: dir}
+	resMap, err := krusty.MakeKustomizer(krusty.MakeDefaultOptions()).Run(fsys, dir)
+	if err != nil {
+		return fmt.Errorf("kustomize build failed: %w", err)
+	}
+
+	relFile, _ := filepath.Rel(root, file)
+	relFile = filepath.ToSlash(relFile)
+	relDir, _ := filepath.Rel(root, dir)
+	generators := map[string][]kustomizeGenerator{}
+
+	for i, r := range resMap.Resources() {
+		if limits.MaxEntries > 0 && i >= limits.MaxEntries {
+			stats.add("entries")
+			break
+		}
+		meta := map[string]string{"kustomize_root": filepath.ToSlash(relDir)}
+		var gens []kustomizeGenerator
+		if origin, err := r.GetOrigin(); err == nil && origin != nil {
+			gens = kustomizeOriginMetadata(root, dir, origin, r, generators, meta)
+		}
+		_ = r.SetOrigin(nil) // Only removes the annotation added above
+		data, err := r.AsYAML()
+		if err != nil {
+			stats.fail(fmt.Errorf("%s: %w", K8sObjectPath(r.GetKind(), r.GetNamespace(), r.GetName()), err))
+			continue
+		}
+
+		masked := ScanK8sResourceValues(data, relFile, func(e Entry) {
+			for k, v := range meta {
+				e.Metadata[k] = v
+			}
+			key := e.Metadata["k8s_secret_key"]
+			key = strings.TrimPrefix(strings.TrimPrefix(key, "data."), "stringData.")
+			if src, ok := kustomizeKeySource(gens, key); ok {
+				e.Metadata["kustomize_source"] = src.file
+				e.Metadata["kustomize_source_line"] = strconv.Itoa(src.line)
+			}
+			emit(e)
+		})
+		m := copyMeta(meta)
+		m["k8s_kind"] = r.GetKind()
+		m["k8s_name"] = r.GetName()
+		if ns := r.GetNamespace(); ns != "" {
+			m["k8s_namespace"] = ns
+		}
+		emit(Entry{Path: relFile + "::" + K8sObjectPath(r.GetKind(), r.GetNamespace(), r.GetName()), Data: masked, Metadata: m})
+	}
+	return nil
+}
+
+// kustomizeOriginMetadata records where a rendered resource came from and,
+// for generated resources, returns the generators that may have produced it.
+// Generators are parsed once per kustomization file and cached in cache.
+func kustomizeOriginMetadata(root, dir string, origin *resource.Origin, r *resource.Resource, cache map[string][]kustomizeGenerator, meta map[string]string) []kustomizeGenerator {
+	if origin.Repo != "" {
+		meta["kustomize_origin"] = origin.Repo + "//" + origin.Path
+		return nil
+	}
+	if origin.Path != "" {
+		meta["kustomize_origin"] = relToRoot(root, filepath.Join(dir, origin.Path))
+	}
+	if origin.ConfiguredIn == "" {
+		return nil
+	}
+	configuredIn := filepath.Join(dir, origin.ConfiguredIn)
+	meta["kustomize_configured_in"] = relToRoot(root, configuredIn)
+	meta["kustomize_generator"] = origin.ConfiguredBy.Kind
+
+	gens, ok := cache[configuredIn]
+	if !ok {
+		gens = parseKustomizeGenerators(root, configuredIn)
+		cache[configuredIn] = gens
+	}
+	// Generated names are <prefix><name><suffix>-<hash>; prefer the longest
+	// generator name found in the rendered name, falling back to every
+	// generator of the same kind.
+	var byKind, byName []kustomizeGenerator
+	for _, g := range gens {
+		if g.kind != origin.ConfiguredBy.Kind {
+			continue
+		}
+		byKind = append(byKind, g)
+		if strings.Contains(r.GetName(), g.name) {
+			byName = append(byName, g)
+		}
+	}
+	if len(byName) == 0 {
+		return byKind
+	}
+	sort.SliceStable(byName, func(i, j int) bool { return len(byName[i].name) > len(byName[j].name) })
+	return byName
+}
+
+func relToRoot(root, p string) string {
+	rel, err := filepath.Rel(root, p)
+	if err != nil {
+		return filepath.ToSlash(p)
+	}
+	return filepath.ToSlash(rel)
+}
+
+// kustomizeGenerator is a secretGenerator or configMapGenerator entry and the
+// place each of its keys is defined.
+type kustomizeGenerator struct {
+	kind string
+	name string
+	keys map[string]kustomizeSource
+}
+
+type kustomizeSource struct {
+	file string
+	line int
+}
+
+func kustomizeKeySource(gens []kustomizeGenerator, key string) (kustomizeSource, bool) {
+	if key == "" {
+		return kustomizeSource{}, false
+	}
+	for _, g := range gens {
+		if src, ok := g.keys[key]; ok {
+			return src, true
+		}
+	}
+	return kustomizeSource{}, false
+}
+
+// parseKustomizeGenerators reads the generators declared in a kustomization
+// file: literals point at their line in the kustomization, env file keys at
+// their line in the env file and files at the file itself.
+func parseKustomizeGenerators(root, file string) []kustomizeGenerator {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil
+	}
+	dir := filepath.Dir(file)
+	relFile := relToRoot(root, file)
+
+	var gens []kustomizeGenerator
+	for _, gk := range kustomizeGeneratorKinds {
+		field, kind := gk[0], gk[1]
+		list := yamlMappingValue(doc.Content[0], field)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, item := range list.Content {
+			if item.Kind != yaml.MappingNode {
+				continue
+			}
+			g := kustomizeGenerator{kind: kind, keys: map[string]kustomizeSource{}}
+			if n := yamlMappingValue(item, "name"); n != nil {
+				g.name = n.Value
+			}
+			for _, lit := range yamlScalarList(yamlMappingValue(item, "literals")) {
+				if k, _, ok := strings.Cut(lit.Value, "="); ok {
+					g.keys[strings.TrimSpace(k)] = kustomizeSource{file: relFile, line: lit.Line}
+				}
+			}
+			envs := yamlScalarList(yamlMappingValue(item, "envs"))
+			if env := yamlMappingValue(item, "env"); env != nil && env.Kind == yaml.ScalarNode {
+				envs = append(envs, env)
+			}
+			for _, env := range envs {
+				p := filepath.Join(dir, env.Value)
+				for k, line := range kustomizeEnvKeys(p) {
+					g.keys[k] = kustomizeSource{file: relToRoot(root, p), line: line}
+				}
+			}
+			for _, f := range yamlScalarList(yamlMappingValue(item, "files")) {
+				k, p, ok := strings.Cut(f.Value, "=")
+				if !ok {
+					p, k = k, filepath.Base(k)
+				}
+				g.keys[strings.TrimSpace(k)] = kustomizeSource{file: relToRoot(root, filepath.Join(dir, p)), line: 1}
+			}
+			gens = append(gens, g)
+		}
+	}
+	return gens
+}
+
+func yamlScalarList(n *yaml.Node) []*yaml.Node {
+	if n == nil || n.Kind != yaml.SequenceNode {
+		return nil
+	}
+	var out []*yaml.Node
+	for _, c := range n.Content {
+		if c.Kind == yaml.ScalarNode && c.Value != "" {
+			out = append(out, c)
+		}
+	}
+	return out
+}
+
+// kustomizeEnvKeys returns the line of each KEY=value in an env file.
+func kustomizeEnvKeys(path string) map[string]int {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	keys := map[string]int{}
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; sc.Scan(); line++ {
+		text := strings.TrimSpace(sc.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if k, _, ok := strings.Cut(text, "="); ok {
+			keys[strings.TrimSpace(k)] = line
+		}
+	}
+	return keys
+}
+
+// kustomizeFS is the on-disk file system with two changes to the
+// kustomization files kustomize reads: the root kustomization asks for origin
+// annotations, which is how findings are attributed to overlays and
+// generators, and remote bases are dropped so builds never touch the network.
+type kustomizeFS struct {
+	filesys.FileSystem
+	root string
+}
+
+func (f kustomizeFS) ReadFile(path string) ([]byte, error) {
+	data, err := f.FileSystem.ReadFile(path)
+	if err != nil || !isKustomizationFile(filepath.Base(path)) {
+		return data, err
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return data, nil
+	}
+	k := doc.Content[0]
+	dir := filepath.Dir(path)
+	changed := false
+	for _, field := range []string{"resources", "bases", "components"} {
+		list := yamlMappingValue(k, field)
+		if list == nil || list.Kind != yaml.SequenceNode {
+			continue
+		}
+		kept := list.Content[:0]
+		for _, ref := range list.Content {
+			if isRemoteKustomizeRef(dir, ref.Value) {
+				changed = true
+				continue
+			}
+			kept = append(kept, ref)
+		}
+		list.Content = kept
+	}
+	if filepath.Clean(dir) == filepath.Clean(f.root) {
+		setKustomizeOriginAnnotations(k)
+		changed = true
+	}
+	if !changed {
+		return data, nil
+	}
+	out, err := yaml.Marshal(k)
+	if err != nil {
+		return data, nil
+	}
+	return out, nil
+}
+
+// setKustomizeOriginAnnotations adds originAnnotations to buildMetadata.
+func setKustomizeOriginAnnotations(k *yaml.Node) {
+	bm := yamlMappingValue(k, "buildMetadata")
+	if bm == nil {
+		k.Content = append(k.Content,
+			&yaml.Node{Kind: yaml.ScalarNode, Value: "buildMetadata"},
+			&yaml.Node{Kind: yaml.SequenceNode})
+		bm = k.Content[len(k.Content)-1]
+	}
+	if bm.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, c := range bm.Content {
+		if c.Value == "originAnnotations" {
+			return
+		}
+	}
+	bm.Content = append(bm.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "originAnnotations"})
+}
+
+// isRemoteKustomizeRef reports whether a resources/bases/components entry
+// refers to a git repository or URL rather than a local path.
+func isRemoteKustomizeRef(dir, ref string) bool {
+	if _, err := os.Stat(filepath.Join(dir, ref)); err == nil {
+		return false
+	}
+	if strings.Contains(ref, "://") || strings.HasPrefix(ref, "git@") || strings.Contains(ref, "?ref=") {
+		return true
+	}
+	// github.com/org/repo/path style
+	host, _, ok := strings.Cut(ref, "/")
+	return ok && strings.Contains(host, ".") && !strings.HasPrefix(host, ".")
+}
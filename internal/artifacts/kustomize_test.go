@@ -0,0 +1,175 @@
+package artifacts
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var kustomizeTree = map[string]string{
+	"base/kustomization.yaml": `resources:
+- deployment.yaml
+- secret.yaml
+secretGenerator:
+- name: api
+  literals:
+  - API_KEY=sk_live_abcdefghijklmnop
+  envs:
+  - db.env
+configMapGenerator:
+- name: settings
+  literals:
+  - MODE=production
+`,
+	"base/db.env": "# database\nDB_USER=app\nDB_PASSWORD=hunter2-prod\n",
+	"base/secret.yaml": `apiVersion: v1
+kind: Secret
+metadata:
+  name: static
+data:
+  token: aHVudGVyMi1wcm9kLXBhc3N3b3Jk
+`,
+	"base/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        image: web:1
+`,
+	"overlays/prod/kustomization.yaml": `namePrefix: prod-
+namespace: prod
+resources:
+- ../../base
+- https://github.com/example/remote//manifests?ref=v1
+patches:
+- path: env.yaml
+`,
+	"overlays/prod/env.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: web
+spec:
+  template:
+    spec:
+      containers:
+      - name: web
+        env:
+        - name: SENTRY_DSN
+          value: https://abc123@sentry.example.com/1
+`,
+}
+
+func TestScanKustomizations(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, kustomizeTree)
+
+	got := map[string]Entry{}
+	err := ScanKustomizations(root, Limits{}, nil, func(e Entry) { got[e.Path] = e })
+	require.NoError(t, err)
+
+	// Only the overlay is a root; base is built through it.
+	for p := range got {
+		assert.True(t, strings.HasPrefix(p, "overlays/prod/kustomization.yaml::"), p)
+	}
+
+	var apiKey, dbPassword Entry
+	for p, e := range got {
+		switch {
+		case strings.HasSuffix(p, "::data.API_KEY"):
+			apiKey = e
+		case strings.HasSuffix(p, "::data.DB_PASSWORD"):
+			dbPassword = e
+		}
+	}
+	require.NotNil(t, apiKey.Metadata, "generated secret missing: %v", entryPaths(got))
+	assert.Equal(t, "sk_live_abcdefghijklmnop", string(apiKey.Data))
+	assert.True(t, strings.HasPrefix(apiKey.Metadata["k8s_name"], "prod-api-"), apiKey.Metadata["k8s_name"])
+	assert.Equal(t, "prod", apiKey.Metadata["k8s_namespace"])
+	assert.Equal(t, "overlays/prod", apiKey.Metadata["kustomize_root"])
+	assert.Equal(t, "base/kustomization.yaml", apiKey.Metadata["kustomize_configured_in"])
+	assert.Equal(t, "SecretGenerator", apiKey.Metadata["kustomize_generator"])
+	assert.Equal(t, "base/kustomization.yaml", apiKey.Metadata["kustomize_source"])
+	assert.Equal(t, "7", apiKey.Metadata["kustomize_source_line"])
+
+	require.NotNil(t, dbPassword.Metadata)
+	assert.Equal(t, "hunter2-prod", string(dbPassword.Data))
+	assert.Equal(t, "base/db.env", dbPassword.Metadata["kustomize_source"])
+	assert.Equal(t, "3", dbPassword.Metadata["kustomize_source_line"])
+
+	static, ok := got["overlays/prod/kustomization.yaml::Secret/prod/prod-static::data.token"]
+	require.True(t, ok, "resource secret missing: %v", entryPaths(got))
+	assert.Equal(t, "hunter2-prod-password", string(static.Data))
+	assert.Equal(t, "base/secret.yaml", static.Metadata["kustomize_origin"])
+	assert.Empty(t, static.Metadata["kustomize_source"])
+
+	// Values patched in by the overlay are scanned in the rendered workload.
+	env, ok := got["overlays/prod/kustomization.yaml::Deployment/prod/prod-web::spec.template.spec.containers[0].env[0].value"]
+	require.True(t, ok, "patched env value missing: %v", entryPaths(got))
+	assert.Equal(t, "SENTRY_DSN=https://abc123@sentry.example.com/1", string(env.Data))
+
+	// Rendered resources are emitted masked and without the origin annotation.
+	secret, ok := got["overlays/prod/kustomization.yaml::Secret/prod/prod-static"]
+	require.True(t, ok)
+	assert.NotContains(t, string(secret.Data), "aHVudGVy")
+	assert.NotContains(t, string(secret.Data), "config.kubernetes.io/origin")
+	assert.Equal(t, "base/secret.yaml", secret.Metadata["kustomize_origin"])
+}
+
+func TestScanKustomizations_BuildError(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"bad/kustomization.yaml": "resources:\n- missing.yaml\n",
+		"ok/kustomization.yaml":  "configMapGenerator:\n- name: c\n  literals:\n  - A=b\n",
+	})
+
+	var paths []string
+	err := ScanKustomizations(root, Limits{}, nil, func(e Entry) { paths = append(paths, e.Path) })
+	assert.ErrorContains(t, err, "bad/kustomization.yaml: kustomize build failed")
+	require.Len(t, paths, 1)
+	assert.True(t, strings.HasPrefix(paths[0], "ok/kustomization.yaml::ConfigMap/c-"), paths[0])
+
+	var stats Stats
+	err = Discover(root, nil, NewKustomizeHandler(Limits{}, func(Entry) {}, &stats))
+	assert.Error(t, err)
+	require.Len(t, stats.Artifacts, 2)
+	bad := stats.Artifacts[0]
+	assert.Equal(t, "bad/kustomization.yaml", bad.Path)
+	require.Len(t, bad.Errors, 1)
+	assert.Contains(t, bad.Errors[0], "kustomize build failed")
+	assert.Empty(t, stats.Artifacts[1].Errors)
+}
+
+func TestScanKustomizations_EntryLimit(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"app/kustomization.yaml": "configMapGenerator:\n- name: a\n  literals:\n  - A=b\n- name: c\n  literals:\n  - C=d\n",
+	})
+
+	var stats Stats
+	require.NoError(t, Discover(root, nil, NewKustomizeHandler(Limits{MaxEntries: 1}, func(Entry) {}, &stats)))
+	assert.Equal(t, 1, stats.AbortedByEntries)
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "entries", stats.Artifacts[0].Aborted)
+}
+
+func TestKustomizationRoots(t *testing.T) {
+	root := t.TempDir()
+	writeFiles(t, root, map[string]string{
+		"base/kustomization.yaml":         "resources: []\n",
+		"components/x/kustomization.yaml": "kind: Component\n",
+		"dev/kustomization.yml":           "resources: [../base]\ncomponents: [../components/x]\n",
+		"prod/Kustomization":              "bases: [../base]\n",
+	})
+	var files []string
+	for _, p := range []string{"base/kustomization.yaml", "components/x/kustomization.yaml", "dev/kustomization.yml", "prod/Kustomization"} {
+		files = append(files, filepath.Join(root, p))
+	}
+	assert.Equal(t, []string{filepath.Join(root, "dev/kustomization.yml"), filepath.Join(root, "prod/Kustomization")}, kustomizationRoots(files))
+}
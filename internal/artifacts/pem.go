@@ -0,0 +1,147 @@
+package artifacts
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"strings"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+)
+
+// PEMKey describes a PEM-encoded private key found in a secret.
+type PEMKey struct {
+	Type      string // e.g. "RSA 2048" or "Ed25519"; "" if unknown
+	Encrypted bool   // protected by a passphrase
+	// Fingerprint is the lowercase hex SHA-256 of the DER-encoded
+	// SubjectPublicKeyInfo of the key ("openssl pkey -pubout -outform DER |
+	// sha256sum"); "" if the public key is not readable.
+	Fingerprint string
+	// SSHFingerprint is the "SHA256:..." fingerprint ssh-keygen -l shows,
+	// for keys in OpenSSH format.
+	SSHFingerprint string
+
+	spki []byte // DER-encoded public key
+}
+
+// ParsePEMKey parses the first PEM private key block in s, which may have
+// its newlines escaped as "\n" as in JSON and YAML strings. Passphrase
+// encrypted keys are recognised but only OpenSSH ones reveal their public
+// key.
+func ParsePEMKey(s string) (PEMKey, bool) {
+	block, raw := pemBlock(s)
+	if block == nil || !strings.HasSuffix(block.Type, "PRIVATE KEY") {
+		return PEMKey{}, false
+	}
+	var k PEMKey
+	var pub crypto.PublicKey
+	if block.Type == "ENCRYPTED PRIVATE KEY" {
+		k.Encrypted = true
+	} else {
+		key, err := ssh.ParseRawPrivateKey(raw)
+		var missing *ssh.PassphraseMissingError
+		switch {
+		case errors.As(err, &missing):
+			k.Encrypted = true
+			if cpk, ok := missing.PublicKey.(ssh.CryptoPublicKey); ok {
+				pub = cpk.CryptoPublicKey()
+			}
+		case err != nil:
+			return PEMKey{}, false
+		default:
+			if signer, ok := key.(crypto.Signer); ok {
+				pub = signer.Public()
+			}
+		}
+	}
+	if pub == nil {
+		return k, true
+	}
+	k.Type = keyType(pub)
+	if der, err := x509.MarshalPKIXPublicKey(pub); err == nil {
+		sum := sha256.Sum256(der)
+		k.spki, k.Fingerprint = der, hex.EncodeToString(sum[:])
+	}
+	if block.Type == "OPENSSH PRIVATE KEY" {
+		if sp, err := ssh.NewPublicKey(pub); err == nil {
+			k.SSHFingerprint = ssh.FingerprintSHA256(sp)
+		}
+	}
+	return k, true
+}
+
+// pemBlock decodes the first PEM block in s, unescaping "\n" if the block
+// does not decode as is, and returns it with the PEM text it came from.
+func pemBlock(s string) (*pem.Block, []byte) {
+	i := strings.Index(s, "-----BEGIN")
+	if i < 0 {
+		return nil, nil
+	}
+	raw := []byte(s[i:])
+	if block, _ := pem.Decode(raw); block != nil {
+		return block, raw
+	}
+	raw = bytes.ReplaceAll(raw, []byte(`\n`), []byte("\n"))
+	block, _ := pem.Decode(raw)
+	return block, raw
+}
+
+// Metadata returns the key's description as finding metadata.
+func (k PEMKey) Metadata() map[string]string {
+	meta := map[string]string{"key_encrypted": "false"}
+	if k.Encrypted {
+		meta["key_encrypted"] = "true"
+	}
+	if k.Type != "" {
+		meta["key_type"] = k.Type
+	}
+	if k.Fingerprint != "" {
+		meta["key_fingerprint"] = k.Fingerprint
+	}
+	if k.SSHFingerprint != "" {
+		meta["key_ssh_fingerprint"] = k.SSHFingerprint
+	}
+	return meta
+}
+
+// Matches reports whether c is a certificate for the key.
+func (k PEMKey) Matches(c *x509.Certificate) bool {
+	return k.spki != nil && bytes.Equal(k.spki, c.RawSubjectPublicKeyInfo)
+}
+
+// PEMCertificates returns the X.509 certificates in the PEM CERTIFICATE
+// blocks of data.
+func PEMCertificates(data []byte) []*x509.Certificate {
+	var certs []*x509.Certificate
+	for {
+		i := bytes.Index(data, []byte("-----BEGIN CERTIFICATE-----"))
+		if i < 0 {
+			return certs
+		}
+		block, rest := pem.Decode(data[i:])
+		if block == nil {
+			// Skip the malformed block's header and keep looking.
+			data = data[i+len("-----BEGIN"):]
+			continue
+		}
+		if c, err := x509.ParseCertificate(block.Bytes); err == nil {
+			certs = append(certs, c)
+		}
+		data = rest
+	}
+}
+
+// CertificateMetadata returns the subject, issuer and expiry of c as finding
+// metadata.
+func CertificateMetadata(c *x509.Certificate) map[string]string {
+	return map[string]string{
+		"cert_subject":   c.Subject.String(),
+		"cert_issuer":    c.Issuer.String(),
+		"cert_not_after": c.NotAfter.UTC().Format(time.RFC3339),
+	}
+}
@@ -0,0 +1,98 @@
+package artifacts
+
+import (
+	"errors"
+	"sync"
+)
+
+// artifactJob scans one artifact, passing what it finds to emit and counting
+// guardrail aborts in stats.
+type artifactJob[T any] func(emit func(T), stats *Stats) error
+
+// runArtifactJobs runs jobs on up to workers goroutines (at least one). Each
+// job's output is buffered and passed to emit in job order once every
+// earlier job has finished, so output does not depend on scheduling and emit
+// is never called concurrently. emit is called without holding the pool's
+// lock, by the worker that finished the job at the head of the queue, so
+// other workers can hand in their results meanwhile. A job only starts once
+// fewer than workers jobs are running or waiting to be emitted, so a slow
+// job holds back later ones instead of letting their output pile up.
+// Per-job Stats are merged into stats in the same order. Job errors are
+// returned joined, in job order.
+func runArtifactJobs[T any](jobs []artifactJob[T], workers int, emit func(T), stats *Stats) error {
+	if workers <= 1 {
+		// Nothing to reorder: scan and emit directly without buffering.
+		var errs []error
+		for _, job := range jobs {
+			if err := job(emit, stats); err != nil {
+				errs = append(errs, err)
+			}
+		}
+		return errors.Join(errs...)
+	}
+
+	type result struct {
+		out   []T
+		stats Stats
+		err   error
+		done  bool
+	}
+	results := make([]result, len(jobs))
+	var mu sync.Mutex
+	flushed := sync.NewCond(&mu)
+	next := 0
+	flushing := false
+	// flush emits completed results in order. It is called with mu held and
+	// returns with it released; only one worker flushes at a time, and the
+	// flushing worker also emits results that finish while it does.
+	flush := func() {
+		if flushing {
+			mu.Unlock()
+			return
+		}
+		flushing = true
+		for next < len(results) && results[next].done {
+			r := &results[next]
+			out, st := r.out, r.stats
+			r.out, r.stats = nil, Stats{}
+			mu.Unlock()
+			for _, v := range out {
+				emit(v)
+			}
+			stats.merge(st)
+			mu.Lock()
+			next++
+			flushed.Signal()
+		}
+		flushing = false
+		mu.Unlock()
+	}
+
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		mu.Lock()
+		for i >= next+workers {
+			flushed.Wait()
+		}
+		mu.Unlock()
+		wg.Add(1)
+		go func(i int, job artifactJob[T]) {
+			defer wg.Done()
+			var r result
+			r.err = job(func(v T) { r.out = append(r.out, v) }, &r.stats)
+			r.done = true
+			mu.Lock()
+			results[i] = r
+			flush()
+		}(i, job)
+	}
+	wg.Wait()
+
+	var errs []error
+	for _, r := range results {
+		if r.err != nil {
+			errs = append(errs, r.err)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,70 @@
+package artifacts
+
+import (
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunArtifactJobs_EmitsInJobOrder(t *testing.T) {
+	var jobs []artifactJob[string]
+	for i := 0; i < 6; i++ {
+		jobs = append(jobs, func(emit func(string), stats *Stats) error {
+			// Earlier jobs finish last.
+			time.Sleep(time.Duration(6-i) * 5 * time.Millisecond)
+			emit(fmt.Sprintf("%d-a", i))
+			emit(fmt.Sprintf("%d-b", i))
+			stats.add("entries")
+			return nil
+		})
+	}
+	var got []string
+	var stats Stats
+	require.NoError(t, runArtifactJobs(jobs, 4, func(s string) { got = append(got, s) }, &stats))
+	assert.Equal(t, []string{"0-a", "0-b", "1-a", "1-b", "2-a", "2-b", "3-a", "3-b", "4-a", "4-b", "5-a", "5-b"}, got)
+	assert.Equal(t, 6, stats.AbortedByEntries)
+}
+
+func TestRunArtifactJobs_BoundsLookahead(t *testing.T) {
+	release := make(chan struct{})
+	var started atomic.Int32
+	jobs := []artifactJob[string]{
+		func(func(string), *Stats) error { <-release; return nil },
+	}
+	for i := 0; i < 5; i++ {
+		jobs = append(jobs, func(emit func(string), _ *Stats) error {
+			started.Add(1)
+			emit(fmt.Sprint(i))
+			return nil
+		})
+	}
+	done := make(chan error)
+	go func() { done <- runArtifactJobs(jobs, 3, func(string) {}, nil) }()
+
+	// While the first job runs, only the next two may start.
+	time.Sleep(50 * time.Millisecond)
+	assert.Equal(t, int32(2), started.Load())
+	close(release)
+	require.NoError(t, <-done)
+	assert.Equal(t, int32(5), started.Load())
+}
+
+func TestRunArtifactJobs_JoinsErrors(t *testing.T) {
+	for _, workers := range []int{1, 3} {
+		jobs := []artifactJob[string]{
+			func(func(string), *Stats) error { return errors.New("first") },
+			func(emit func(string), _ *Stats) error { emit("ok"); return nil },
+			func(func(string), *Stats) error { return errors.New("third") },
+		}
+		var got []string
+		err := runArtifactJobs(jobs, workers, func(s string) { got = append(got, s) }, nil)
+		require.Error(t, err)
+		assert.Equal(t, "first\nthird", err.Error())
+		assert.Equal(t, []string{"ok"}, got)
+	}
+}
@@ -0,0 +1,167 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// pulumiSecretSig is the signature key Pulumi uses to mark secret values in
+// checkpoints and exports.
+const pulumiSecretSig = "4dabf18193072939515e22adb298388d"
+
+// isPulumiPath reports whether rel is named like a stack config,
+// Pulumi.<stack>.yaml.
+func isPulumiPath(rel string) bool {
+	base := filepath.Base(rel)
+	return strings.HasPrefix(base, "Pulumi.") && (strings.HasSuffix(base, ".yaml") || strings.HasSuffix(base, ".yml"))
+}
+
+// isPulumiCheckpoint reports whether JSON looks like a stack checkpoint or
+// `pulumi stack export` output.
+func isPulumiCheckpoint(b []byte) bool {
+	head := b
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return (bytes.Contains(head, []byte(`"checkpoint"`)) || bytes.Contains(head, []byte(`"deployment"`))) && bytes.Contains(b, []byte("urn:pulumi:"))
+}
+
+// scanPulumiStackConfig emits each plaintext value under config: in a
+// Pulumi.<stack>.yaml file at rel::config.<key>. Values stored as
+// {secure: ...} are encrypted and skipped, as is the project file
+// Pulumi.yaml.
+func scanPulumiStackConfig(rel string, data []byte, emit EntryFunc) {
+	base := filepath.Base(rel)
+	stack := strings.TrimSuffix(strings.TrimSuffix(strings.TrimPrefix(base, "Pulumi."), ".yaml"), ".yml")
+	if stack == "" || strings.EqualFold(base, "Pulumi.yaml") || strings.EqualFold(base, "Pulumi.yml") {
+		return
+	}
+	var doc yaml.Node
+	if yaml.Unmarshal(data, &doc) != nil || len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return
+	}
+	config := yamlMappingValue(doc.Content[0], "config")
+	if config == nil || config.Kind != yaml.MappingNode {
+		return
+	}
+	for i := 0; i+1 < len(config.Content); i += 2 {
+		key := config.Content[i].Value
+		pulumiConfigValue(config.Content[i+1], "", func(path string, n *yaml.Node) {
+			emit(Entry{
+				Path:     rel + "::config." + joinTFPath(key, path),
+				Data:     []byte(key + ": " + n.Value),
+				Metadata: map[string]string{"pulumi_stack": stack, "pulumi_config_key": key},
+				Line:     yamlContentLine(n),
+			})
+		})
+	}
+}
+
+func pulumiConfigValue(n *yaml.Node, path string, fn func(path string, n *yaml.Node)) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Value != "" && n.Tag != "!!null" {
+			fn(path, n)
+		}
+	case yaml.MappingNode:
+		if len(n.Content) == 2 && n.Content[0].Value == "secure" {
+			return
+		}
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			pulumiConfigValue(n.Content[i+1], joinK8sLocation(path, n.Content[i].Value), fn)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			pulumiConfigValue(c, path+"["+strconv.Itoa(i)+"]", fn)
+		}
+	}
+}
+
+// pulumiResource is the part of a checkpoint or export resource needed to
+// find secrets.
+type pulumiResource struct {
+	URN     string         `json:"urn"`
+	Type    string         `json:"type"`
+	Inputs  map[string]any `json:"inputs"`
+	Outputs map[string]any `json:"outputs"`
+}
+
+// scanPulumiCheckpoint scans a stack checkpoint (.pulumi/stacks/*.json) or
+// `pulumi stack export` output. It emits secret values whose plaintext is
+// present and string properties with secret-looking names, at
+// rel::json:<path in the file>. Ciphertext is skipped.
+func scanPulumiCheckpoint(rel string, data []byte, emit EntryFunc) {
+	var doc struct {
+		Checkpoint struct {
+			Latest struct {
+				Resources []pulumiResource `json:"resources"`
+			} `json:"latest"`
+		} `json:"checkpoint"`
+		Deployment struct {
+			Resources []pulumiResource `json:"resources"`
+		} `json:"deployment"`
+	}
+	if json.Unmarshal(data, &doc) != nil {
+		return
+	}
+	prefix, resources := "checkpoint.latest.resources", doc.Checkpoint.Latest.Resources
+	if len(resources) == 0 {
+		prefix, resources = "deployment.resources", doc.Deployment.Resources
+	}
+	for i, res := range resources {
+		base := prefix + "[" + strconv.Itoa(i) + "]"
+		for _, section := range []struct {
+			name string
+			vals map[string]any
+		}{{"inputs", res.Inputs}, {"outputs", res.Outputs}} {
+			for _, k := range sortedKeys(section.vals) {
+				walkPulumiValues(k, section.vals[k], keyLooksSensitive(k), func(prop, value string, secret bool) {
+					meta := map[string]string{"pulumi_urn": res.URN, "pulumi_type": res.Type, "pulumi_property": prop}
+					if secret {
+						meta["pulumi_secret"] = "true"
+					}
+					emit(Entry{Path: rel + "::json:" + base + "." + section.name + "." + prop, Data: []byte(prop + ": " + value), Metadata: meta})
+				})
+			}
+		}
+	}
+}
+
+// walkPulumiValues calls fn for each non-empty string leaf of v under a
+// secret-looking key and for each secret value with a plaintext.
+func walkPulumiValues(path string, v any, named bool, fn func(prop, value string, secret bool)) {
+	switch val := v.(type) {
+	case string:
+		if val != "" && named {
+			fn(path, val, false)
+		}
+	case map[string]any:
+		if _, ok := val[pulumiSecretSig]; ok {
+			plain, ok := val["plaintext"].(string)
+			if !ok || plain == "" {
+				return
+			}
+			// plaintext holds the JSON encoding of the value.
+			var s string
+			if json.Unmarshal([]byte(plain), &s) == nil {
+				plain = s
+			}
+			if plain != "" {
+				fn(path, plain, true)
+			}
+			return
+		}
+		for _, k := range sortedKeys(val) {
+			walkPulumiValues(joinTFPath(path, k), val[k], named || keyLooksSensitive(k), fn)
+		}
+	case []any:
+		for i, item := range val {
+			walkPulumiValues(path+"["+strconv.Itoa(i)+"]", item, named, fn)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScanPulumiStackConfig(t *testing.T) {
+	src := `secretsprovider: passphrase
+encryptionsalt: v1:abc:def
+config:
+  aws:region: us-east-1
+  app:dbPassword:
+    secure: v1:AAAABBBB:CCCCDDDD
+  app:apiToken: tkn-plaintext-123
+  app:settings:
+    admin:
+      password: hunter2
+`
+	got := map[string]Entry{}
+	scanPulumiStackConfig("infra/Pulumi.prod.yaml", []byte(src), func(e Entry) { got[e.Path] = e })
+
+	tok, ok := got["infra/Pulumi.prod.yaml::config.app:apiToken"]
+	require.True(t, ok, "plaintext config missing: %v", entryPaths(got))
+	assert.Equal(t, "app:apiToken: tkn-plaintext-123", string(tok.Data))
+	assert.Equal(t, 7, tok.Line)
+	assert.Equal(t, "prod", tok.Metadata["pulumi_stack"])
+	assert.Equal(t, "app:apiToken", tok.Metadata["pulumi_config_key"])
+
+	nested := got["infra/Pulumi.prod.yaml::config.app:settings.admin.password"]
+	assert.Equal(t, "app:settings", nested.Metadata["pulumi_config_key"])
+	assert.Contains(t, got, "infra/Pulumi.prod.yaml::config.aws:region")
+	assert.NotContains(t, got, "infra/Pulumi.prod.yaml::config.app:dbPassword.secure")
+	assert.Len(t, got, 3)
+
+	n := 0
+	scanPulumiStackConfig("Pulumi.yaml", []byte("name: app\nconfig:\n  token: abc\n"), func(Entry) { n++ })
+	assert.Zero(t, n, "project file is not a stack config")
+}
+
+func TestScanPulumiCheckpoint(t *testing.T) {
+	src := `{"version": 3, "deployment": {"resources": [
+  {"urn": "urn:pulumi:prod::app::pulumi:pulumi:Stack::app-prod", "type": "pulumi:pulumi:Stack"},
+  {"urn": "urn:pulumi:prod::app::aws:rds/instance:Instance::db", "type": "aws:rds/instance:Instance",
+   "inputs": {"password": {"4dabf18193072939515e22adb298388d": "1b47061264138c4ac30d75fd1eb44270", "plaintext": "\"S3cr3t-Pa55w0rd\""}},
+   "outputs": {
+     "password": {"4dabf18193072939515e22adb298388d": "1b47061264138c4ac30d75fd1eb44270", "ciphertext": "v1:abc"},
+     "endpoint": "db.example.com",
+     "tags": {"api_token": "tkn-abc"},
+     "connection": {"4dabf18193072939515e22adb298388d": "1b47061264138c4ac30d75fd1eb44270", "plaintext": "{\"user\":\"u\"}"}
+   }}
+]}}`
+	require.True(t, isPulumiCheckpoint([]byte(src)))
+	got := map[string]Entry{}
+	scanPulumiCheckpoint("stack.json", []byte(src), func(e Entry) { got[e.Path] = e })
+
+	pw, ok := got["stack.json::json:deployment.resources[1].inputs.password"]
+	require.True(t, ok, "secret plaintext missing: %v", entryPaths(got))
+	assert.Equal(t, "password: S3cr3t-Pa55w0rd", string(pw.Data))
+	assert.Equal(t, "true", pw.Metadata["pulumi_secret"])
+	assert.Equal(t, "urn:pulumi:prod::app::aws:rds/instance:Instance::db", pw.Metadata["pulumi_urn"])
+	assert.Equal(t, "aws:rds/instance:Instance", pw.Metadata["pulumi_type"])
+	assert.Equal(t, "password", pw.Metadata["pulumi_property"])
+
+	tag := got["stack.json::json:deployment.resources[1].outputs.tags.api_token"]
+	assert.Equal(t, "tags.api_token", tag.Metadata["pulumi_property"])
+	assert.Empty(t, tag.Metadata["pulumi_secret"])
+	assert.Equal(t, `connection: {"user":"u"}`, string(got["stack.json::json:deployment.resources[1].outputs.connection"].Data))
+	assert.Len(t, got, 3)
+
+	assert.False(t, isPulumiCheckpoint([]byte(`{"name": "app", "password": "x"}`)))
+	assert.False(t, isPulumiCheckpoint([]byte(`{"name": "app", "description": "see urn:pulumi:..."}`)))
+}
+
+func TestIsPulumiPath(t *testing.T) {
+	assert.True(t, isPulumiPath("infra/Pulumi.prod.yaml"))
+	assert.True(t, isPulumiPath("Pulumi.dev.yml"))
+	assert.False(t, isPulumiPath("package.json"))
+	assert.False(t, isPulumiPath(".pulumi/stacks/app/prod.json"), "checkpoints are matched by content")
+}
@@ -0,0 +1,141 @@
+package artifacts
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// DetectorArchiveBomb reports an artifact whose scan was stopped by a
+// compression-ratio guardrail: an archive or entry that expands far beyond
+// its compressed size, a zip whose entries overlap, or an archive that
+// contains itself.
+const DetectorArchiveBomb = "archive-bomb"
+
+// ratioSlack is the number of decompressed bytes always allowed before the
+// ratio limits apply, so that small, highly repetitive files are not
+// mistaken for bombs.
+const ratioSlack = 1 << 20
+
+// errCompressionRatio is wrapped by read errors for entries that expand
+// beyond Limits.MaxEntryRatio.
+var errCompressionRatio = errors.New("compression ratio limit exceeded")
+
+// Bomb is an artifact whose scan a compression-ratio guardrail stopped.
+type Bomb struct {
+	Path   string
+	Type   string
+	Reason string
+}
+
+// archiveFrame is an archive being scanned, linked to the archive it was
+// found in, so that the ratio budget and self-containing archives can be
+// checked across nesting levels.
+type archiveFrame struct {
+	parent *archiveFrame
+	ra     io.ReaderAt
+	size   int64
+	sum    []byte // SHA-256 of the archive, computed on first use
+	// budget is the value of the decompressed counter at which
+	// MaxArchiveRatio is exceeded; 0 if unlimited. Nested archives share
+	// the budget of the outermost one.
+	budget int64
+}
+
+// enterArchive returns limits with a frame for the archive in ra pushed, or
+// an error if the archive is identical to one it is nested in.
+func enterArchive(limits Limits, ra io.ReaderAt, size int64, decompressed int64) (Limits, error) {
+	f := &archiveFrame{parent: limits.archive, ra: ra, size: size}
+	switch {
+	case f.parent != nil:
+		f.budget = f.parent.budget
+	case limits.MaxArchiveRatio > 0:
+		f.budget = decompressed + max(size*int64(limits.MaxArchiveRatio), ratioSlack)
+	}
+	for p := f.parent; p != nil; p = p.parent {
+		if p.size == size && bytes.Equal(p.digest(), f.digest()) {
+			return limits, errors.New("archive contains itself")
+		}
+	}
+	limits.archive = f
+	return limits, nil
+}
+
+func (f *archiveFrame) digest() []byte {
+	if f.sum == nil {
+		h := sha256.New()
+		if _, err := io.Copy(h, io.NewSectionReader(f.ra, 0, f.size)); err != nil {
+			// Unreadable: give the frame a digest no other archive has.
+			return []byte(fmt.Sprintf("%p", f))
+		}
+		f.sum = h.Sum(nil)
+	}
+	return f.sum
+}
+
+// ratioBudget returns the decompressed counter value at which the archive
+// being scanned exceeds MaxArchiveRatio, or 0 if there is no such limit.
+func (l Limits) ratioBudget() int64 {
+	if l.archive == nil {
+		return 0
+	}
+	return l.archive.budget
+}
+
+// limitEntryRatio wraps r, an entry stored in compressed bytes, so that
+// reading more than MaxEntryRatio times that fails with errCompressionRatio.
+func limitEntryRatio(r io.Reader, compressed int64, limits Limits) io.Reader {
+	if limits.MaxEntryRatio <= 0 {
+		return r
+	}
+	return &ratioReader{r: r, ratio: limits.MaxEntryRatio, compressed: compressed, max: max(compressed*int64(limits.MaxEntryRatio), ratioSlack)}
+}
+
+type ratioReader struct {
+	r          io.Reader
+	ratio      int
+	compressed int64
+	n, max     int64
+}
+
+func (r *ratioReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.n > r.max {
+		return n, fmt.Errorf("%w: expands to more than %dx its %d compressed bytes", errCompressionRatio, r.ratio, r.compressed)
+	}
+	return n, err
+}
+
+// overlappingZipEntries returns the names of two entries whose compressed
+// data overlap, the mark of a zip bomb that reuses one deflate stream for
+// many entries.
+func overlappingZipEntries(zr *zip.Reader) (string, string, bool) {
+	type span struct {
+		start, end int64
+		name       string
+	}
+	spans := make([]span, 0, len(zr.File))
+	for _, f := range zr.File {
+		off, err := f.DataOffset()
+		if err != nil {
+			continue
+		}
+		spans = append(spans, span{start: off, end: off + int64(f.CompressedSize64), name: f.Name})
+	}
+	sort.Slice(spans, func(i, j int) bool { return spans[i].start < spans[j].start })
+	last := -1
+	for i, s := range spans {
+		if last >= 0 && s.start < spans[last].end {
+			return spans[last].name, s.name, true
+		}
+		if last < 0 || s.end > spans[last].end {
+			last = i
+		}
+	}
+	return "", "", false
+}
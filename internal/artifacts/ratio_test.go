@@ -0,0 +1,96 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scanRatio(t *testing.T, lim Limits, files map[string][]byte) (map[string]string, Stats) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, data := range files {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o600))
+	}
+	lim.MaxArchiveBytes, lim.MaxEntries, lim.MaxDepth, lim.TimeBudget = 64<<20, 100, 2, 10*time.Second
+	got := map[string]string{}
+	var stats Stats
+	require.NoError(t, ScanArchivesWithStats(dir, lim, nil, func(p string, b []byte) { got[p] = string(b) }, &stats))
+	return got, stats
+}
+
+func TestScanArchives_EntryRatio(t *testing.T) {
+	z := zipBytes(t, map[string]string{
+		"bomb.txt": strings.Repeat("a", 8<<20),
+		"ok.env":   "password=hunter2\n",
+	})
+	got, stats := scanRatio(t, Limits{MaxEntryRatio: 200}, map[string][]byte{"a.zip": z})
+	assert.Equal(t, map[string]string{"a.zip::ok.env": "password=hunter2\n"}, got)
+	assert.Equal(t, 1, stats.AbortedByRatio)
+	require.Len(t, stats.Bombs, 1)
+	assert.Equal(t, "a.zip", stats.Bombs[0].Path)
+	assert.Equal(t, "archive", stats.Bombs[0].Type)
+	assert.Contains(t, stats.Bombs[0].Reason, "bomb.txt: compression ratio limit exceeded")
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "ratio", stats.Artifacts[0].Aborted)
+}
+
+func TestScanArchives_ArchiveRatio(t *testing.T) {
+	files := map[string]string{}
+	for _, n := range []string{"a", "b", "c", "d"} {
+		files[n+".txt"] = strings.Repeat(n, 1<<20)
+	}
+	tgz := compressBytes(t, "gzip", tarBytes(t, files))
+	got, stats := scanRatio(t, Limits{MaxArchiveRatio: 100}, map[string][]byte{"logs.tgz": tgz})
+	assert.Less(t, len(got), 2, "scanning should stop once the ratio budget is spent")
+	assert.Equal(t, 1, stats.AbortedByRatio)
+	require.Len(t, stats.Bombs, 1)
+	assert.Equal(t, "archive decompressed to more than 100x its size", stats.Bombs[0].Reason)
+}
+
+func TestScanArchives_RatioSlack(t *testing.T) {
+	// Small, highly repetitive entries are within the first MiB allowed
+	// regardless of ratio.
+	z := zipBytes(t, map[string]string{"app.log": strings.Repeat("x", 512<<10)})
+	got, stats := scanRatio(t, Limits{MaxEntryRatio: 10, MaxArchiveRatio: 10}, map[string][]byte{"a.zip": z})
+	assert.Len(t, got, 1)
+	assert.Zero(t, stats.AbortedByRatio)
+	assert.Empty(t, stats.Bombs)
+}
+
+func TestScanArchives_OverlappingZipEntries(t *testing.T) {
+	z := zipBytes(t, map[string]string{"a.txt": "hello", "b.txt": "hello"})
+	// Point the second central directory record at the first local header,
+	// so both entries share the same compressed data.
+	second := bytes.LastIndex(z, []byte("PK\x01\x02"))
+	require.Positive(t, second)
+	binary.LittleEndian.PutUint32(z[second+42:], 0)
+
+	got, stats := scanRatio(t, Limits{}, map[string][]byte{"overlap.zip": z})
+	assert.Empty(t, got)
+	assert.Equal(t, 1, stats.AbortedByRatio)
+	require.Len(t, stats.Bombs, 1)
+	assert.Equal(t, "zip entries a.txt and b.txt overlap", stats.Bombs[0].Reason)
+}
+
+func TestEnterArchive_DetectsSelfContainingArchive(t *testing.T) {
+	outer := zipBytes(t, map[string]string{"r.zip": "placeholder"})
+	lim, err := enterArchive(Limits{MaxArchiveRatio: 10}, bytes.NewReader(outer), int64(len(outer)), 5)
+	require.NoError(t, err)
+	assert.Equal(t, int64(5+ratioSlack), lim.ratioBudget())
+
+	other := zipBytes(t, map[string]string{"s.zip": "placeholder"})
+	nested, err := enterArchive(lim, bytes.NewReader(other), int64(len(other)), 100)
+	require.NoError(t, err)
+	assert.Equal(t, lim.ratioBudget(), nested.ratioBudget(), "nested archives share the outer budget")
+
+	_, err = enterArchive(nested, bytes.NewReader(outer), int64(len(outer)), 200)
+	assert.EqualError(t, err, "archive contains itself")
+}
@@ -1,11 +1,17 @@
 package artifacts
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"path"
+	"sort"
+	"strings"
 	"time"
 
 	"github.com/google/go-containerregistry/pkg/authn"
 	"github.com/google/go-containerregistry/pkg/name"
+	v1 "github.com/google/go-containerregistry/pkg/v1"
 	"github.com/google/go-containerregistry/pkg/v1/remote"
 )
 
@@ -17,19 +23,48 @@ func ScanRegistryImage(imageRef string, limits Limits, emit func(path string, da
 	if err != nil {
 		return fmt.Errorf("invalid image reference %q: %w", imageRef, err)
 	}
+	_, err = scanRemoteImage(ref, imageRef, limits, emit, stats)
+	return err
+}
+
+// ScanRegistryImages scans several registry images as ScanRegistryImage
+// does, up to limits.Workers at a time. Entries are emitted grouped by image,
+// in the order of refs.
+func ScanRegistryImages(refs []string, limits Limits, emit func(path string, data []byte), stats *Stats) error {
+	jobs := make([]artifactJob[Entry], len(refs))
+	for i, ref := range refs {
+		jobs[i] = func(emit func(Entry), stats *Stats) error {
+			return ScanRegistryImage(ref, limits, func(p string, b []byte) { emit(Entry{Path: p, Data: b}) }, stats)
+		}
+	}
+	return runArtifactJobs(jobs, limits.Workers, func(e Entry) { emit(e.Path, e.Data) }, stats)
+}
 
+// scanRemoteImage streams every layer of ref and emits entries under the
+// virtual path prefix vpBase (typically the human-readable image reference),
+// which is also the path the image is reported under in stats. It reports
+// whether every layer was scanned in full.
+func scanRemoteImage(ref name.Reference, vpBase string, limits Limits, emit func(path string, data []byte), stats *Stats) (bool, error) {
+	return runArtifactScan(stats, limits, vpBase, "registry", func(stats *Stats) (int, int64, error) {
+		return scanRemoteImageLayers(ref, vpBase, limits, emit, stats)
+	})
+}
+
+// scanRemoteImageLayers does the work of scanRemoteImage, returning the
+// entries emitted and the bytes decompressed.
+func scanRemoteImageLayers(ref name.Reference, vpBase string, limits Limits, emit func(path string, data []byte), stats *Stats) (int, int64, error) {
 	// Fetch the image metadata.
 	// remote.Image() uses the default keychain (e.g., ~/.docker/config.json) for auth.
 	// This does NOT download the layers yet.
 	img, err := remote.Image(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
 	if err != nil {
-		return fmt.Errorf("failed to fetch image metadata for %q: %w", imageRef, err)
+		return 0, 0, fmt.Errorf("failed to fetch image metadata for %q: %w", vpBase, err)
 	}
 
 	// Get the list of layers
 	layers, err := img.Layers()
 	if err != nil {
-		return fmt.Errorf("failed to get layers for %q: %w", imageRef, err)
+		return 0, 0, fmt.Errorf("failed to get layers for %q: %w", vpBase, err)
 	}
 
 	var decompressed int64
@@ -45,34 +80,225 @@ func ScanRegistryImage(imageRef string, limits Limits, emit func(path string, da
 	for _, layer := range layers {
 		// Check global/artifact limits before starting a layer
 		if r := limitsExceededReason(limits, decompressed, entries, 0, deadline); r != "" {
-			if stats != nil {
-				stats.add(r)
-			}
-			return nil // Abort scanning this image
+			stats.add(r)
+			return entries, decompressed, nil // Abort scanning this image
 		}
 
 		digest, err := layer.Digest()
 		if err != nil {
+			stats.fail(fmt.Errorf("layer digest: %w", err))
 			continue
 		}
 
 		rc, err := layer.Uncompressed()
 		if err != nil {
-			return fmt.Errorf("failed to read layer %s: %w", digest, err)
+			return entries, decompressed, fmt.Errorf("failed to read layer %s: %w", digest, err)
 		}
 
 		// Construct virtual path: image:tag::sha256:hash
 		// Files within layer will be: image:tag::sha256:hash/path/to/file
-		vp := fmt.Sprintf("%s::%s", imageRef, digest.String())
+		vp := fmt.Sprintf("%s::%s", vpBase, digest.String())
 
 		// Use the shared tar scanner from artifacts.go
 		// We pass depth=1 because the layer itself is "inside" the image
-		err = scanTarReaderJoin(vp, "/", limits, &decompressed, &entries, 1, deadline, emit, rc)
+		err = scanTarReaderWithStats(vp, "/", limits, &decompressed, &entries, 1, deadline, emit, rc, stats)
 		safeClose(rc)
 		if err != nil {
+			stats.fail(fmt.Errorf("layer %s: %w", digest, err))
+			stats.unscannable(vp, err.Error())
+		}
+	}
+
+	return entries, decompressed, nil
+}
+
+// RegistryTarget is a unique manifest in a registry together with every
+// repository:tag reference that resolves to it.
+type RegistryTarget struct {
+	Digest string
+	Refs   []string
+}
+
+// RegistryOptions controls repository-wide and catalog-wide registry scans.
+type RegistryOptions struct {
+	// TagPattern filters tags with comma-separated path.Match globs (e.g. "v*").
+	// An empty pattern selects every tag.
+	TagPattern string
+	// Concurrency bounds how many manifests are scanned at once. When zero,
+	// Limits.Workers is used, falling back to 1.
+	Concurrency int
+	// Seen reports whether a manifest digest was already scanned, e.g. in a
+	// previous run recorded in the cache. Seen digests are skipped.
+	Seen func(digest string) bool
+	// Done is called once a manifest digest has been scanned in full: not
+	// for digests skipped because Limits.GlobalDeadline had passed, stopped
+	// by a byte, entry or time limit, or with layers that could not be read.
+	// It may be called from several goroutines at once.
+	Done func(digest string)
+}
+
+func (o RegistryOptions) workers(limits Limits) int {
+	if o.Concurrency > 0 {
+		return o.Concurrency
+	}
+	if limits.Workers > 0 {
+		return limits.Workers
+	}
+	return 1
+}
+
+// matchTagPattern reports whether tag matches any of the comma-separated globs.
+func matchTagPattern(pattern, tag string) bool {
+	if strings.TrimSpace(pattern) == "" {
+		return true
+	}
+	for _, p := range strings.Split(pattern, ",") {
+		p = strings.TrimSpace(p)
+		if p == "" {
+			continue
+		}
+		if ok, _ := path.Match(p, tag); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// ListRegistryTargets lists the tags of repo that match tagPattern and groups
+// them by manifest digest, so each unique manifest appears exactly once.
+func ListRegistryTargets(repo string, tagPattern string) ([]RegistryTarget, error) {
+	return listRegistryTargets([]string{repo}, tagPattern)
+}
+
+// ListRegistryCatalog returns the repositories hosted by a registry using the
+// /v2/_catalog API.
+func ListRegistryCatalog(registry string) ([]string, error) {
+	reg, err := name.NewRegistry(registry)
+	if err != nil {
+		return nil, fmt.Errorf("invalid registry %q: %w", registry, err)
+	}
+	repos, err := remote.Catalog(context.Background(), reg, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list catalog for %q: %w", registry, err)
+	}
+	out := make([]string, 0, len(repos))
+	for _, r := range repos {
+		out = append(out, reg.Name()+"/"+r)
+	}
+	sort.Strings(out)
+	return out, nil
+}
+
+func listRegistryTargets(repos []string, tagPattern string) ([]RegistryTarget, error) {
+	byDigest := map[string]*RegistryTarget{}
+	var errs []error
+	for _, r := range repos {
+		repo, err := name.NewRepository(r)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("invalid repository %q: %w", r, err))
+			continue
+		}
+		tags, err := remote.List(repo, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to list tags for %q: %w", r, err))
+			continue
+		}
+		for _, tag := range tags {
+			if !matchTagPattern(tagPattern, tag) {
+				continue
+			}
+			ref := repo.Tag(tag)
+			// HEAD only fetches the descriptor; layers are not downloaded here.
+			desc, err := remote.Head(ref, remote.WithAuthFromKeychain(authn.DefaultKeychain))
+			if err != nil {
+				errs = append(errs, fmt.Errorf("failed to resolve %q: %w", ref.String(), err))
+				continue
+			}
+			d := desc.Digest.String()
+			t, ok := byDigest[d]
+			if !ok {
+				t = &RegistryTarget{Digest: d}
+				byDigest[d] = t
+			}
+			t.Refs = append(t.Refs, r+":"+tag)
+		}
+	}
+	targets := make([]RegistryTarget, 0, len(byDigest))
+	for _, t := range byDigest {
+		sort.Strings(t.Refs)
+		targets = append(targets, *t)
+	}
+	// Group output by repository and tag of the first reference.
+	sort.Slice(targets, func(i, j int) bool { return targets[i].Refs[0] < targets[j].Refs[0] })
+	return targets, errors.Join(errs...)
+}
+
+// ScanRegistryRepository scans every tag of repo matching opts.TagPattern,
+// scanning each unique manifest digest once.
+func ScanRegistryRepository(repo string, limits Limits, opts RegistryOptions, emit EntryFunc, stats *Stats) error {
+	return ScanRegistryRepositories([]string{repo}, limits, opts, emit, stats)
+}
+
+// ScanRegistryCatalog lists every repository in registry and scans their tags
+// as ScanRegistryRepositories does.
+func ScanRegistryCatalog(registry string, limits Limits, opts RegistryOptions, emit EntryFunc, stats *Stats) error {
+	repos, err := ListRegistryCatalog(registry)
+	if err != nil {
+		return err
+	}
+	return ScanRegistryRepositories(repos, limits, opts, emit, stats)
+}
+
+// ScanRegistryRepositories scans the matching tags of several repositories.
+// Manifests shared by multiple tags (or repositories) are scanned once and
+// reported under the first reference; every reference is recorded in the
+// "registry_refs" metadata. Entries are emitted grouped by image, in
+// repository and tag order, regardless of concurrency.
+func ScanRegistryRepositories(repos []string, limits Limits, opts RegistryOptions, emit EntryFunc, stats *Stats) error {
+	targets, listErr := listRegistryTargets(repos, opts.TagPattern)
+	var errs []error
+	if listErr != nil {
+		errs = append(errs, listErr)
+	}
+
+	var pending []RegistryTarget
+	for _, t := range targets {
+		if opts.Seen != nil && opts.Seen(t.Digest) {
+			continue
+		}
+		pending = append(pending, t)
+	}
+
+	jobs := make([]artifactJob[Entry], len(pending))
+	for i, t := range pending {
+		jobs[i] = func(emit func(Entry), stats *Stats) error {
+			meta := map[string]string{
+				"registry_digest": t.Digest,
+				"registry_refs":   strings.Join(t.Refs, ","),
+			}
+			complete, err := scanRegistryTarget(t, limits, func(p string, b []byte) {
+				emit(Entry{Path: p, Data: b, Metadata: meta})
+			}, stats)
+			if complete && opts.Done != nil {
+				opts.Done(t.Digest)
+			}
 			return err
 		}
 	}
+	if err := runArtifactJobs(jobs, opts.workers(limits), emit, stats); err != nil {
+		errs = append(errs, err)
+	}
+	return errors.Join(errs...)
+}
 
-	return nil
+func scanRegistryTarget(t RegistryTarget, limits Limits, emit func(path string, data []byte), stats *Stats) (bool, error) {
+	ref, err := name.ParseReference(t.Refs[0])
+	if err != nil {
+		return false, fmt.Errorf("invalid image reference %q: %w", t.Refs[0], err)
+	}
+	// Pin the digest so every reference is scanned against the same manifest.
+	if h, herr := v1.NewHash(t.Digest); herr == nil {
+		ref = ref.Context().Digest(h.String())
+	}
+	return scanRemoteImage(ref, t.Refs[0], limits, emit, stats)
 }
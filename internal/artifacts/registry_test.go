@@ -1,9 +1,25 @@
 package artifacts
 
 import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"log"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
+	"github.com/google/go-containerregistry/pkg/name"
+	"github.com/google/go-containerregistry/pkg/registry"
+	"github.com/google/go-containerregistry/pkg/v1/empty"
+	"github.com/google/go-containerregistry/pkg/v1/mutate"
+	"github.com/google/go-containerregistry/pkg/v1/remote"
+	"github.com/google/go-containerregistry/pkg/v1/tarball"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestScanRegistryImage_InvalidRef(t *testing.T) {
@@ -14,3 +30,155 @@ func TestScanRegistryImage_InvalidRef(t *testing.T) {
 
 // Note: Valid registry tests require network and valid credentials or a public image.
 // We skip them here to keep unit tests fast and hermetic.
+
+// pushTestImage writes a single-layer image containing files to ref on a test registry.
+func pushTestImage(t *testing.T, ref string, files map[string]string) {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for name, content := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: name, Mode: 0600, Size: int64(len(content))}))
+		_, err := tw.Write([]byte(content))
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	layer, err := tarball.LayerFromReader(bytes.NewReader(buf.Bytes()))
+	require.NoError(t, err)
+	img, err := mutate.AppendLayers(empty.Image, layer)
+	require.NoError(t, err)
+	r, err := name.ParseReference(ref)
+	require.NoError(t, err)
+	require.NoError(t, remote.Write(r, img))
+}
+
+func newTestRegistry(t *testing.T) string {
+	t.Helper()
+	srv := httptest.NewServer(registry.New(registry.Logger(log.New(io.Discard, "", 0))))
+	t.Cleanup(srv.Close)
+	u, err := url.Parse(srv.URL)
+	require.NoError(t, err)
+	return u.Host
+}
+
+func TestListRegistryTargets_GroupsTagsByDigest(t *testing.T) {
+	host := newTestRegistry(t)
+	repo := host + "/team/app"
+	pushTestImage(t, repo+":v1", map[string]string{"app/config.env": "TOKEN=one"})
+	pushTestImage(t, repo+":v1-alias", map[string]string{"app/config.env": "TOKEN=one"})
+	pushTestImage(t, repo+":v2", map[string]string{"app/config.env": "TOKEN=two"})
+	pushTestImage(t, repo+":latest", map[string]string{"app/config.env": "TOKEN=three"})
+
+	targets, err := ListRegistryTargets(repo, "v*")
+	require.NoError(t, err)
+	require.Len(t, targets, 2)
+	assert.Equal(t, []string{repo + ":v1", repo + ":v1-alias"}, targets[0].Refs)
+	assert.Equal(t, []string{repo + ":v2"}, targets[1].Refs)
+	assert.NotEqual(t, targets[0].Digest, targets[1].Digest)
+}
+
+func TestScanRegistryRepositories_ScansEachDigestOnce(t *testing.T) {
+	host := newTestRegistry(t)
+	repo := host + "/team/app"
+	pushTestImage(t, repo+":v1", map[string]string{"app/config.env": "TOKEN=one"})
+	pushTestImage(t, repo+":v1-alias", map[string]string{"app/config.env": "TOKEN=one"})
+	pushTestImage(t, repo+":v2", map[string]string{"app/config.env": "TOKEN=two"})
+
+	var got []Entry
+	var done []string
+	var mu sync.Mutex
+	opts := RegistryOptions{
+		Concurrency: 2,
+		Done: func(d string) {
+			mu.Lock()
+			done = append(done, d)
+			mu.Unlock()
+		},
+	}
+	err := ScanRegistryRepository(repo, Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 2}, opts, func(e Entry) {
+		got = append(got, e)
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, got, 2)
+	assert.Len(t, done, 2)
+
+	// Entries are grouped in repository/tag order.
+	assert.True(t, strings.HasPrefix(got[0].Path, repo+":v1::sha256:"), got[0].Path)
+	assert.True(t, strings.HasSuffix(got[0].Path, "/app/config.env"), got[0].Path)
+	assert.Equal(t, repo+":v1,"+repo+":v1-alias", got[0].Metadata["registry_refs"])
+	assert.True(t, strings.HasPrefix(got[1].Path, repo+":v2::"), got[1].Path)
+	assert.Equal(t, "TOKEN=two", string(got[1].Data))
+}
+
+func TestScanRegistryRepositories_SkipsSeenDigests(t *testing.T) {
+	host := newTestRegistry(t)
+	repo := host + "/team/app"
+	pushTestImage(t, repo+":v1", map[string]string{"a.txt": "one"})
+	pushTestImage(t, repo+":v2", map[string]string{"a.txt": "two"})
+
+	targets, err := ListRegistryTargets(repo, "v1")
+	require.NoError(t, err)
+	require.Len(t, targets, 1)
+	seen := targets[0].Digest
+
+	var got []Entry
+	opts := RegistryOptions{Seen: func(d string) bool { return d == seen }}
+	err = ScanRegistryRepository(repo, Limits{}, opts, func(e Entry) { got = append(got, e) }, nil)
+	require.NoError(t, err)
+	require.Len(t, got, 1)
+	assert.Equal(t, "two", string(got[0].Data))
+}
+
+func TestScanRegistryRepositories_DeadlineSkipIsNotDone(t *testing.T) {
+	host := newTestRegistry(t)
+	repo := host + "/team/app"
+	pushTestImage(t, repo+":v1", map[string]string{"a.txt": "one"})
+
+	var done []string
+	var stats Stats
+	opts := RegistryOptions{Done: func(d string) { done = append(done, d) }}
+	lim := Limits{MaxArchiveBytes: 1 << 20, GlobalDeadline: time.Now().Add(-time.Second)}
+	require.NoError(t, ScanRegistryRepository(repo, lim, opts, func(Entry) {}, &stats))
+	assert.Empty(t, done)
+	assert.Equal(t, 1, stats.AbortedByTime)
+}
+
+func TestScanRegistryRepositories_PartialScanIsNotDone(t *testing.T) {
+	host := newTestRegistry(t)
+	repo := host + "/team/app"
+	pushTestImage(t, repo+":v1", map[string]string{"a.txt": "one", "b.txt": "two", "c.txt": "three"})
+
+	var done []string
+	var stats Stats
+	opts := RegistryOptions{Done: func(d string) { done = append(done, d) }}
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 1, MaxDepth: 2}
+	require.NoError(t, ScanRegistryRepository(repo, lim, opts, func(Entry) {}, &stats))
+	assert.Empty(t, done)
+	require.Len(t, stats.Artifacts, 1)
+	assert.Equal(t, "entries", stats.Artifacts[0].Aborted)
+}
+
+func TestScanRegistryCatalog(t *testing.T) {
+	host := newTestRegistry(t)
+	pushTestImage(t, host+"/team/app:v1", map[string]string{"a.txt": "app"})
+	pushTestImage(t, host+"/team/worker:v1", map[string]string{"b.txt": "worker"})
+
+	repos, err := ListRegistryCatalog(host)
+	require.NoError(t, err)
+	assert.Equal(t, []string{host + "/team/app", host + "/team/worker"}, repos)
+
+	var paths []string
+	err = ScanRegistryCatalog(host, Limits{}, RegistryOptions{TagPattern: "v*"}, func(e Entry) {
+		paths = append(paths, e.Path)
+	}, nil)
+	require.NoError(t, err)
+	require.Len(t, paths, 2)
+	assert.True(t, strings.HasPrefix(paths[0], host+"/team/app:v1::"))
+	assert.True(t, strings.HasPrefix(paths[1], host+"/team/worker:v1::"))
+}
+
+func TestMatchTagPattern(t *testing.T) {
+	assert.True(t, matchTagPattern("", "anything"))
+	assert.True(t, matchTagPattern("v*", "v1.2.3"))
+	assert.False(t, matchTagPattern("v*", "latest"))
+	assert.True(t, matchTagPattern("v*, latest", "latest"))
+}
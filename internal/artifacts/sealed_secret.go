@@ -0,0 +1,71 @@
+package artifacts
+
+import (
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// isSealedSecret reports whether doc is a Bitnami SealedSecret resource.
+func isSealedSecret(doc *yaml.Node) bool {
+	kind := yamlMappingValue(doc, "kind")
+	api := yamlMappingValue(doc, "apiVersion")
+	return kind != nil && kind.Value == "SealedSecret" && api != nil && strings.HasPrefix(api.Value, "bitnami.com/")
+}
+
+// maskSealedSecret masks the ciphertext of a SealedSecret: each value of
+// spec.encryptedData and the legacy spec.data blob.
+func maskSealedSecret(lines []string, doc *yaml.Node) bool {
+	spec := yamlMappingValue(doc, "spec")
+	if spec == nil || spec.Kind != yaml.MappingNode {
+		return false
+	}
+	changed := false
+	if enc := yamlMappingValue(spec, "encryptedData"); enc != nil && enc.Kind == yaml.MappingNode {
+		for i := 1; i < len(enc.Content); i += 2 {
+			changed = maskYAMLNode(lines, enc.Content[i]) || changed
+		}
+	}
+	if data := yamlMappingValue(spec, "data"); data != nil && data.Kind == yaml.ScalarNode {
+		changed = maskYAMLNode(lines, data) || changed
+	}
+	return changed
+}
+
+// scanSealedSecretPlaintext emits the values a SealedSecret carries outside
+// its ciphertext: spec.template.data entries, which end up in the unsealed
+// Secret as written, and data/stringData mistakenly set on the SealedSecret
+// itself. Template values that only reference encrypted keys ({{ .key }})
+// are skipped.
+func scanSealedSecretPlaintext(rel string, doc *yaml.Node, emit EntryFunc) {
+	meta := map[string]string{"encryption": "sealed-secret", "k8s_kind": "SealedSecret"}
+	if md := yamlMappingValue(doc, "metadata"); md != nil {
+		if n := yamlMappingValue(md, "name"); n != nil {
+			meta["k8s_name"] = n.Value
+		}
+		if ns := yamlMappingValue(md, "namespace"); ns != nil {
+			meta["k8s_namespace"] = ns.Value
+		}
+	}
+	values := func(m *yaml.Node, path string, confidence float64) {
+		if m == nil || m.Kind != yaml.MappingNode {
+			return
+		}
+		for i := 0; i+1 < len(m.Content); i += 2 {
+			v := m.Content[i+1]
+			if v.Kind != yaml.ScalarNode || v.Value == "" || strings.Contains(v.Value, "{{") {
+				continue
+			}
+			em := copyMeta(meta)
+			em["plaintext_key"] = path + "." + m.Content[i].Value
+			emit(Entry{Path: rel, Data: []byte(v.Value), Metadata: em, Line: yamlContentLine(v), Detector: DetectorEncryptedFilePlaintext, Confidence: confidence})
+		}
+	}
+	if spec := yamlMappingValue(doc, "spec"); spec != nil && spec.Kind == yaml.MappingNode {
+		if tmpl := yamlMappingValue(spec, "template"); tmpl != nil && tmpl.Kind == yaml.MappingNode {
+			values(yamlMappingValue(tmpl, "data"), "spec.template.data", 0.8)
+		}
+	}
+	values(yamlMappingValue(doc, "data"), "data", 0.9)
+	values(yamlMappingValue(doc, "stringData"), "stringData", 0.9)
+}
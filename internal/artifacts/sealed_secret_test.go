@@ -0,0 +1,58 @@
+package artifacts
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSealedSecret = `apiVersion: bitnami.com/v1alpha1
+kind: SealedSecret
+metadata:
+  name: db
+  namespace: prod
+spec:
+  encryptedData:
+    password: AgBy3i4OJSWK+PiTySYZZA9rO43cGDEq6h7Uu0hE3rMZ
+    token: |
+      AgCtr8OJSWK+PiTySYZZA9rO43cGDEq6h7Uu0hE3rMZ
+  template:
+    metadata:
+      name: db
+    data:
+      username: admin-plain
+      url: "postgres://{{ .password }}@db"
+---
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: other
+data:
+  encryptedData: AgBy3i4OJSWK
+`
+
+func TestMaskEncryptedValues_SealedSecret(t *testing.T) {
+	masked, ok := MaskEncryptedValues([]byte(testSealedSecret))
+	require.True(t, ok)
+	out := string(masked)
+	assert.Len(t, out, len(testSealedSecret))
+	assert.NotContains(t, out, "AgBy3i4OJSWK+PiTy")
+	assert.NotContains(t, out, "AgCtr8OJSWK")
+	assert.Contains(t, out, "    password: ****")
+	assert.Contains(t, out, "username: admin-plain")
+	// Only SealedSecret resources are masked.
+	assert.Contains(t, out, "encryptedData: AgBy3i4OJSWK\n")
+}
+
+func TestScanEncryptedPlaintext_SealedSecret(t *testing.T) {
+	var got []Entry
+	ScanEncryptedPlaintext("sealed.yaml", []byte(testSealedSecret), func(e Entry) { got = append(got, e) })
+	require.Len(t, got, 1)
+	assert.Equal(t, "admin-plain", string(got[0].Data))
+	assert.Equal(t, 15, got[0].Line)
+	assert.Equal(t, "spec.template.data.username", got[0].Metadata["plaintext_key"])
+	assert.Equal(t, "sealed-secret", got[0].Metadata["encryption"])
+	assert.Equal(t, "prod", got[0].Metadata["k8s_namespace"])
+	assert.Equal(t, 0.8, got[0].Confidence)
+}
@@ -0,0 +1,128 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"strings"
+
+	doublestar "github.com/bmatcuk/doublestar/v4"
+)
+
+// DetectorSensitivePath reports a file at a path where credentials are
+// conventionally kept, such as root/.ssh/id_rsa or .aws/credentials,
+// whether or not its content matches a rule.
+const DetectorSensitivePath = "sensitive-path"
+
+// sensitivePathRule is a glob over slash-separated paths relative to the
+// scan root, archive or image layer, with the confidence to report matches
+// with. check, if set, must also accept the file's content.
+type sensitivePathRule struct {
+	pattern     string
+	description string
+	confidence  float64
+	check       func([]byte) bool
+}
+
+// defaultSensitivePaths are the rules SensitivePaths starts from unless
+// DisableDefaults is set.
+var defaultSensitivePaths = []sensitivePathRule{
+	{pattern: "**/.ssh/id_{rsa,dsa,ecdsa,ed25519,ecdsa_sk,ed25519_sk}", description: "SSH private key", confidence: 0.9},
+	{pattern: "**/.aws/credentials", description: "AWS CLI credentials", confidence: 0.9},
+	{pattern: "**/.docker/config.json", description: "Docker registry credentials", confidence: 0.7},
+	{pattern: "**/.kube/config", description: "Kubernetes client configuration", confidence: 0.7},
+	{pattern: "**/.git-credentials", description: "Git credential store", confidence: 0.9},
+	{pattern: "**/{.netrc,_netrc}", description: "netrc credentials", confidence: 0.9},
+	{pattern: "**/.pgpass", description: "PostgreSQL password file", confidence: 0.9},
+	{pattern: "**/.vault-token", description: "HashiCorp Vault token", confidence: 0.9},
+	{pattern: "**/.config/gcloud/{credentials.db,access_tokens.db,application_default_credentials.json}", description: "Google Cloud SDK credentials", confidence: 0.9},
+	{pattern: "**/.npmrc", description: "npm configuration, may hold registry tokens", confidence: 0.6},
+	{pattern: "**/.pypirc", description: "PyPI configuration, may hold upload credentials", confidence: 0.6},
+	{pattern: "**/.{bash,zsh,sh,ash,fish,mysql,psql,python,node_repl}_history", description: "shell or client history", confidence: 0.6},
+	{pattern: "**/etc/{shadow,gshadow}", description: "password hashes", confidence: 0.9, check: hasPasswordHash},
+}
+
+// customSensitivePathConfidence is the confidence of matches of rules from
+// configuration.
+const customSensitivePathConfidence = 0.7
+
+// SensitivePaths matches file paths against sensitive-path rules. A nil
+// *SensitivePaths matches nothing.
+type SensitivePaths struct {
+	rules   []sensitivePathRule
+	exclude []string
+}
+
+// NewSensitivePaths returns the default sensitive-path rules, unless
+// disableDefaults is set, plus a rule for each glob in patterns; paths
+// matching a glob in exclude are never reported. Globs use doublestar
+// syntax and are matched against slash-separated paths relative to the scan
+// root, or to the root of an archive or image layer.
+func NewSensitivePaths(patterns, exclude []string, disableDefaults bool) (*SensitivePaths, error) {
+	sp := &SensitivePaths{}
+	if !disableDefaults {
+		sp.rules = append(sp.rules, defaultSensitivePaths...)
+	}
+	for _, p := range patterns {
+		if !doublestar.ValidatePattern(p) {
+			return nil, fmt.Errorf("invalid sensitive path pattern %q", p)
+		}
+		sp.rules = append(sp.rules, sensitivePathRule{pattern: p, description: "file at a sensitive path", confidence: customSensitivePathConfidence})
+	}
+	for _, p := range exclude {
+		if !doublestar.ValidatePattern(p) {
+			return nil, fmt.Errorf("invalid sensitive path exclude pattern %q", p)
+		}
+	}
+	sp.exclude = exclude
+	return sp, nil
+}
+
+// Match returns a DetectorSensitivePath entry at rel if name, the path of
+// the file relative to its root, matches a rule. Rules that look at the
+// content are skipped when data is nil.
+func (sp *SensitivePaths) Match(rel, name string, data []byte) (Entry, bool) {
+	if sp == nil {
+		return Entry{}, false
+	}
+	name = strings.TrimPrefix(name, "./")
+	for _, p := range sp.exclude {
+		if ok, _ := doublestar.Match(p, name); ok {
+			return Entry{}, false
+		}
+	}
+	for _, r := range sp.rules {
+		if ok, _ := doublestar.Match(r.pattern, name); !ok {
+			continue
+		}
+		if r.check != nil && (data == nil || !r.check(data)) {
+			continue
+		}
+		return Entry{
+			Path:       rel,
+			Data:       []byte(r.description),
+			Metadata:   map[string]string{"sensitive_path_rule": r.pattern},
+			Detector:   DetectorSensitivePath,
+			Confidence: r.confidence,
+		}, true
+	}
+	return Entry{}, false
+}
+
+// hasPasswordHash reports whether a shadow or gshadow file has an account
+// with a password hash set, rather than only locked ("!", "*") or empty
+// passwords as in most base images.
+func hasPasswordHash(b []byte) bool {
+	sc := bufio.NewScanner(bytes.NewReader(b))
+	for sc.Scan() {
+		fields := strings.Split(sc.Text(), ":")
+		if len(fields) < 2 {
+			continue
+		}
+		pw := fields[1]
+		if pw != "" && !strings.HasPrefix(pw, "!") && !strings.HasPrefix(pw, "*") && pw != "x" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+package artifacts
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSensitivePaths_Match(t *testing.T) {
+	sp, err := NewSensitivePaths([]string{"**/secrets/*.key"}, []string{"**/testdata/**"}, false)
+	require.NoError(t, err)
+
+	for name, want := range map[string]string{
+		"root/.ssh/id_rsa":                  "SSH private key",
+		"home/app/.ssh/id_ed25519":          "SSH private key",
+		".aws/credentials":                  "AWS CLI credentials",
+		"root/.docker/config.json":          "Docker registry credentials",
+		"home/dev/.git-credentials":         "Git credential store",
+		"root/.bash_history":                "shell or client history",
+		"./.netrc":                          "netrc credentials",
+		"srv/secrets/tls.key":               "file at a sensitive path",
+		"root/.ssh/id_rsa.pub":              "",
+		"root/.ssh/known_hosts":             "",
+		"testdata/fixture/.aws/credentials": "",
+		"etc/passwd":                        "",
+	} {
+		e, ok := sp.Match("image.tar::"+name, name, []byte("x"))
+		if want == "" {
+			assert.False(t, ok, name)
+			continue
+		}
+		require.True(t, ok, name)
+		assert.Equal(t, want, string(e.Data), name)
+		assert.Equal(t, DetectorSensitivePath, e.Detector)
+		assert.Equal(t, "image.tar::"+name, e.Path)
+		assert.NotEmpty(t, e.Metadata["sensitive_path_rule"])
+	}
+
+	// Only shadow files with a password hash set are reported.
+	_, ok := sp.Match("etc/shadow", "etc/shadow", []byte("root:!:19000:0:99999:7:::\nnobody:*:19000::::::\n"))
+	assert.False(t, ok)
+	e, ok := sp.Match("etc/shadow", "etc/shadow", []byte("root:$6$salt$hash:19000:0:99999:7:::\n"))
+	require.True(t, ok)
+	assert.Equal(t, 0.9, e.Confidence)
+
+	custom, err := NewSensitivePaths([]string{"**/*.kdbx"}, nil, true)
+	require.NoError(t, err)
+	_, ok = custom.Match("root/.aws/credentials", "root/.aws/credentials", nil)
+	assert.False(t, ok, "defaults disabled")
+	e, ok = custom.Match("vault.kdbx", "vault.kdbx", nil)
+	require.True(t, ok)
+	assert.Equal(t, customSensitivePathConfidence, e.Confidence)
+
+	_, err = NewSensitivePaths([]string{"[unterminated"}, nil, false)
+	assert.Error(t, err)
+
+	var none *SensitivePaths
+	_, ok = none.Match("root/.ssh/id_rsa", "root/.ssh/id_rsa", nil)
+	assert.False(t, ok)
+}
+
+func TestScanContainers_ReportsSensitivePaths(t *testing.T) {
+	layer := tarBytes(t, map[string]string{
+		"root/.ssh/id_rsa":        "\x00binary key material",
+		"root/.aws/credentials":   "[default]\n",
+		"usr/share/doc/README.md": "docs\n",
+	})
+	image := tarBytes(t, map[string]string{
+		"manifest.json": `[{"Config":"config.json","Layers":["123/layer.tar"]}]`,
+		"123/layer.tar": string(layer),
+	})
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "image.tar"), image, 0o600))
+
+	sp, err := NewSensitivePaths(nil, nil, false)
+	require.NoError(t, err)
+	var stats Stats
+	lim := Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 100, MaxDepth: 2, TimeBudget: time.Second, SensitivePaths: sp}
+	require.NoError(t, ScanContainersWithStats(dir, lim, nil, func(string, []byte) {}, &stats))
+
+	got := map[string]string{}
+	for _, e := range stats.Detected {
+		got[e.Path] = string(e.Data)
+	}
+	assert.Equal(t, map[string]string{
+		"image.tar::123/root/.ssh/id_rsa":      "SSH private key",
+		"image.tar::123/root/.aws/credentials": "AWS CLI credentials",
+	}, got)
+}
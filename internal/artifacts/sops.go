@@ -0,0 +1,321 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+
+	yaml "gopkg.in/yaml.v3"
+)
+
+// DetectorEncryptedFilePlaintext reports values stored in plaintext inside a
+// file that is meant to be encrypted: a SOPS-encrypted YAML, JSON, dotenv or
+// INI file, or a SealedSecret manifest.
+const DetectorEncryptedFilePlaintext = "encrypted-file-plaintext"
+
+var (
+	sopsCiphertextRe = regexp.MustCompile(`ENC\[AES256_GCM,data:[^\]]*\]`)
+	sopsArmoredRe    = regexp.MustCompile(`(?s)-----BEGIN (?:PGP MESSAGE|AGE ENCRYPTED FILE)-----.*?-----END (?:PGP MESSAGE|AGE ENCRYPTED FILE)-----`)
+	sopsDotenvRe     = regexp.MustCompile(`(?m)^sops_(?:mac|version|lastmodified)=`)
+	sopsINIRe        = regexp.MustCompile(`(?m)^\[sops\][ \t\r]*$`)
+)
+
+// MaskEncryptedValues returns a copy of data in which SOPS ciphertext
+// (ENC[AES256_GCM,...] values and the wrapped data keys in the sops
+// metadata) and SealedSecret encryptedData are overwritten with asterisks of
+// the same length, so line and column numbers are kept. ok is false, and data
+// is returned as is, when there is nothing to mask.
+func MaskEncryptedValues(data []byte) (masked []byte, ok bool) {
+	hasCiphertext := bytes.Contains(data, []byte("ENC[AES256_GCM,"))
+	hasSealed := bytes.Contains(data, []byte("SealedSecret"))
+	if !hasCiphertext && !hasSealed {
+		return data, false
+	}
+	out := data
+	if docs := yamlDocuments(data); len(docs) > 0 {
+		lines := strings.Split(string(data), "\n")
+		changed := false
+		for _, doc := range docs {
+			if sops := sopsMetadataNode(doc); sops != nil {
+				changed = maskSOPSDataKeys(lines, sops) || changed
+			} else if isSealedSecret(doc) {
+				changed = maskSealedSecret(lines, doc) || changed
+			}
+		}
+		if changed {
+			out = []byte(strings.Join(lines, "\n"))
+		}
+	}
+	if hasCiphertext {
+		if bytes.Equal(out, data) {
+			out = append([]byte(nil), data...)
+		}
+		maskMatches(out, sopsCiphertextRe)
+		if sopsDotenvRe.Match(out) || sopsINIRe.Match(out) {
+			maskMatches(out, sopsArmoredRe)
+			maskSOPSFlatDataKeys(out)
+		}
+	}
+	return out, !bytes.Equal(out, data)
+}
+
+// ScanEncryptedPlaintext emits the values of a SOPS-encrypted file (YAML,
+// JSON, dotenv or INI) or SealedSecret manifest that are stored in
+// plaintext, as DetectorEncryptedFilePlaintext entries at rel with
+// Entry.Line set. Values SOPS would have encrypted are reported with high
+// confidence; values its unencrypted_suffix, encrypted_regex and similar
+// rules deliberately leave in plaintext only when their key looks like a
+// secret.
+func ScanEncryptedPlaintext(rel string, data []byte, emit EntryFunc) {
+	switch {
+	case sopsDotenvRe.Match(data):
+		scanSOPSFlat(rel, data, false, emit)
+		return
+	case sopsINIRe.Match(data):
+		scanSOPSFlat(rel, data, true, emit)
+		return
+	}
+	if !bytes.Contains(data, []byte("sops")) && !bytes.Contains(data, []byte("SealedSecret")) {
+		return
+	}
+	for _, doc := range yamlDocuments(data) {
+		if sops := sopsMetadataNode(doc); sops != nil {
+			rules := newSOPSRules(func(key string) string {
+				if v := yamlMappingValue(sops, key); v != nil {
+					return v.Value
+				}
+				return ""
+			})
+			for i := 0; i+1 < len(doc.Content); i += 2 {
+				if key := doc.Content[i].Value; key != "sops" {
+					sopsTreeValues(rel, doc.Content[i+1], []string{key}, rules, emit)
+				}
+			}
+		} else if isSealedSecret(doc) {
+			scanSealedSecretPlaintext(rel, doc, emit)
+		}
+	}
+}
+
+// yamlDocuments returns the top-level mappings of a YAML or JSON stream.
+func yamlDocuments(data []byte) []*yaml.Node {
+	var docs []*yaml.Node
+	dec := yaml.NewDecoder(bytes.NewReader(data))
+	for {
+		var doc yaml.Node
+		if dec.Decode(&doc) != nil {
+			return docs
+		}
+		if len(doc.Content) > 0 && doc.Content[0].Kind == yaml.MappingNode {
+			docs = append(docs, doc.Content[0])
+		}
+	}
+}
+
+// sopsMetadataNode returns the sops: mapping of a SOPS-encrypted document.
+func sopsMetadataNode(doc *yaml.Node) *yaml.Node {
+	sops := yamlMappingValue(doc, "sops")
+	if sops == nil || sops.Kind != yaml.MappingNode {
+		return nil
+	}
+	if yamlMappingValue(sops, "mac") == nil && yamlMappingValue(sops, "version") == nil {
+		return nil
+	}
+	return sops
+}
+
+// maskSOPSDataKeys masks the enc: values (the data key wrapped for each KMS,
+// PGP or age recipient) in the sops metadata.
+func maskSOPSDataKeys(lines []string, n *yaml.Node) bool {
+	changed := false
+	switch n.Kind {
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			if v := n.Content[i+1]; n.Content[i].Value == "enc" && v.Kind == yaml.ScalarNode {
+				changed = maskYAMLNode(lines, v) || changed
+			} else {
+				changed = maskSOPSDataKeys(lines, v) || changed
+			}
+		}
+	case yaml.SequenceNode:
+		for _, c := range n.Content {
+			changed = maskSOPSDataKeys(lines, c) || changed
+		}
+	}
+	return changed
+}
+
+// maskSOPSFlatDataKeys masks the *_enc metadata values of dotenv and INI
+// files (sops_kms__list_0__map_enc=...).
+func maskSOPSFlatDataKeys(b []byte) {
+	for off := 0; off < len(b); {
+		end := bytes.IndexByte(b[off:], '\n')
+		if end < 0 {
+			end = len(b) - off
+		}
+		line := b[off : off+end]
+		if k, _, ok := bytes.Cut(line, []byte("=")); ok && bytes.HasSuffix(bytes.TrimSpace(k), []byte("_enc")) {
+			for i := len(k) + 1; i < len(line); i++ {
+				if line[i] != ' ' && line[i] != '\r' {
+					line[i] = '*'
+				}
+			}
+		}
+		off += end + 1
+	}
+}
+
+// maskMatches overwrites every match of re in b with asterisks, keeping
+// newlines.
+func maskMatches(b []byte, re *regexp.Regexp) {
+	for _, m := range re.FindAllIndex(b, -1) {
+		for i := m[0]; i < m[1]; i++ {
+			if b[i] != '\n' && b[i] != '\r' {
+				b[i] = '*'
+			}
+		}
+	}
+}
+
+// sopsRules holds the key selection rules recorded in SOPS metadata, which
+// decide which values SOPS encrypts.
+type sopsRules struct {
+	unencryptedSuffix string
+	encryptedSuffix   string
+	unencryptedRe     *regexp.Regexp
+	encryptedRe       *regexp.Regexp
+}
+
+func newSOPSRules(get func(key string) string) sopsRules {
+	r := sopsRules{unencryptedSuffix: get("unencrypted_suffix"), encryptedSuffix: get("encrypted_suffix")}
+	if s := get("unencrypted_regex"); s != "" {
+		r.unencryptedRe, _ = regexp.Compile(s)
+	}
+	if s := get("encrypted_regex"); s != "" {
+		r.encryptedRe, _ = regexp.Compile(s)
+	}
+	if r.unencryptedSuffix == "" && r.encryptedSuffix == "" && r.unencryptedRe == nil && r.encryptedRe == nil {
+		r.unencryptedSuffix = "_unencrypted"
+	}
+	return r
+}
+
+// plaintextRule returns the rule that makes SOPS leave the value under keys
+// in plaintext, or "" when SOPS encrypts it. As in SOPS, a rule matching any
+// key on the path applies to everything below it.
+func (r sopsRules) plaintextRule(keys []string) string {
+	matchAny := func(match func(string) bool) bool {
+		for _, k := range keys {
+			if !strings.HasPrefix(k, "[") && match(k) {
+				return true
+			}
+		}
+		return false
+	}
+	switch {
+	case r.unencryptedSuffix != "":
+		if matchAny(func(k string) bool { return strings.HasSuffix(k, r.unencryptedSuffix) }) {
+			return "unencrypted_suffix"
+		}
+	case r.encryptedSuffix != "":
+		if !matchAny(func(k string) bool { return strings.HasSuffix(k, r.encryptedSuffix) }) {
+			return "encrypted_suffix"
+		}
+	case r.unencryptedRe != nil:
+		if matchAny(r.unencryptedRe.MatchString) {
+			return "unencrypted_regex"
+		}
+	case r.encryptedRe != nil:
+		if !matchAny(r.encryptedRe.MatchString) {
+			return "encrypted_regex"
+		}
+	}
+	return ""
+}
+
+// emit reports a plaintext value found under keys.
+func (r sopsRules) emit(rel string, keys []string, value string, line int, emit EntryFunc) {
+	if value == "" || strings.HasPrefix(value, "ENC[AES256_GCM,") {
+		return
+	}
+	rule := r.plaintextRule(keys)
+	confidence := 0.9
+	if rule != "" {
+		if !keyLooksSensitive(keys[len(keys)-1]) {
+			return
+		}
+		confidence = 0.7
+	}
+	path := ""
+	for _, k := range keys {
+		path = joinTFPath(path, k)
+	}
+	meta := map[string]string{"encryption": "sops", "plaintext_key": path}
+	if rule != "" {
+		meta["sops_rule"] = rule
+	}
+	emit(Entry{Path: rel, Data: []byte(value), Metadata: meta, Line: line, Detector: DetectorEncryptedFilePlaintext, Confidence: confidence})
+}
+
+func sopsTreeValues(rel string, n *yaml.Node, keys []string, rules sopsRules, emit EntryFunc) {
+	switch n.Kind {
+	case yaml.ScalarNode:
+		if n.Tag != "!!null" {
+			rules.emit(rel, keys, n.Value, yamlContentLine(n), emit)
+		}
+	case yaml.MappingNode:
+		for i := 0; i+1 < len(n.Content); i += 2 {
+			sopsTreeValues(rel, n.Content[i+1], append(keys[:len(keys):len(keys)], n.Content[i].Value), rules, emit)
+		}
+	case yaml.SequenceNode:
+		for i, c := range n.Content {
+			sopsTreeValues(rel, c, append(keys[:len(keys):len(keys)], "["+strconv.Itoa(i)+"]"), rules, emit)
+		}
+	}
+}
+
+// scanSOPSFlat scans a SOPS-encrypted dotenv file, or an INI file when ini
+// is set. Metadata lives in sops_* keys (dotenv) or the [sops] section (INI).
+func scanSOPSFlat(rel string, data []byte, ini bool, emit EntryFunc) {
+	type value struct {
+		keys  []string
+		value string
+		line  int
+	}
+	var values []value
+	meta := map[string]string{}
+	section := ""
+	sc := bufio.NewScanner(bytes.NewReader(data))
+	sc.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for lineNo := 1; sc.Scan(); lineNo++ {
+		line := strings.TrimSpace(sc.Text())
+		if line == "" || line[0] == '#' || line[0] == ';' {
+			continue
+		}
+		if ini && strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.TrimSpace(line[1 : len(line)-1])
+			continue
+		}
+		k, v, ok := strings.Cut(line, "=")
+		if !ok {
+			continue
+		}
+		k, v = strings.TrimSpace(k), strings.TrimSpace(v)
+		switch {
+		case ini && section == "sops":
+			meta[k] = v
+		case !ini && strings.HasPrefix(k, "sops_"):
+			meta[strings.TrimPrefix(k, "sops_")] = v
+		case ini:
+			values = append(values, value{[]string{section, k}, v, lineNo})
+		default:
+			values = append(values, value{[]string{k}, unquoteValue(v), lineNo})
+		}
+	}
+	rules := newSOPSRules(func(key string) string { return meta[key] })
+	for _, v := range values {
+		rules.emit(rel, v.keys, v.value, v.line, emit)
+	}
+}
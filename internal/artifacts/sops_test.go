@@ -0,0 +1,147 @@
+package artifacts
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testSOPSYAML = `db:
+    password: ENC[AES256_GCM,data:Tr7o1A==,iv:1BpNDPXmSTWTBeLHrkvbqCYu2Ztux6xwYuFi4C0q0xg=,tag:JVdvWb8m6tuFNwf0S7ihtA==,type:str]
+    host_unencrypted: db.internal
+    api_token: sk_live_abcdefghijklmnop
+    token_unencrypted: tkn-plain-123
+    replicas: 3
+sops:
+    kms:
+        - arn: arn:aws:kms:us-east-1:123456789012:key/abcd
+          enc: AQICAHhQm0cBXK6KJ+Wq8fFpQzXa2C/bE1RXkP0m9hYt4WV0xgH4bUOdAHYmC2GlJ3KqBvBAAAAfjB8BgkqhkiG9w0BBwagbzBtAgEAMGgGCSqGSIb3DQEHATAeBglghkgBZQMEAS4wEQQM
+          created_at: "2024-01-01T00:00:00Z"
+    age:
+        - recipient: age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p
+          enc: |
+            -----BEGIN AGE ENCRYPTED FILE-----
+            YWdlLWVuY3J5cHRpb24ub3JnL3YxCi0+IFgyNTUxOSBxR2F0TmZ4
+            -----END AGE ENCRYPTED FILE-----
+    lastmodified: "2024-01-01T00:00:00Z"
+    mac: ENC[AES256_GCM,data:p2CGkJbyMXYGk5E=,iv:cLtuUZoAKJb7kyk0G9PYW/u2VwaKE5QCCOaFHlj7bK8=,tag:e2UxmeJMXnSzyN9u3XHhkQ==,type:str]
+    unencrypted_suffix: _unencrypted
+    version: 3.8.1
+`
+
+func TestMaskEncryptedValues_SOPSYAML(t *testing.T) {
+	masked, ok := MaskEncryptedValues([]byte(testSOPSYAML))
+	require.True(t, ok)
+	out := string(masked)
+	assert.Len(t, out, len(testSOPSYAML))
+	assert.Equal(t, strings.Count(testSOPSYAML, "\n"), strings.Count(out, "\n"))
+	for _, s := range []string{"ENC[", "Tr7o1A==", "AQICAHhQm0cB", "YWdlLWVuY3J5cHRpb24", "BEGIN AGE"} {
+		assert.NotContains(t, out, s)
+	}
+	// Plaintext and metadata that is not key material are kept.
+	for _, s := range []string{"password: ***", "sk_live_abcdefghijklmnop", "arn:aws:kms", "age1ql3z7hjy54pw3hyww5ayyfg7zqgvc7w3j2elw8zmrj2kg5sfn9aqmcac8p", "version: 3.8.1"} {
+		assert.Contains(t, out, s)
+	}
+
+	plain := []byte("password: hunter2\n")
+	got, ok := MaskEncryptedValues(plain)
+	assert.False(t, ok)
+	assert.Equal(t, plain, got)
+}
+
+func TestScanEncryptedPlaintext_SOPSYAML(t *testing.T) {
+	got := map[string]Entry{}
+	ScanEncryptedPlaintext("secrets.enc.yaml", []byte(testSOPSYAML), func(e Entry) { got[e.Metadata["plaintext_key"]] = e })
+
+	tok, ok := got["db.api_token"]
+	require.True(t, ok, "plaintext value missing: %v", got)
+	assert.Equal(t, "secrets.enc.yaml", tok.Path)
+	assert.Equal(t, "sk_live_abcdefghijklmnop", string(tok.Data))
+	assert.Equal(t, 4, tok.Line)
+	assert.Equal(t, DetectorEncryptedFilePlaintext, tok.Detector)
+	assert.Equal(t, 0.9, tok.Confidence)
+	assert.Equal(t, "sops", tok.Metadata["encryption"])
+	assert.Empty(t, tok.Metadata["sops_rule"])
+
+	// Values left in plaintext by the unencrypted_suffix rule are reported
+	// only when the key looks like a secret.
+	suffixed := got["db.token_unencrypted"]
+	assert.Equal(t, "unencrypted_suffix", suffixed.Metadata["sops_rule"])
+	assert.Equal(t, 0.7, suffixed.Confidence)
+	assert.NotContains(t, got, "db.host_unencrypted")
+
+	assert.Equal(t, "3", string(got["db.replicas"].Data))
+	assert.NotContains(t, got, "db.password")
+	assert.Len(t, got, 3)
+}
+
+func TestScanEncryptedPlaintext_SOPSEncryptedRegexJSON(t *testing.T) {
+	src := `{
+	"apiVersion": "v1",
+	"kind": "Secret",
+	"metadata": {"name": "db"},
+	"stringData": {
+		"password": "ENC[AES256_GCM,data:abc=,iv:def=,tag:ghi=,type:str]",
+		"token": "added-after-encryption"
+	},
+	"sops": {"mac": "ENC[AES256_GCM,data:mac=,iv:iv=,tag:tag=,type:str]", "encrypted_regex": "^(data|stringData)$", "version": "3.8.1"}
+}`
+	got := map[string]Entry{}
+	ScanEncryptedPlaintext("secret.enc.json", []byte(src), func(e Entry) { got[e.Metadata["plaintext_key"]] = e })
+	require.Contains(t, got, "stringData.token")
+	assert.Equal(t, 7, got["stringData.token"].Line)
+	assert.Empty(t, got["stringData.token"].Metadata["sops_rule"])
+	// metadata.name is outside encrypted_regex by design and not secret-like.
+	assert.Len(t, got, 1)
+
+	masked, ok := MaskEncryptedValues([]byte(src))
+	require.True(t, ok)
+	assert.NotContains(t, string(masked), "ENC[")
+	assert.Contains(t, string(masked), `"token": "added-after-encryption"`)
+}
+
+func TestScanEncryptedPlaintext_SOPSDotenvAndINI(t *testing.T) {
+	env := "DB_PASSWORD=ENC[AES256_GCM,data:abc=,iv:def=,tag:ghi=,type:str]\n" +
+		"API_KEY=sk_live_abcdefghijklmnop\n" +
+		"sops_kms__list_0__map_enc=AQICAHhQm0cBXK6KJ\n" +
+		"sops_age__list_0__map_enc=-----BEGIN AGE ENCRYPTED FILE-----\\nYWdl\\n-----END AGE ENCRYPTED FILE-----\\n\n" +
+		"sops_mac=ENC[AES256_GCM,data:mac=,iv:iv=,tag:tag=,type:str]\n" +
+		"sops_version=3.8.1\n"
+	var got []Entry
+	ScanEncryptedPlaintext(".env.enc", []byte(env), func(e Entry) { got = append(got, e) })
+	require.Len(t, got, 1)
+	assert.Equal(t, "API_KEY", got[0].Metadata["plaintext_key"])
+	assert.Equal(t, 2, got[0].Line)
+
+	masked, ok := MaskEncryptedValues([]byte(env))
+	require.True(t, ok)
+	assert.NotContains(t, string(masked), "AQICAH")
+	assert.NotContains(t, string(masked), "YWdl")
+	assert.Contains(t, string(masked), "API_KEY=sk_live_abcdefghijklmnop")
+
+	ini := "[database]\npassword = ENC[AES256_GCM,data:abc=,iv:def=,tag:ghi=,type:str]\nuser = admin\n\n" +
+		"[sops]\nmac = ENC[AES256_GCM,data:mac=,iv:iv=,tag:tag=,type:str]\nversion = 3.8.1\n"
+	got = nil
+	ScanEncryptedPlaintext("app.enc.ini", []byte(ini), func(e Entry) { got = append(got, e) })
+	require.Len(t, got, 1)
+	assert.Equal(t, "database.user", got[0].Metadata["plaintext_key"])
+	assert.Equal(t, 3, got[0].Line)
+}
+
+func TestSOPSRules_PlaintextRule(t *testing.T) {
+	rules := func(m map[string]string) sopsRules { return newSOPSRules(func(k string) string { return m[k] }) }
+
+	def := rules(nil)
+	assert.Empty(t, def.plaintextRule([]string{"db", "password"}))
+	assert.Equal(t, "unencrypted_suffix", def.plaintextRule([]string{"config_unencrypted", "password"}))
+
+	enc := rules(map[string]string{"encrypted_suffix": "_secret"})
+	assert.Empty(t, enc.plaintextRule([]string{"db_secret", "[0]", "value"}))
+	assert.Equal(t, "encrypted_suffix", enc.plaintextRule([]string{"db", "password"}))
+
+	re := rules(map[string]string{"unencrypted_regex": "^(name|kind)$"})
+	assert.Equal(t, "unencrypted_regex", re.plaintextRule([]string{"name"}))
+	assert.Empty(t, re.plaintextRule([]string{"token"}))
+}
@@ -0,0 +1,429 @@
+package artifacts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// isTerraformPlan reports whether JSON looks like `terraform show -json`
+// output for a saved plan.
+func isTerraformPlan(b []byte) bool {
+	head := b
+	if len(head) > 512 {
+		head = head[:512]
+	}
+	return bytes.Contains(head, []byte(`"format_version"`)) && bytes.Contains(b, []byte(`"resource_changes"`))
+}
+
+func tfEntry(vpath, address, attribute, value string, sensitive bool, line int) Entry {
+	meta := map[string]string{"tf_address": address}
+	if attribute != "" {
+		meta["tf_attribute"] = attribute
+	}
+	if sensitive {
+		meta["tf_sensitive"] = "true"
+	}
+	return Entry{Path: vpath, Data: []byte(value), Metadata: meta, Line: line}
+}
+
+func joinTFPath(parent, key string) string {
+	if parent == "" || key == "" || strings.HasPrefix(key, "[") {
+		return parent + key
+	}
+	return parent + "." + key
+}
+
+// scanTerraformHCL scans Terraform configuration. In .tf files it emits every
+// literal provider argument, variable default and sensitive output value, and
+// literal arguments with secret-looking names in resource, data, module,
+// locals and terraform blocks. In .tfvars files (tfvars true) every literal
+// assignment is emitted. Each entry holds the attribute's source text, at
+// rel::<address>.<attribute> with Entry.Line set to its first line.
+// Expressions that reference variables or call functions are skipped.
+func scanTerraformHCL(rel string, src []byte, tfvars bool, emit EntryFunc) {
+	file, _ := hclsyntax.ParseConfig(src, rel, hcl.InitialPos)
+	if file == nil {
+		return
+	}
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return
+	}
+	h := tfHCL{rel: rel, src: src, emit: emit}
+	if tfvars {
+		for _, attr := range sortedTFAttributes(body) {
+			h.attribute("var."+attr.Name, "", attr, false, true)
+		}
+		return
+	}
+	for _, b := range body.Blocks {
+		name := strings.Join(b.Labels, ".")
+		switch b.Type {
+		case "provider":
+			h.block("provider."+name, "", b.Body, false, true)
+		case "variable":
+			if attr, ok := b.Body.Attributes["default"]; ok {
+				h.attribute("var."+name, "default", attr, tfSensitiveFlag(b.Body), true)
+			}
+		case "output":
+			sensitive := tfSensitiveFlag(b.Body)
+			if attr, ok := b.Body.Attributes["value"]; ok && (sensitive || keyLooksSensitive(name)) {
+				h.attribute("output."+name, "value", attr, sensitive, true)
+			}
+		case "resource":
+			h.block(name, "", b.Body, false, false)
+		case "data", "module":
+			h.block(b.Type+"."+name, "", b.Body, false, false)
+		case "locals":
+			for _, attr := range sortedTFAttributes(b.Body) {
+				h.attribute("local."+attr.Name, "", attr, false, false)
+			}
+		default:
+			h.block(joinTFPath(b.Type, name), "", b.Body, false, false)
+		}
+	}
+}
+
+type tfHCL struct {
+	rel  string
+	src  []byte
+	emit EntryFunc
+}
+
+// block emits the attributes of a block and its nested blocks; nested block
+// types become part of the attribute path (assume_role.external_id).
+func (h tfHCL) block(address, prefix string, body *hclsyntax.Body, sensitive, all bool) {
+	for _, attr := range sortedTFAttributes(body) {
+		h.attribute(address, joinTFPath(prefix, attr.Name), attr, sensitive, all)
+	}
+	for _, b := range body.Blocks {
+		h.block(address, joinTFPath(prefix, b.Type), b.Body, sensitive, all)
+	}
+}
+
+// attribute emits attr when its value is a constant containing a string and
+// either all is set, the value is sensitive or its name looks like a secret.
+// Otherwise object literals are searched for secret-looking keys, as in
+// environment = { DB_PASSWORD = "..." }.
+func (h tfHCL) attribute(address, attribute string, attr *hclsyntax.Attribute, sensitive, all bool) {
+	if all || sensitive || keyLooksSensitive(attr.Name) {
+		h.value(address, attribute, attr.Expr, attr.SrcRange, sensitive)
+		return
+	}
+	h.objectItems(address, attribute, attr.Expr)
+}
+
+func (h tfHCL) objectItems(address, attribute string, expr hclsyntax.Expression) {
+	obj, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if !ok {
+		return
+	}
+	for _, item := range obj.Items {
+		k, diags := item.KeyExpr.Value(nil)
+		if diags.HasErrors() || k.IsNull() || k.Type() != cty.String {
+			continue
+		}
+		path := joinTFPath(attribute, k.AsString())
+		if keyLooksSensitive(k.AsString()) {
+			h.value(address, path, item.ValueExpr, hcl.RangeBetween(item.KeyExpr.Range(), item.ValueExpr.Range()), false)
+			continue
+		}
+		h.objectItems(address, path, item.ValueExpr)
+	}
+}
+
+// value emits the source text in r if expr is a constant containing a string.
+func (h tfHCL) value(address, attribute string, expr hclsyntax.Expression, r hcl.Range, sensitive bool) {
+	if len(expr.Variables()) > 0 {
+		return
+	}
+	val, diags := expr.Value(nil)
+	if diags.HasErrors() || !val.IsWhollyKnown() || !ctyHasString(val) {
+		return
+	}
+	if r.Start.Byte < 0 || r.End.Byte > len(h.src) || r.Start.Byte >= r.End.Byte {
+		return
+	}
+	vpath := h.rel + "::" + joinTFPath(address, attribute)
+	h.emit(tfEntry(vpath, address, attribute, string(h.src[r.Start.Byte:r.End.Byte]), sensitive, r.Start.Line))
+}
+
+func sortedTFAttributes(body *hclsyntax.Body) []*hclsyntax.Attribute {
+	attrs := make([]*hclsyntax.Attribute, 0, len(body.Attributes))
+	for _, a := range body.Attributes {
+		attrs = append(attrs, a)
+	}
+	sort.Slice(attrs, func(i, j int) bool { return attrs[i].SrcRange.Start.Byte < attrs[j].SrcRange.Start.Byte })
+	return attrs
+}
+
+// tfSensitiveFlag reports whether a variable or output sets sensitive = true.
+func tfSensitiveFlag(body *hclsyntax.Body) bool {
+	attr, ok := body.Attributes["sensitive"]
+	if !ok {
+		return false
+	}
+	v, diags := attr.Expr.Value(nil)
+	return !diags.HasErrors() && v.Type() == cty.Bool && v.IsKnown() && !v.IsNull() && v.True()
+}
+
+func ctyHasString(v cty.Value) bool {
+	if v.IsNull() {
+		return false
+	}
+	t := v.Type()
+	switch {
+	case t == cty.String:
+		return v.AsString() != ""
+	case t.IsObjectType() || t.IsMapType() || t.IsListType() || t.IsTupleType() || t.IsSetType():
+		for it := v.ElementIterator(); it.Next(); {
+			_, ev := it.Element()
+			if ctyHasString(ev) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tfStateV4 is the part of a version 4 Terraform state file needed to find
+// secrets.
+type tfStateV4 struct {
+	Version int `json:"version"`
+	Outputs map[string]struct {
+		Value     any  `json:"value"`
+		Sensitive bool `json:"sensitive"`
+	} `json:"outputs"`
+	Resources []struct {
+		Module    string `json:"module"`
+		Mode      string `json:"mode"`
+		Type      string `json:"type"`
+		Name      string `json:"name"`
+		Instances []struct {
+			IndexKey            any               `json:"index_key"`
+			Attributes          map[string]any    `json:"attributes"`
+			SensitiveAttributes []json.RawMessage `json:"sensitive_attributes"`
+		} `json:"instances"`
+	} `json:"resources"`
+}
+
+// scanTerraformState emits the values in a version 4 state that Terraform
+// marks sensitive (sensitive_attributes, sensitive outputs) or whose names
+// look like secrets, at rel::json:<path in the state>. It returns false for
+// other state versions.
+func scanTerraformState(rel string, b []byte, emit EntryFunc) bool {
+	var st tfStateV4
+	if json.Unmarshal(b, &st) != nil || st.Version != 4 {
+		return false
+	}
+	for ri, res := range st.Resources {
+		address := res.Type + "." + res.Name
+		if res.Mode == "data" {
+			address = "data." + address
+		}
+		if res.Module != "" {
+			address = res.Module + "." + address
+		}
+		for ii, inst := range res.Instances {
+			addr := address + tfIndexKey(inst.IndexKey)
+			mask := tfSensitiveMask(inst.SensitiveAttributes)
+			jsonBase := fmt.Sprintf("resources[%d].instances[%d].attributes", ri, ii)
+			walkTFValues("", inst.Attributes, mask, false, func(attr, value string, sensitive bool) {
+				emit(tfEntry(rel+"::json:"+joinTFPath(jsonBase, attr), addr, attr, addr+"."+attr+": "+value, sensitive, 0))
+			})
+		}
+	}
+	for _, name := range sortedKeys(st.Outputs) {
+		out := st.Outputs[name]
+		if !out.Sensitive && !keyLooksSensitive(name) {
+			continue
+		}
+		walkTFValues("", out.Value, true, false, func(attr, value string, _ bool) {
+			jsonPath := joinTFPath("outputs."+name+".value", attr)
+			emit(tfEntry(rel+"::json:"+jsonPath, "output."+name, attr, "output."+joinTFPath(name, attr)+": "+value, out.Sensitive, 0))
+		})
+	}
+	return true
+}
+
+// tfPlan is the part of `terraform show -json` plan output needed to find
+// secrets.
+type tfPlan struct {
+	Variables map[string]struct {
+		Value any `json:"value"`
+	} `json:"variables"`
+	ResourceChanges []struct {
+		Address string `json:"address"`
+		Change  struct {
+			Before          any `json:"before"`
+			After           any `json:"after"`
+			BeforeSensitive any `json:"before_sensitive"`
+			AfterSensitive  any `json:"after_sensitive"`
+		} `json:"change"`
+	} `json:"resource_changes"`
+	OutputChanges map[string]struct {
+		After          any `json:"after"`
+		AfterSensitive any `json:"after_sensitive"`
+	} `json:"output_changes"`
+}
+
+// scanTerraformPlan emits input variables, values of resource changes that
+// the plan marks sensitive or whose names look like secrets, and sensitive
+// outputs, at rel::json:<path in the plan>.
+func scanTerraformPlan(rel string, b []byte, emit EntryFunc) bool {
+	var plan tfPlan
+	if json.Unmarshal(b, &plan) != nil {
+		return false
+	}
+	for _, name := range sortedKeys(plan.Variables) {
+		walkTFValues("", plan.Variables[name].Value, true, false, func(attr, value string, _ bool) {
+			jsonPath := joinTFPath("variables."+name+".value", attr)
+			emit(tfEntry(rel+"::json:"+jsonPath, "var."+name, attr, "var."+joinTFPath(name, attr)+": "+value, false, 0))
+		})
+	}
+	for i, rc := range plan.ResourceChanges {
+		for _, side := range []struct {
+			name   string
+			values any
+			mask   any
+		}{
+			{"before", rc.Change.Before, rc.Change.BeforeSensitive},
+			{"after", rc.Change.After, rc.Change.AfterSensitive},
+		} {
+			jsonBase := fmt.Sprintf("resource_changes[%d].change.%s", i, side.name)
+			walkTFValues("", side.values, side.mask, false, func(attr, value string, sensitive bool) {
+				emit(tfEntry(rel+"::json:"+joinTFPath(jsonBase, attr), rc.Address, attr, rc.Address+"."+attr+": "+value, sensitive, 0))
+			})
+		}
+	}
+	for _, name := range sortedKeys(plan.OutputChanges) {
+		oc := plan.OutputChanges[name]
+		if !tfMaskSet(oc.AfterSensitive) && !keyLooksSensitive(name) {
+			continue
+		}
+		walkTFValues("", oc.After, true, false, func(attr, value string, _ bool) {
+			jsonPath := joinTFPath("output_changes."+name+".after", attr)
+			emit(tfEntry(rel+"::json:"+jsonPath, "output."+name, attr, "output."+joinTFPath(name, attr)+": "+value, tfMaskSet(oc.AfterSensitive), 0))
+		})
+	}
+	return true
+}
+
+// walkTFValues calls fn for each non-empty string leaf of v that is marked in
+// mask or sits under a key that looks like a secret. mask mirrors v: true
+// marks a whole subtree, maps and slices mark children (slices may also be
+// masked by a map keyed by index, as built by tfSensitiveMask).
+func walkTFValues(path string, v, mask any, named bool, fn func(attr, value string, sensitive bool)) {
+	sensitive := tfMaskSet(mask)
+	switch val := v.(type) {
+	case string:
+		if val != "" && (sensitive || named) {
+			fn(path, val, sensitive)
+		}
+	case map[string]any:
+		for _, k := range sortedKeys(val) {
+			walkTFValues(joinTFPath(path, k), val[k], tfMaskChild(mask, k, -1), named || keyLooksSensitive(k), fn)
+		}
+	case []any:
+		for i, item := range val {
+			walkTFValues(path+"["+strconv.Itoa(i)+"]", item, tfMaskChild(mask, strconv.Itoa(i), i), named, fn)
+		}
+	}
+}
+
+func tfMaskSet(mask any) bool {
+	b, ok := mask.(bool)
+	return ok && b
+}
+
+func tfMaskChild(mask any, key string, index int) any {
+	switch m := mask.(type) {
+	case bool:
+		if m {
+			return true
+		}
+	case map[string]any:
+		return m[key]
+	case []any:
+		if index >= 0 && index < len(m) {
+			return m[index]
+		}
+	}
+	return nil
+}
+
+// tfSensitiveMask converts state v4 sensitive_attributes, a list of paths
+// such as [{"type":"get_attr","value":"password"}], into a mask for
+// walkTFValues.
+func tfSensitiveMask(paths []json.RawMessage) any {
+	root := map[string]any{}
+	for _, raw := range paths {
+		var steps []struct {
+			Type  string          `json:"type"`
+			Value json.RawMessage `json:"value"`
+		}
+		if json.Unmarshal(raw, &steps) != nil || len(steps) == 0 {
+			continue
+		}
+		node := root
+		for i, s := range steps {
+			var key string
+			if s.Type == "index" {
+				var idx struct {
+					Value any `json:"value"`
+				}
+				if json.Unmarshal(s.Value, &idx) != nil {
+					break
+				}
+				key = fmt.Sprint(idx.Value)
+			} else if json.Unmarshal(s.Value, &key) != nil {
+				break
+			}
+			if i == len(steps)-1 {
+				node[key] = true
+				break
+			}
+			next, ok := node[key].(map[string]any)
+			if !ok {
+				if node[key] == true {
+					break
+				}
+				next = map[string]any{}
+				node[key] = next
+			}
+			node = next
+		}
+	}
+	return root
+}
+
+// tfIndexKey formats a count or for_each instance key as Terraform does in
+// addresses: [0] or ["key"].
+func tfIndexKey(k any) string {
+	switch v := k.(type) {
+	case nil:
+		return ""
+	case string:
+		return "[" + strconv.Quote(v) + "]"
+	case float64:
+		return "[" + strconv.FormatFloat(v, 'f', -1, 64) + "]"
+	}
+	return "[" + fmt.Sprint(k) + "]"
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,45 @@
+package artifacts
+
+import (
+	"bytes"
+	"errors"
+
+	"github.com/bodgit/sevenzip"
+)
+
+// DetectorUnscannableContent reports content inside an artifact that could
+// not be scanned: encrypted archive entries and PDFs, and corrupt or
+// truncated archives.
+const DetectorUnscannableContent = "unscannable-content"
+
+// Unscannable is content inside an artifact that could not be scanned.
+type Unscannable struct {
+	Path   string // virtual path of the entry or archive
+	Reason string
+}
+
+// unscannable records content at path that could not be scanned.
+func (s *Stats) unscannable(path, reason string) {
+	if s == nil {
+		return
+	}
+	s.Unscannable = append(s.Unscannable, Unscannable{Path: path, Reason: reason})
+}
+
+// unscannableReason returns why the binary entry content b cannot be
+// scanned, or "" if it is not a format known to hide secrets.
+func unscannableReason(b []byte) string {
+	if bytes.HasPrefix(b, []byte("%PDF-")) && bytes.Contains(b, []byte("/Encrypt")) {
+		return "encrypted PDF"
+	}
+	return ""
+}
+
+// unreadableReason describes an error that stopped an entry from being read.
+func unreadableReason(err error) string {
+	var re *sevenzip.ReadError
+	if errors.As(err, &re) && re.Encrypted {
+		return "encrypted 7z entry"
+	}
+	return "unreadable entry: " + err.Error()
+}
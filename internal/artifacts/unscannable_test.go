@@ -0,0 +1,101 @@
+package artifacts
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/bodgit/sevenzip"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnscannableReason(t *testing.T) {
+	for name, tc := range map[string]struct {
+		data []byte
+		want string
+	}{
+		"encrypted pdf": {[]byte("%PDF-1.7\n1 0 obj\n<< /Encrypt 5 0 R >>\n\x00"), "encrypted PDF"},
+		"plain pdf":     {[]byte("%PDF-1.7\n1 0 obj\n<< /Type /Catalog >>\n\x00"), ""},
+		"der":           {[]byte{0x30, 0x82, 0x01, 0x00, 0x30, 0x82}, ""},
+	} {
+		assert.Equal(t, tc.want, unscannableReason(tc.data), name)
+	}
+}
+
+func TestUnreadableReason(t *testing.T) {
+	assert.Equal(t, "encrypted 7z entry", unreadableReason(&sevenzip.ReadError{Encrypted: true, Err: errors.New("bad password")}))
+	assert.Equal(t, "unreadable entry: unexpected EOF", unreadableReason(errors.New("unexpected EOF")))
+}
+
+func TestScanArchives_ReportsUnscannableContent(t *testing.T) {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	// Bit 0 of the flags marks an entry as encrypted.
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "secrets.txt", Method: zip.Store, Flags: 0x1})
+	require.NoError(t, err)
+	_, err = w.Write([]byte("ciphertext"))
+	require.NoError(t, err)
+	for name, data := range map[string][]byte{
+		"docs/audit.pdf": []byte("%PDF-1.4\n<< /Encrypt 3 0 R >>\n\x00"),
+		"inner.tgz":      []byte("\x00this is not a gzip stream"),
+		"readme.txt":     []byte("hello\n"),
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+
+	got, stats := scanArchiveFiles(t, map[string][]byte{"bundle.zip": buf.Bytes()})
+	assert.Equal(t, map[string]string{"bundle.zip::readme.txt": "hello\n"}, got)
+	byPath := map[string]string{}
+	for _, u := range stats.Unscannable {
+		byPath[u.Path] = u.Reason
+	}
+	assert.Equal(t, map[string]string{
+		"bundle.zip::secrets.txt":    "encrypted zip entry",
+		"bundle.zip::docs/audit.pdf": "encrypted PDF",
+		"bundle.zip::inner.tgz":      "corrupt gzip: gzip: invalid header",
+	}, byPath)
+}
+
+func TestScanArchives_ReportsCorruptArchive(t *testing.T) {
+	tgz := compressBytes(t, "gzip", tarBytes(t, map[string]string{"a.txt": "a", "b.txt": "b"}))
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"truncated.tgz": string(tgz[:len(tgz)/2])})
+	var stats Stats
+	err := ScanArchivesWithStats(dir, Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 10, MaxDepth: 2}, nil, func(string, []byte) {}, &stats)
+	require.Error(t, err)
+	require.Len(t, stats.Unscannable, 1)
+	assert.Equal(t, "truncated.tgz", stats.Unscannable[0].Path)
+	assert.Contains(t, stats.Unscannable[0].Reason, "corrupt")
+}
+
+func TestScanArchives_TruncatedEntryIsStillScanned(t *testing.T) {
+	var big strings.Builder
+	big.WriteString("password=hunter2\n")
+	for i := 0; big.Len() < 256<<10; i++ {
+		fmt.Fprintf(&big, "line %d %x\n", i, i*i*7919)
+	}
+	tgz := compressBytes(t, "gzip", tarBytes(t, map[string]string{"big.txt": big.String()}))
+	dir := t.TempDir()
+	writeFiles(t, dir, map[string]string{"truncated.tgz": string(tgz[:len(tgz)/2])})
+	got := map[string]string{}
+	var stats Stats
+	err := ScanArchivesWithStats(dir, Limits{MaxArchiveBytes: 1 << 20, MaxEntries: 10, MaxDepth: 2}, nil, func(p string, b []byte) { got[p] = string(b) }, &stats)
+	require.Error(t, err)
+
+	part := got["truncated.tgz::big.txt"]
+	assert.True(t, strings.HasPrefix(part, "password=hunter2\n"), "readable part is emitted")
+	assert.Less(t, len(part), big.Len())
+	paths := map[string]bool{}
+	for _, u := range stats.Unscannable {
+		paths[u.Path] = true
+	}
+	assert.True(t, paths["truncated.tgz::big.txt"], "truncated entry is still reported: %+v", stats.Unscannable)
+}
@@ -0,0 +1,284 @@
+package artifacts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// maxDecoderWindow bounds the history a zstd or xz decoder may allocate,
+// which the stream itself declares: up to 512 MiB for zstd and 4 GiB for xz.
+// It is the dictionary of xz -9, the largest xz preset, which xz declares
+// whatever the size of its input. zstd shrinks its window to small inputs,
+// so for zstd Limits.MaxArchiveBytes lowers the bound further, but never
+// below minDecoderWindow, the window of the default zstd levels.
+const (
+	maxDecoderWindow = 64 << 20
+	minDecoderWindow = 8 << 20
+)
+
+// errDecoderWindow is returned for xz streams that declare a dictionary
+// larger than maxDecoderWindow.
+var errDecoderWindow = errors.New("decoder window too large")
+
+// zstdDecoderWindow returns the largest window a zstd stream is decoded
+// with under limits.
+func zstdDecoderWindow(limits Limits) int64 {
+	w := int64(maxDecoderWindow)
+	if limits.MaxArchiveBytes > 0 && limits.MaxArchiveBytes < w {
+		w = limits.MaxArchiveBytes
+	}
+	return max(w, minDecoderWindow)
+}
+
+var xzStreamMagic = []byte{0xfd, '7', 'z', 'X', 'Z', 0x00}
+
+// xzDictGuard passes an xz stream through unchanged while walking its
+// container format, and fails before handing on a block header whose LZMA2
+// dictionary exceeds max, which the xz decoder would otherwise allocate up
+// front. It reads one header, LZMA2 chunk or index record ahead of the
+// decoder; the block data itself is not decompressed.
+type xzDictGuard struct {
+	r       *bufio.Reader
+	max     int64
+	pending []byte
+	err     error
+	step    func() error
+
+	checkSize int   // bytes of the integrity check after each block
+	n         int64 // bytes of the current block or index so far
+	records   uint64
+}
+
+func newXZDictGuard(r io.Reader, max int64) *xzDictGuard {
+	g := &xzDictGuard{r: bufio.NewReader(r), max: max}
+	g.step = g.streamHeader
+	return g
+}
+
+func (g *xzDictGuard) Read(p []byte) (int, error) {
+	for len(g.pending) == 0 && g.err == nil {
+		if g.err = g.step(); g.err != nil {
+			g.pending = nil
+		}
+	}
+	if len(g.pending) == 0 {
+		return 0, g.err
+	}
+	n := copy(p, g.pending)
+	g.pending = g.pending[n:]
+	return n, nil
+}
+
+// read reads n bytes from the stream for the decoder and returns them.
+func (g *xzDictGuard) read(n int) ([]byte, error) {
+	start := len(g.pending)
+	g.pending = append(g.pending, make([]byte, n)...)
+	if _, err := io.ReadFull(g.r, g.pending[start:]); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	g.n += int64(n)
+	return g.pending[start:], nil
+}
+
+// varint reads a multibyte integer of the xz format.
+func (g *xzDictGuard) varint() (uint64, error) {
+	var v uint64
+	for i := 0; i < 9; i++ {
+		b, err := g.read(1)
+		if err != nil {
+			return 0, err
+		}
+		v |= uint64(b[0]&0x7f) << (7 * i)
+		if b[0]&0x80 == 0 {
+			return v, nil
+		}
+	}
+	return 0, errors.New("xz: bad multibyte integer")
+}
+
+func (g *xzDictGuard) streamHeader() error {
+	h, err := g.read(12)
+	if err != nil {
+		return err
+	}
+	if !bytes.Equal(h[:6], xzStreamMagic) {
+		return errors.New("xz: bad stream header")
+	}
+	if id := h[7] & 0x0f; id > 0 {
+		g.checkSize = 4 << ((id - 1) / 3)
+	} else {
+		g.checkSize = 0
+	}
+	g.step = g.blockOrIndex
+	return nil
+}
+
+func (g *xzDictGuard) blockOrIndex() error {
+	g.n = 0
+	b, err := g.read(1)
+	if err != nil {
+		return err
+	}
+	if b[0] == 0 {
+		records, err := g.varint()
+		if err != nil {
+			return err
+		}
+		g.records = records
+		g.step = g.indexRecord
+		return nil
+	}
+	h, err := g.read((int(b[0])+1)*4 - 1)
+	if err != nil {
+		return err
+	}
+	if err := g.checkBlockHeader(h[:len(h)-4]); err != nil {
+		return err
+	}
+	g.step = g.lzma2Chunk
+	return nil
+}
+
+// checkBlockHeader checks the filter flags in a block header, h being the
+// header after its size byte and without its CRC32.
+func (g *xzDictGuard) checkBlockHeader(h []byte) error {
+	hr := bytes.NewReader(h)
+	flags, err := hr.ReadByte()
+	if err != nil {
+		return errors.New("xz: bad block header")
+	}
+	uvarint := func() uint64 {
+		v, e := binary.ReadUvarint(hr)
+		err = errors.Join(err, e)
+		return v
+	}
+	if flags&0x40 != 0 {
+		uvarint() // compressed size
+	}
+	if flags&0x80 != 0 {
+		uvarint() // uncompressed size
+	}
+	for i := 0; i <= int(flags&0x03); i++ {
+		id := uvarint()
+		props := make([]byte, min(uvarint(), uint64(hr.Len())))
+		_, _ = io.ReadFull(hr, props)
+		if err != nil {
+			return errors.New("xz: bad block header")
+		}
+		if id == 0x21 && len(props) == 1 {
+			if dict := xzDictSize(props[0]); dict > g.max {
+				return fmt.Errorf("%w: xz dictionary of %d bytes", errDecoderWindow, dict)
+			}
+		}
+	}
+	return nil
+}
+
+// xzDictSize decodes the dictionary size of an LZMA2 filter.
+func xzDictSize(c byte) int64 {
+	if c >= 40 {
+		return 1<<32 - 1
+	}
+	return int64(2|c&1) << (c/2 + 11)
+}
+
+// lzma2Chunk skips one LZMA2 chunk of the block being read. Chunk headers
+// give the size of their compressed data, so nothing is decoded.
+func (g *xzDictGuard) lzma2Chunk() error {
+	c, err := g.read(1)
+	if err != nil {
+		return err
+	}
+	var size int
+	switch control := c[0]; {
+	case control == 0:
+		g.step = g.blockPadding
+		return nil
+	case control == 1 || control == 2:
+		h, err := g.read(2)
+		if err != nil {
+			return err
+		}
+		size = int(binary.BigEndian.Uint16(h)) + 1
+	case control >= 0x80:
+		h, err := g.read(4)
+		if err != nil {
+			return err
+		}
+		size = int(binary.BigEndian.Uint16(h[2:])) + 1
+		if control >= 0xc0 {
+			size++ // properties byte
+		}
+	default:
+		return errors.New("xz: bad LZMA2 chunk")
+	}
+	_, err = g.read(size)
+	return err
+}
+
+func (g *xzDictGuard) blockPadding() error {
+	if _, err := g.read(int(pad4(g.n)) + g.checkSize); err != nil {
+		return err
+	}
+	g.step = g.blockOrIndex
+	return nil
+}
+
+func (g *xzDictGuard) indexRecord() error {
+	if g.records == 0 {
+		if _, err := g.read(int(pad4(g.n)) + 4); err != nil {
+			return err
+		}
+		g.step = g.streamFooter
+		return nil
+	}
+	g.records--
+	if _, err := g.varint(); err != nil {
+		return err
+	}
+	_, err := g.varint()
+	return err
+}
+
+func (g *xzDictGuard) streamFooter() error {
+	f, err := g.read(12)
+	if err != nil {
+		return err
+	}
+	if string(f[10:]) != "YZ" {
+		return errors.New("xz: bad stream footer")
+	}
+	g.step = g.streamPadding
+	return nil
+}
+
+// streamPadding reads the zero padding after a stream, then either ends or
+// moves on to the next concatenated stream.
+func (g *xzDictGuard) streamPadding() error {
+	b, err := g.r.Peek(1)
+	if errors.Is(err, io.EOF) {
+		return io.EOF
+	}
+	if err != nil {
+		return err
+	}
+	if b[0] != 0 {
+		g.step = g.streamHeader
+		return nil
+	}
+	_, err = g.read(4)
+	return err
+}
+
+// unexpectedEOF turns io.EOF in the middle of a structure into
+// io.ErrUnexpectedEOF.
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
@@ -0,0 +1,83 @@
+package artifacts
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/ulikunitz/xz"
+)
+
+func TestXZDictGuard_PassesStreamsThrough(t *testing.T) {
+	var data bytes.Buffer
+	for i := 0; data.Len() < 256<<10; i++ {
+		fmt.Fprintf(&data, "line %d %x\n", i, i*i*7919)
+	}
+	var multi bytes.Buffer
+	w, err := xz.WriterConfig{BlockSize: 32 << 10, CheckSum: xz.SHA256}.NewWriter(&multi)
+	require.NoError(t, err)
+	_, err = w.Write(data.Bytes())
+	require.NoError(t, err)
+	require.NoError(t, w.Close())
+
+	single := compressBytes(t, "xz", []byte("token=abc\n"))
+	concatenated := append(append(append([]byte{}, single...), 0, 0, 0, 0), single...)
+
+	for name, tc := range map[string]struct {
+		stream []byte
+		want   string
+	}{
+		"multiple blocks":      {multi.Bytes(), data.String()},
+		"concatenated streams": {concatenated, "token=abc\ntoken=abc\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			passed, err := io.ReadAll(newXZDictGuard(bytes.NewReader(tc.stream), maxDecoderWindow))
+			require.NoError(t, err)
+			assert.Equal(t, tc.stream, passed)
+
+			dr, err := decompress("xz", bytes.NewReader(tc.stream), Limits{})
+			require.NoError(t, err)
+			got, err := io.ReadAll(dr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.want, string(got))
+		})
+	}
+}
+
+func TestDecompress_RejectsLargeWindows(t *testing.T) {
+	t.Run("xz", func(t *testing.T) {
+		stream := compressBytes(t, "xz", []byte("token=abc\n"))
+		// The LZMA2 filter flags (ID 0x21, one property byte) in the first
+		// block header; 40 declares a 4 GiB dictionary.
+		i := bytes.Index(stream[12:], []byte{0x21, 0x01})
+		require.GreaterOrEqual(t, i, 0)
+		stream[12+i+2] = 40
+
+		dr, err := decompress("xz", bytes.NewReader(stream), Limits{})
+		if err == nil {
+			_, err = io.ReadAll(dr)
+		}
+		assert.ErrorIs(t, err, errDecoderWindow)
+	})
+
+	t.Run("zstd", func(t *testing.T) {
+		var buf bytes.Buffer
+		w, err := zstd.NewWriter(&buf, zstd.WithSingleSegment(false))
+		require.NoError(t, err)
+		_, err = w.Write([]byte("token=abc\n"))
+		require.NoError(t, err)
+		require.NoError(t, w.Close())
+		stream := buf.Bytes()
+		require.Zero(t, stream[4]&0x20, "frame has a window descriptor")
+		stream[5] = 18 << 3 // 256 MiB
+
+		dr, err := decompress("zstd", bytes.NewReader(stream), Limits{MaxArchiveBytes: 32 << 20})
+		require.NoError(t, err)
+		_, err = io.ReadAll(dr)
+		assert.ErrorIs(t, err, zstd.ErrWindowSizeExceeded)
+	})
+}
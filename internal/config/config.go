@@ -26,21 +26,52 @@ type FileConfig struct {
 	DisableStructured *string  `yaml:"disable_structured"`
 
 	// Deep scanning config mirrors CLI flags
-	Archives             *bool   `yaml:"archives"`
-	Containers           *bool   `yaml:"containers"`
-	IaC                  *bool   `yaml:"iac"`
-	Helm                 *bool   `yaml:"helm"`
-	K8s                  *bool   `yaml:"k8s"`
-	MaxArchiveBytes      *int64  `yaml:"max_archive_bytes"`
-	MaxEntries           *int    `yaml:"max_entries"`
-	MaxDepth             *int    `yaml:"max_depth"`
-	ScanTimeBudget       *string `yaml:"scan_time_budget"`
-	GlobalArtifactBudget *string `yaml:"global_artifact_budget"`
+	Archives             *bool    `yaml:"archives"`
+	Containers           *bool    `yaml:"containers"`
+	IaC                  *bool    `yaml:"iac"`
+	Binaries             *bool    `yaml:"binaries"`
+	Helm                 *bool    `yaml:"helm"`
+	HelmRender           *bool    `yaml:"helm_render"`
+	HelmValues           []string `yaml:"helm_values"`
+	K8s                  *bool    `yaml:"k8s"`
+	Kustomize            *bool    `yaml:"kustomize"`
+	MaxArchiveBytes      *int64   `yaml:"max_archive_bytes"`
+	MaxEntries           *int     `yaml:"max_entries"`
+	MaxDepth             *int     `yaml:"max_depth"`
+	MaxEntryRatio        *int     `yaml:"max_entry_ratio"`
+	MaxArchiveRatio      *int     `yaml:"max_archive_ratio"`
+	ScanTimeBudget       *string  `yaml:"scan_time_budget"`
+	GlobalArtifactBudget *string  `yaml:"global_artifact_budget"`
+
+	// Severity policy for decoded JWTs: info, low, medium, high or unchanged
+	JWTExpiredSeverity *string `yaml:"jwt_expired_severity"`
+	JWTAlgNoneSeverity *string `yaml:"jwt_alg_none_severity"`
+
+	// Decoding of base64, hex and URL-encoded blobs (off unless decode_depth is set)
+	DecodeDepth     *int `yaml:"decode_depth"`
+	DecodeMinLength *int `yaml:"decode_min_length"`
+
+	// Sensitive-path detection config
+	SensitivePaths *SensitivePathsConfig `yaml:"sensitive_paths"`
 
 	// Gitleaks integration config
 	Gitleaks *GitleaksConfig `yaml:"gitleaks"`
 }
 
+// SensitivePathsConfig configures the sensitive-path detector, which reports
+// files such as .ssh/id_rsa or .aws/credentials by their path.
+type SensitivePathsConfig struct {
+	// Patterns are extra doublestar globs of paths to report, relative to
+	// the scan root or to the root of an archive or image layer.
+	Patterns []string `yaml:"patterns"`
+
+	// Exclude lists globs of paths that are never reported.
+	Exclude []string `yaml:"exclude"`
+
+	// DisableDefaults drops the built-in rules, leaving only Patterns.
+	DisableDefaults *bool `yaml:"disable_defaults"`
+}
+
 // GitleaksConfig holds configuration for Gitleaks integration.
 type GitleaksConfig struct {
 	// ConfigPath is the path to a .gitleaks.toml configuration file.
@@ -0,0 +1,134 @@
+package engine
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/varalys/redactyl/internal/scanner"
+)
+
+// defaultDecodeMinLength is the shortest encoded blob decoded when
+// Config.DecodeMinLength is 0.
+const defaultDecodeMinLength = 20
+
+var (
+	base64BlobRx = regexp.MustCompile(`[A-Za-z0-9+/_-]+={0,2}`)
+	hexBlobRx    = regexp.MustCompile(`\b[0-9A-Fa-f]+\b`)
+	urlBlobRx    = regexp.MustCompile(`[^\s"'<>]*%[0-9A-Fa-f]{2}[^\s"'<>]*`)
+)
+
+// decodedBlob is the decoded content of the encoded blobs on one line.
+type decodedBlob struct {
+	line     int
+	encoding string
+	data     []byte
+}
+
+// decodeInputs finds base64, hex and percent-encoded blobs of at least
+// minLen characters in an input, decodes those whose content is text, and
+// returns the decoded content as inputs to scan at in.Path::line-N::<enc>,
+// decoding them in turn up to depth levels. Blobs of the same encoding on
+// one line are scanned together. The encodings applied, outermost first,
+// are recorded in the "encoding_chain" metadata.
+func decodeInputs(in scanner.BatchInput, depth, minLen int) []scanner.BatchInput {
+	if depth <= 0 {
+		return nil
+	}
+	if minLen <= 0 {
+		minLen = defaultDecodeMinLength
+	}
+	var blobs []*decodedBlob
+	byKey := map[string]*decodedBlob{}
+	for i, line := range bytes.Split(in.Data, []byte("\n")) {
+		lineNo := i + 1 + in.Context.LineOffset
+		decodeLine(line, minLen, func(enc string, data []byte) {
+			key := fmt.Sprintf("%d/%s", lineNo, enc)
+			if b, ok := byKey[key]; ok {
+				b.data = append(append(b.data, '\n'), data...)
+				return
+			}
+			b := &decodedBlob{line: lineNo, encoding: enc, data: data}
+			byKey[key] = b
+			blobs = append(blobs, b)
+		})
+	}
+	var out []scanner.BatchInput
+	chain := in.Context.Metadata["encoding_chain"]
+	for _, b := range blobs {
+		p := fmt.Sprintf("%s::line-%d::%s", in.Path, b.line, b.encoding)
+		meta := cloneMetadata(in.Context.Metadata)
+		meta["encoding_chain"] = b.encoding
+		if chain != "" {
+			meta["encoding_chain"] = chain + "," + b.encoding
+		}
+		ctx := scanner.ScanContext{VirtualPath: p, RealPath: in.Context.RealPath, Metadata: meta}
+		child := makeBatchInput(p, b.data, &ctx)
+		out = append(out, child)
+		out = append(out, decodeInputs(child, depth-1, minLen)...)
+	}
+	return out
+}
+
+// decodeLine calls fn with the encoding and decoded content of each blob on
+// line that decodes to text.
+func decodeLine(line []byte, minLen int, fn func(enc string, data []byte)) {
+	seen := map[string]bool{}
+	try := func(blob []byte, enc string, decode func(string) ([]byte, error)) {
+		if len(blob) < minLen || seen[string(blob)] {
+			return
+		}
+		out, err := decode(string(blob))
+		if err != nil || !isText(out) || bytes.Equal(out, blob) {
+			return
+		}
+		seen[string(blob)] = true
+		fn(enc, out)
+	}
+	for _, blob := range hexBlobRx.FindAll(line, -1) {
+		if len(blob)%2 == 0 {
+			try(blob, "hex", hex.DecodeString)
+		}
+	}
+	for _, blob := range base64BlobRx.FindAll(line, -1) {
+		try(blob, "base64", decodeBase64)
+	}
+	for _, blob := range urlBlobRx.FindAll(line, -1) {
+		try(blob, "url", func(s string) ([]byte, error) {
+			out, err := url.PathUnescape(s)
+			return []byte(out), err
+		})
+	}
+}
+
+// decodeBase64 decodes standard or URL-safe base64, padded or not.
+func decodeBase64(s string) ([]byte, error) {
+	enc := base64.StdEncoding
+	if strings.ContainsAny(s, "-_") {
+		enc = base64.URLEncoding
+	}
+	if !strings.HasSuffix(s, "=") {
+		enc = enc.WithPadding(base64.NoPadding)
+	}
+	return enc.DecodeString(s)
+}
+
+// isText reports whether b is UTF-8 text without control characters other
+// than whitespace, as opposed to the bytes a random token decodes to.
+func isText(b []byte) bool {
+	if len(b) == 0 || !utf8.Valid(b) {
+		return false
+	}
+	for _, r := range string(b) {
+		if !unicode.IsPrint(r) && r != '\t' && r != '\n' && r != '\r' {
+			return false
+		}
+	}
+	return true
+}
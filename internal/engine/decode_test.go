@@ -0,0 +1,106 @@
+package engine
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"net/url"
+	"testing"
+
+	"github.com/varalys/redactyl/internal/scanner"
+	"github.com/varalys/redactyl/internal/types"
+)
+
+const decodeSecret = "token=sk_live_0123456789abcdef"
+
+func TestDecodeInputs_Base64(t *testing.T) {
+	data := "APP=demo\nPAYLOAD=" + base64.StdEncoding.EncodeToString([]byte(decodeSecret)) + "\n"
+	got := decodeInputs(makeBatchInput("file.env", []byte(data), nil), 2, 0)
+	if len(got) != 1 {
+		t.Fatalf("expected one decoded input, got %+v", got)
+	}
+	if got[0].Path != "file.env::line-2::base64" || string(got[0].Data) != decodeSecret {
+		t.Fatalf("unexpected decoded input: %s %q", got[0].Path, got[0].Data)
+	}
+	if got[0].Context.Metadata["encoding_chain"] != "base64" || got[0].Context.RealPath != "file.env" {
+		t.Fatalf("unexpected context: %+v", got[0].Context)
+	}
+}
+
+func TestDecodeInputs_Nested(t *testing.T) {
+	inner := hex.EncodeToString([]byte(decodeSecret))
+	data := "value: " + base64.RawURLEncoding.EncodeToString([]byte(inner)) + "\n"
+	ctx := scanner.ScanContext{LineOffset: 10}
+	got := decodeInputs(makeBatchInput("values.yaml", []byte(data), &ctx), 2, 0)
+	if len(got) != 2 {
+		t.Fatalf("expected two decoded inputs, got %+v", got)
+	}
+	if got[0].Path != "values.yaml::line-11::base64" || string(got[0].Data) != inner {
+		t.Fatalf("unexpected outer input: %s %q", got[0].Path, got[0].Data)
+	}
+	if got[1].Path != "values.yaml::line-11::base64::line-1::hex" || string(got[1].Data) != decodeSecret {
+		t.Fatalf("unexpected inner input: %s %q", got[1].Path, got[1].Data)
+	}
+	if got[1].Context.Metadata["encoding_chain"] != "base64,hex" {
+		t.Fatalf("unexpected encoding chain: %q", got[1].Context.Metadata["encoding_chain"])
+	}
+
+	// Depth 1 stops after the outer layer.
+	if got := decodeInputs(makeBatchInput("values.yaml", []byte(data), &ctx), 1, 0); len(got) != 1 {
+		t.Fatalf("expected one decoded input at depth 1, got %+v", got)
+	}
+}
+
+func TestDecodeInputs_URL(t *testing.T) {
+	data := "redirect=https://example.com/cb?state=" + url.QueryEscape("a b&"+decodeSecret) + "\n"
+	got := decodeInputs(makeBatchInput("access.log", []byte(data), nil), 1, 0)
+	if len(got) != 1 || got[0].Path != "access.log::line-1::url" {
+		t.Fatalf("unexpected decoded inputs: %+v", got)
+	}
+	if string(got[0].Data) != "redirect=https://example.com/cb?state=a+b&"+decodeSecret {
+		t.Fatalf("unexpected decoded data: %q", got[0].Data)
+	}
+}
+
+func TestDecodeInputs_Skips(t *testing.T) {
+	for name, data := range map[string]string{
+		"random token":  "API_TOKEN=Zx9QmT4vLp2Rw8Ks7Nd3Yh6Bj1Fc5Ga0\n",
+		"hex digest":    "sha256=9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08\n",
+		"short base64":  "x=" + base64.StdEncoding.EncodeToString([]byte("hi there")) + "\n",
+		"plain english": "the quick brown fox jumps over the lazy dog\n",
+	} {
+		if got := decodeInputs(makeBatchInput("f.txt", []byte(data), nil), 2, 0); len(got) != 0 {
+			t.Fatalf("%s: expected nothing decoded, got %+v", name, got)
+		}
+	}
+	data := "PAYLOAD=" + base64.StdEncoding.EncodeToString([]byte(decodeSecret)) + "\n"
+	if got := decodeInputs(makeBatchInput("f.env", []byte(data), nil), 0, 0); len(got) != 0 {
+		t.Fatalf("expected nothing decoded at depth 0, got %+v", got)
+	}
+	if got := decodeInputs(makeBatchInput("f.env", []byte(data), nil), 2, 100); len(got) != 0 {
+		t.Fatalf("expected nothing decoded below the minimum length, got %+v", got)
+	}
+}
+
+func TestProcessChunk_DecodesBlobs(t *testing.T) {
+	const key = "sk_live_0123456789abcdef"
+	data := []byte("PAYLOAD=" + base64.StdEncoding.EncodeToString([]byte(decodeSecret)) + "\n")
+	run := func(cfg Config) []types.Finding {
+		var got []types.Finding
+		chunk := []pendingScan{{input: makeBatchInput("file.env", data, nil)}}
+		if err := processChunk(&needleScanner{needle: key}, cfg, chunk, func(fs []types.Finding) { got = append(got, fs...) }, map[string]string{}, &Result{}); err != nil {
+			t.Fatal(err)
+		}
+		return got
+	}
+
+	got := run(Config{DecodeDepth: 2})
+	if len(got) != 1 {
+		t.Fatalf("expected one finding, got %+v", got)
+	}
+	if got[0].Path != "file.env::line-1::base64" || got[0].Metadata["encoding_chain"] != "base64" {
+		t.Fatalf("unexpected finding: %+v", got[0])
+	}
+	if got := run(Config{}); len(got) != 0 {
+		t.Fatalf("expected no findings with decoding disabled, got %+v", got)
+	}
+}
@@ -0,0 +1,104 @@
+package engine
+
+import (
+	"fmt"
+
+	"github.com/varalys/redactyl/internal/artifacts"
+	"github.com/varalys/redactyl/internal/scanner"
+	"github.com/varalys/redactyl/internal/textenc"
+	"github.com/varalys/redactyl/internal/types"
+)
+
+// transcoded is an input that was converted to UTF-8 before scanning.
+type transcoded struct {
+	text       textenc.Text
+	lineOffset int
+}
+
+// transcode converts an input stored as UTF-16, UTF-32 or Windows-1252 text
+// to UTF-8, which the scanner's rules expect, and records the original
+// encoding in the "encoding" metadata of its findings.
+func transcode(in scanner.BatchInput, texts map[string]transcoded) scanner.BatchInput {
+	t, ok := textenc.Decode(in.Data)
+	if !ok {
+		return in
+	}
+	in.Data = t.Data
+	meta := cloneMetadata(in.Context.Metadata)
+	meta["encoding"] = t.Encoding
+	in.Context.Metadata = meta
+	texts[in.Path] = transcoded{text: t, lineOffset: in.Context.LineOffset}
+	return in
+}
+
+// remapColumns maps the columns of findings in transcoded inputs from the
+// UTF-8 text that was scanned back to byte columns in the original file.
+// Line numbers are unchanged by transcoding.
+func remapColumns(findings []types.Finding, texts map[string]transcoded) {
+	if len(texts) == 0 {
+		return
+	}
+	for i := range findings {
+		f := &findings[i]
+		tc, ok := texts[f.Path]
+		if !ok || f.Column <= 0 {
+			continue
+		}
+		f.Column = tc.text.Column(f.Line-tc.lineOffset, f.Column)
+	}
+}
+
+// extracted is a binary input replaced by the strings extracted from it.
+type extracted struct {
+	strings    artifacts.BinaryStrings
+	lineOffset int
+}
+
+// extractStrings replaces a binary input with its printable strings, one
+// per line, so that they can be scanned like text.
+func extractStrings(in scanner.BatchInput, bins map[string]extracted) scanner.BatchInput {
+	if !looksBinary(in.Data) {
+		return in
+	}
+	bs := artifacts.ExtractStrings(in.Data)
+	in.Data = bs.Data
+	meta := cloneMetadata(in.Context.Metadata)
+	meta["binary_format"] = bs.Format
+	if bs.Format == "" {
+		meta["binary_format"] = "unknown"
+	}
+	in.Context.Metadata = meta
+	bins[in.Path] = extracted{strings: bs, lineOffset: in.Context.LineOffset}
+	return in
+}
+
+// locateStrings replaces the line and column of findings in extracted
+// binaries with the byte offset of the match in the binary and the section
+// and symbol containing it.
+func locateStrings(findings []types.Finding, bins map[string]extracted) {
+	if len(bins) == 0 {
+		return
+	}
+	for i := range findings {
+		f := &findings[i]
+		ex, ok := bins[f.Path]
+		if !ok {
+			continue
+		}
+		off, section, symbol, ok := ex.strings.Locate(f.Line-ex.lineOffset, f.Column)
+		if !ok {
+			continue
+		}
+		if f.Metadata == nil {
+			f.Metadata = map[string]string{}
+		}
+		f.Metadata["binary_offset"] = fmt.Sprintf("0x%x", off)
+		if section != "" {
+			f.Metadata["binary_section"] = section
+		}
+		if symbol != "" {
+			f.Metadata["binary_symbol"] = symbol
+		}
+		f.Line, f.Column = 0, 0
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"sync"
 	"time"
 
 	doublestar "github.com/bmatcuk/doublestar/v4"
@@ -44,15 +45,45 @@ type Config struct {
 	ScanArchives         bool
 	ScanContainers       bool
 	ScanIaC              bool
+	ScanBinaries         bool     // Extract and scan printable strings from binaries on disk and in artifacts
 	ScanHelm             bool     // Scan Helm charts
+	HelmCharts           []string // Remote Helm charts to scan (oci://host/charts/app:1.2.3 or https://.../app-1.2.3.tgz)
+	HelmRepos            []string // Helm repository URLs whose index.yaml is read to select charts
+	HelmRepoCharts       []string // Chart selectors for HelmRepos ("name", "name@version", "name@*")
+	HelmRender           bool     // Render Helm templates in-process and scan the rendered manifests
+	HelmValues           []string // Extra values files merged over chart values when rendering
 	ScanK8s              bool     // Scan Kubernetes manifests
+	ScanKustomize        bool     // Build kustomization roots in-process and scan the rendered resources
+	ScanK8sCluster       bool     // Scan Secrets, ConfigMaps and workloads from a live cluster (read-only)
+	Kubeconfig           string   // Kubeconfig for ScanK8sCluster (empty = default loading rules)
+	KubeContext          string   // Kubeconfig context for ScanK8sCluster (empty = current context)
+	K8sNamespace         string   // Namespace for ScanK8sCluster (empty = all namespaces)
 	RegistryImages       []string // Remote registry images to scan (e.g. gcr.io/proj/img:tag)
+	RegistryRepos        []string // Remote repositories whose tags are scanned (e.g. host/team/app)
+	RegistryCatalogs     []string // Registries whose whole catalog is scanned (e.g. host)
+	RegistryTags         string   // Comma-separated tag globs for repository/catalog scans (e.g. "v*")
+	RegistryConcurrency  int      // Max manifests scanned concurrently (0 = Threads, at most 4)
 	MaxArchiveBytes      int64
 	MaxEntries           int
 	MaxDepth             int
+	MaxEntryRatio        int // Max decompressed/compressed size of one archive entry (0 = unlimited)
+	MaxArchiveRatio      int // Max bytes decompressed from an archive per byte of it (0 = unlimited)
 	ScanTimeBudget       time.Duration
 	GlobalArtifactBudget time.Duration
 
+	// Sensitive-path detection (files such as .ssh/id_rsa or .aws/credentials)
+	SensitivePaths      []string // Extra doublestar globs to report
+	SensitiveExcludes   []string // Globs of paths never reported
+	NoSensitiveDefaults bool     // Only use SensitivePaths, not the built-in rules
+
+	// Decoding of base64, hex and URL-encoded blobs for rescanning
+	DecodeDepth     int // Levels of nested encoding to decode (0 = disabled)
+	DecodeMinLength int // Shortest encoded blob to decode (0 = 20 characters)
+
+	// Severity policy for decoded JWTs ("" = leave the severity unchanged)
+	JWTExpiredSeverity types.Severity // Severity of tokens whose exp claim has passed
+	JWTAlgNoneSeverity types.Severity // Severity of unsigned ("alg": "none") tokens
+
 	// Gitleaks configuration (for scanner integration)
 	GitleaksConfig config.GitleaksConfig
 }
@@ -98,6 +129,11 @@ func makeBatchInput(path string, data []byte, ctx *scanner.ScanContext) scanner.
 	}
 }
 
+// maxArtifactWorkers caps how many artifacts are scanned at once, whatever
+// Threads is: each artifact in flight may hold up to MaxArchiveBytes of
+// entries until it is its turn to be emitted.
+const maxArtifactWorkers = 4
+
 func determineBatchSize(threads int) int {
 	if threads <= 0 {
 		threads = runtime.GOMAXPROCS(0)
@@ -119,14 +155,35 @@ func processChunk(scnr scanner.Scanner, cfg Config, chunk []pendingScan, emit fu
 	cacheOK := true
 	if !cfg.DryRun {
 		inputs := make([]scanner.BatchInput, len(chunk))
+		var direct []types.Finding
+		if res.certs == nil {
+			res.certs = certIndex{}
+		}
+		texts := map[string]transcoded{}
+		bins := map[string]extracted{}
+		var decoded []scanner.BatchInput
 		for i, job := range chunk {
-			inputs[i] = job.input
+			in := job.input
+			if cfg.ScanBinaries {
+				in = extractStrings(in, bins)
+			}
+			in = transcode(in, texts)
+			inputs[i], direct = maskEncrypted(in, direct)
+			res.certs.add(in.Path, in.Data)
+			if _, ok := bins[in.Path]; !ok {
+				decoded = append(decoded, decodeInputs(inputs[i], cfg.DecodeDepth, cfg.DecodeMinLength)...)
+			}
+		}
+		for _, d := range decoded {
+			res.certs.add(d.Path, d.Data)
 		}
+		inputs = append(inputs, decoded...)
+		emit(filterFindings(cfg, direct))
 		findings, err := scnr.ScanBatch(inputs)
 		if err == nil {
-			findings = filterByConfidence(findings, cfg.MinConfidence)
-			findings = filterByIDs(findings, cfg.EnableDetectors, cfg.DisableDetectors)
-			emit(findings)
+			remapColumns(findings, texts)
+			locateStrings(findings, bins)
+			emit(filterFindings(cfg, findings))
 		} else {
 			cacheOK = false
 		}
@@ -144,11 +201,90 @@ func processChunk(scnr scanner.Scanner, cfg Config, chunk []pendingScan, emit fu
 	return nil
 }
 
-// DetectorIDs returns the list of available Gitleaks detector IDs.
-// This is a representative list of common Gitleaks rules for UI purposes.
-// The actual detection is performed by Gitleaks with its full rule set.
+// maskEncrypted masks SOPS and SealedSecret ciphertext in an input so the
+// scanner does not report it as high-entropy secrets, and appends findings
+// for the values in such files that are stored in plaintext.
+func maskEncrypted(in scanner.BatchInput, out []types.Finding) (scanner.BatchInput, []types.Finding) {
+	masked, ok := artifacts.MaskEncryptedValues(in.Data)
+	if !ok {
+		return in, out
+	}
+	artifacts.ScanEncryptedPlaintext(in.Path, in.Data, func(e artifacts.Entry) {
+		f := entryFinding(e)
+		f.Line += in.Context.LineOffset
+		for k, v := range in.Context.Metadata {
+			if _, ok := f.Metadata[k]; !ok {
+				f.Metadata[k] = v
+			}
+		}
+		out = append(out, f)
+	})
+	in.Data = masked
+	return in, out
+}
+
+// entryFinding converts an entry that an artifact scanner identified as a
+// secret itself (Entry.Detector set) into a finding.
+func entryFinding(e artifacts.Entry) types.Finding {
+	return types.Finding{
+		Path:       e.Path,
+		Line:       e.Line,
+		Match:      string(e.Data),
+		Secret:     string(e.Data),
+		Detector:   e.Detector,
+		Severity:   severityForConfidence(e.Confidence),
+		Confidence: e.Confidence,
+		Context:    artifacts.Detectors[e.Detector],
+		Metadata:   cloneMetadata(e.Metadata),
+	}
+}
+
+// bombFinding reports an artifact that a compression-ratio guardrail stopped
+// as an informational finding.
+func bombFinding(b artifacts.Bomb) types.Finding {
+	return types.Finding{
+		Path:       b.Path,
+		Match:      b.Reason,
+		Detector:   artifacts.DetectorArchiveBomb,
+		Severity:   types.SevInfo,
+		Confidence: 1,
+		Context:    artifacts.Detectors[artifacts.DetectorArchiveBomb],
+		Metadata:   map[string]string{"artifact_type": b.Type},
+	}
+}
+
+// unscannableFinding reports encrypted or corrupt content that an
+// artifact scanner could not look into as a low-severity finding.
+func unscannableFinding(u artifacts.Unscannable) types.Finding {
+	return types.Finding{
+		Path:       u.Path,
+		Match:      u.Reason,
+		Detector:   artifacts.DetectorUnscannableContent,
+		Severity:   types.SevLow,
+		Confidence: 1,
+		Context:    artifacts.Detectors[artifacts.DetectorUnscannableContent],
+		Metadata:   map[string]string{"reason": u.Reason},
+	}
+}
+
+// severityForConfidence uses the same thresholds as the Gitleaks scanner.
+func severityForConfidence(c float64) types.Severity {
+	switch {
+	case c >= 0.9:
+		return types.SevHigh
+	case c >= 0.7:
+		return types.SevMed
+	default:
+		return types.SevLow
+	}
+}
+
+// DetectorIDs returns the list of available detector IDs: a representative
+// list of common Gitleaks rules for UI purposes, plus the detectors built
+// into the artifact scanners. The actual Gitleaks detection is performed
+// with its full rule set.
 func DetectorIDs() []string {
-	return factory.DefaultDetectors()
+	return append(factory.DefaultDetectors(), artifacts.DetectorIDs()...)
 }
 
 // Scan runs a scan and returns only findings (without stats).
@@ -167,6 +303,8 @@ type Result struct {
 	Duration       time.Duration
 	ArtifactStats  DeepStats
 	ArtifactErrors []error
+
+	certs certIndex // PEM certificates in the scanned inputs, by artifact
 }
 
 // DeepStats summarizes artifact scanning abort reasons.
@@ -175,6 +313,10 @@ type DeepStats struct {
 	AbortedByEntries int
 	AbortedByDepth   int
 	AbortedByTime    int
+	AbortedByRatio   int
+	// Artifacts reports each deep-scanned artifact: how much of it was
+	// scanned, why scanning stopped early and what could not be read.
+	Artifacts []types.ArtifactReport
 }
 
 // ScanWithStats runs a scan and returns findings along with timing and counts.
@@ -185,6 +327,10 @@ func ScanWithStats(cfg Config) (Result, error) {
 	if err != nil {
 		return result, fmt.Errorf("failed to initialize scanner: %w", err)
 	}
+	sensitive, err := artifacts.NewSensitivePaths(cfg.SensitivePaths, cfg.SensitiveExcludes, cfg.NoSensitiveDefaults)
+	if err != nil {
+		return result, err
+	}
 
 	var db cache.DB
 	if !cfg.NoCache {
@@ -208,7 +354,7 @@ func ScanWithStats(cfg Config) (Result, error) {
 	}
 
 	if cfg.HistoryCommits == 0 && cfg.BaseBranch == "" {
-		if err := scanFilesystem(ctx, cfg, scnr, ign, db, emit, updated, &result); err != nil {
+		if err := scanFilesystem(ctx, cfg, scnr, ign, sensitive, db, emit, updated, &result); err != nil {
 			return result, err
 		}
 	}
@@ -231,12 +377,16 @@ func ScanWithStats(cfg Config) (Result, error) {
 		}
 	}
 
-	if cfg.ScanArchives || cfg.ScanContainers || cfg.ScanIaC || cfg.ScanHelm || cfg.ScanK8s {
-		if err := scanArtifacts(cfg, scnr, emit, updated, &result); err != nil {
+	if cfg.ScanArchives || cfg.ScanContainers || cfg.ScanIaC || cfg.ScanHelm || cfg.ScanK8s || cfg.ScanKustomize || cfg.ScanK8sCluster ||
+		len(cfg.RegistryImages) > 0 || len(cfg.RegistryRepos) > 0 || len(cfg.RegistryCatalogs) > 0 ||
+		len(cfg.HelmCharts) > 0 || len(cfg.HelmRepos) > 0 {
+		if err := scanArtifacts(cfg, scnr, sensitive, db, emit, updated, &result); err != nil {
 			return result, err
 		}
 	}
 
+	annotatePrivateKeys(out, result.certs)
+	annotateJWTs(out, cfg, time.Now())
 	result.Findings = out
 	result.Duration = time.Since(started)
 	if !cfg.NoCache && len(updated) > 0 {
@@ -251,12 +401,29 @@ func ScanWithStats(cfg Config) (Result, error) {
 	return result, nil
 }
 
-func scanFilesystem(ctx context.Context, cfg Config, scnr scanner.Scanner, ign ignore.Matcher, db cache.DB, emit func([]types.Finding), updated map[string]string, result *Result) error {
+func scanFilesystem(ctx context.Context, cfg Config, scnr scanner.Scanner, ign ignore.Matcher, sensitive *artifacts.SensitivePaths, db cache.DB, emit func([]types.Finding), updated map[string]string, result *Result) error {
 	batchSize := determineBatchSize(cfg.Threads)
 	queue := make([]pendingScan, 0, batchSize)
 	var walkErr error
 
-	err := Walk(ctx, cfg, ign, func(p string, data []byte) {
+	// Files at sensitive paths, keystores and key files are reported
+	// whether or not they are text.
+	inspect := func(p string, data []byte) {
+		if cfg.DryRun {
+			return
+		}
+		var direct []types.Finding
+		if e, ok := sensitive.Match(p, filepath.ToSlash(p), data); ok {
+			direct = append(direct, entryFinding(e))
+		}
+		if e, ok := artifacts.InspectCredentialStore(p, data); ok {
+			direct = append(direct, entryFinding(e))
+		}
+		if len(direct) > 0 {
+			emit(filterFindings(cfg, direct))
+		}
+	}
+	queueFile := func(p string, data []byte) {
 		if walkErr != nil {
 			return
 		}
@@ -275,6 +442,15 @@ func scanFilesystem(ctx context.Context, cfg Config, scnr scanner.Scanner, ign i
 			}
 			queue = queue[:0]
 		}
+	}
+	err := walk(ctx, cfg, ign, func(p string, data []byte) {
+		inspect(p, data)
+		queueFile(p, data)
+	}, func(p string, data []byte) {
+		inspect(p, data)
+		if cfg.ScanBinaries && !looksNonTextMIME(p, data) {
+			queueFile(p, data)
+		}
 	})
 	if err != nil {
 		return err
@@ -399,13 +575,17 @@ func scanDiff(cfg Config, scnr scanner.Scanner, ign ignore.Matcher, emit func([]
 	return nil
 }
 
-func scanArtifacts(cfg Config, scnr scanner.Scanner, emit func([]types.Finding), updated map[string]string, result *Result) error {
+func scanArtifacts(cfg Config, scnr scanner.Scanner, sensitive *artifacts.SensitivePaths, db cache.DB, emit func([]types.Finding), updated map[string]string, result *Result) error {
 	lim := artifacts.Limits{
 		MaxArchiveBytes: cfg.MaxArchiveBytes,
 		MaxEntries:      cfg.MaxEntries,
 		MaxDepth:        cfg.MaxDepth,
+		MaxEntryRatio:   cfg.MaxEntryRatio,
+		MaxArchiveRatio: cfg.MaxArchiveRatio,
+		SensitivePaths:  sensitive,
+		Binaries:        cfg.ScanBinaries,
 		TimeBudget:      cfg.ScanTimeBudget,
-		Workers:         cfg.Threads,
+		Workers:         min(cfg.Threads, maxArtifactWorkers),
 	}
 	if cfg.GlobalArtifactBudget > 0 {
 		lim.GlobalDeadline = time.Now().Add(cfg.GlobalArtifactBudget)
@@ -445,54 +625,168 @@ func scanArtifacts(cfg Config, scnr scanner.Scanner, emit func([]types.Finding),
 			flushArtifacts()
 		}
 	}
+	emitEntry := func(e artifacts.Entry) {
+		if artifactErr != nil {
+			return
+		}
+		if cfg.DryRun {
+			return
+		}
+		if e.Detector != "" {
+			emit(filterFindings(cfg, []types.Finding{entryFinding(e)}))
+			return
+		}
+		ctx := scanner.ScanContext{
+			VirtualPath: e.Path,
+			RealPath:    e.Path,
+			Metadata:    e.Metadata,
+		}
+		if e.Line > 0 {
+			ctx.LineOffset = e.Line - 1
+		}
+		artifactQueue = append(artifactQueue, pendingScan{
+			input:    makeBatchInput(e.Path, e.Data, &ctx),
+			cacheKey: e.Path,
+			cacheVal: fastHash(e.Data),
+		})
+		if len(artifactQueue) >= batchSize {
+			flushArtifacts()
+		}
+	}
 	allowArtifact := func(rel string) bool { return allowedByGlobs(rel, cfg) }
 	var artStats artifacts.Stats
+	helmOpts := artifacts.HelmOptions{Render: cfg.HelmRender, ValuesFiles: cfg.HelmValues}
 
+	// Local artifacts are found in a single walk of cfg.Root, with each path
+	// offered to the handler of every enabled artifact type.
+	var handlers []artifacts.Handler
 	if cfg.ScanArchives {
-		if err := artifacts.ScanArchivesWithStats(cfg.Root, lim, allowArtifact, emitArtifact, &artStats); err != nil {
+		handlers = append(handlers, artifacts.NewArchiveHandler(lim, emitArtifact, &artStats))
+	}
+	if cfg.ScanContainers {
+		handlers = append(handlers, artifacts.NewContainerHandler(lim, emitArtifact, &artStats))
+	}
+	if cfg.ScanIaC {
+		handlers = append(handlers, artifacts.NewIaCHandler(lim, emitEntry, &artStats))
+	}
+	if cfg.ScanHelm {
+		h, err := artifacts.NewHelmHandler(lim, helmOpts, emitEntry, &artStats)
+		if err != nil {
 			result.ArtifactErrors = append(result.ArtifactErrors, err)
+		} else {
+			handlers = append(handlers, h)
 		}
 	}
-	if cfg.ScanContainers {
-		if err := artifacts.ScanContainersWithStats(cfg.Root, lim, allowArtifact, emitArtifact, &artStats); err != nil {
+	if cfg.ScanK8s {
+		handlers = append(handlers, artifacts.NewK8sHandler(lim, emitEntry, &artStats))
+	}
+	if cfg.ScanKustomize {
+		handlers = append(handlers, artifacts.NewKustomizeHandler(lim, emitEntry, &artStats))
+	}
+	if len(handlers) > 0 {
+		if err := artifacts.Discover(cfg.Root, allowArtifact, handlers...); err != nil {
 			result.ArtifactErrors = append(result.ArtifactErrors, err)
 		}
 	}
-	if cfg.ScanIaC {
-		if err := artifacts.ScanIaCWithFilter(cfg.Root, lim, allowArtifact, emitArtifact); err != nil {
+	for _, ref := range cfg.HelmCharts {
+		if err := artifacts.ScanHelmChartRef(ref, lim, helmOpts, emitEntry, &artStats); err != nil {
 			result.ArtifactErrors = append(result.ArtifactErrors, err)
 		}
 	}
-	if cfg.ScanHelm {
-		if err := artifacts.ScanHelmChartsWithFilter(cfg.Root, lim, allowArtifact, emitArtifact); err != nil {
+	for _, repo := range cfg.HelmRepos {
+		if err := artifacts.ScanHelmRepository(repo, cfg.HelmRepoCharts, lim, helmOpts, emitEntry, &artStats); err != nil {
 			result.ArtifactErrors = append(result.ArtifactErrors, err)
 		}
 	}
-	if cfg.ScanK8s {
-		if err := artifacts.ScanK8sManifestsWithFilter(cfg.Root, lim, allowArtifact, emitArtifact); err != nil {
+	if cfg.ScanK8sCluster {
+		opts := artifacts.K8sClusterOptions{Kubeconfig: cfg.Kubeconfig, Context: cfg.KubeContext, Namespace: cfg.K8sNamespace}
+		if err := artifacts.ScanK8sCluster(opts, lim, emitEntry, &artStats); err != nil {
 			result.ArtifactErrors = append(result.ArtifactErrors, err)
 		}
 	}
 	if len(cfg.RegistryImages) > 0 {
-		for _, img := range cfg.RegistryImages {
-			if err := artifacts.ScanRegistryImage(img, lim, emitArtifact, &artStats); err != nil {
+		if err := artifacts.ScanRegistryImages(cfg.RegistryImages, lim, emitArtifact, &artStats); err != nil {
+			result.ArtifactErrors = append(result.ArtifactErrors, err)
+		}
+	}
+	if len(cfg.RegistryRepos) > 0 || len(cfg.RegistryCatalogs) > 0 {
+		var digestsMu sync.Mutex
+		var scannedDigests []string
+		// Digests taken up in this run, so an image reached through both a
+		// repository and a catalog (or two catalogs) is scanned once.
+		seenDigests := map[string]bool{}
+		regOpts := artifacts.RegistryOptions{
+			TagPattern:  cfg.RegistryTags,
+			Concurrency: cfg.RegistryConcurrency,
+			Seen: func(digest string) bool {
+				key := registryCacheKey(digest)
+				digestsMu.Lock()
+				defer digestsMu.Unlock()
+				if seenDigests[key] {
+					return true
+				}
+				seenDigests[key] = true
+				return !cfg.NoCache && db.Entries[key] == digest
+			},
+			Done: func(digest string) {
+				digestsMu.Lock()
+				scannedDigests = append(scannedDigests, digest)
+				digestsMu.Unlock()
+			},
+		}
+		if len(cfg.RegistryRepos) > 0 {
+			if err := artifacts.ScanRegistryRepositories(cfg.RegistryRepos, lim, regOpts, emitEntry, &artStats); err != nil {
+				result.ArtifactErrors = append(result.ArtifactErrors, err)
+			}
+		}
+		for _, reg := range cfg.RegistryCatalogs {
+			if err := artifacts.ScanRegistryCatalog(reg, lim, regOpts, emitEntry, &artStats); err != nil {
 				result.ArtifactErrors = append(result.ArtifactErrors, err)
 			}
 		}
+		flushArtifacts()
+		if artifactErr == nil && !cfg.NoCache && !cfg.DryRun {
+			for _, d := range scannedDigests {
+				updated[registryCacheKey(d)] = d
+			}
+		}
 	}
 	flushArtifacts()
 	if artifactErr != nil {
 		return artifactErr
 	}
+	// Artifacts the scanners could not (fully) look into, and the credential
+	// stores and sensitive files found inside artifacts, are reported too.
+	var reported []types.Finding
+	for _, b := range artStats.Bombs {
+		reported = append(reported, bombFinding(b))
+	}
+	for _, u := range artStats.Unscannable {
+		reported = append(reported, unscannableFinding(u))
+	}
+	for _, e := range artStats.Detected {
+		reported = append(reported, entryFinding(e))
+	}
+	if len(reported) > 0 && !cfg.DryRun {
+		emit(filterFindings(cfg, reported))
+	}
 	result.ArtifactStats = DeepStats{
 		AbortedByBytes:   artStats.AbortedByBytes,
 		AbortedByEntries: artStats.AbortedByEntries,
 		AbortedByDepth:   artStats.AbortedByDepth,
 		AbortedByTime:    artStats.AbortedByTime,
+		AbortedByRatio:   artStats.AbortedByRatio,
+		Artifacts:        artStats.Artifacts,
 	}
 	return nil
 }
 
+// registryCacheKey is the cache entry recording that a registry manifest
+// digest has been scanned, so repository and catalog scans can reuse it.
+func registryCacheKey(digest string) string {
+	return "registry::" + digest
+}
+
 func fastHash(b []byte) string {
 	if len(b) == 0 {
 		return "0000000000000000"
@@ -507,6 +801,11 @@ func fastHash(b []byte) string {
 	return string(buf[:])
 }
 
+func filterFindings(cfg Config, fs []types.Finding) []types.Finding {
+	fs = filterByConfidence(fs, cfg.MinConfidence)
+	return filterByIDs(fs, cfg.EnableDetectors, cfg.DisableDetectors)
+}
+
 func filterByConfidence(fs []types.Finding, min float64) []types.Finding {
 	if min <= 0 {
 		return fs
@@ -0,0 +1,91 @@
+package engine
+
+import (
+	"strings"
+	"time"
+
+	"github.com/varalys/redactyl/internal/types"
+	"github.com/varalys/redactyl/internal/validate"
+)
+
+// jwtIn returns the first JWT-shaped token in s: a run of base64url
+// characters and dots starting with "eyJ" (an encoded '{"').
+func jwtIn(s string) string {
+	i := strings.Index(s, "eyJ")
+	if i < 0 {
+		return ""
+	}
+	s = s[i:]
+	end := strings.IndexFunc(s, func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '-' || r == '_' || r == '.')
+	})
+	if end >= 0 {
+		s = s[:end]
+	}
+	return s
+}
+
+// annotateJWTs decodes the JWTs in findings, without verifying their
+// signatures, and adds their header and claims to the findings' metadata.
+// Expired and unsigned ("alg": "none") tokens are flagged, and their
+// severity is set to cfg.JWTExpiredSeverity and cfg.JWTAlgNoneSeverity
+// when those are set; the higher of the two wins for a token that is both.
+func annotateJWTs(findings []types.Finding, cfg Config, now time.Time) {
+	for i := range findings {
+		f := &findings[i]
+		tok := jwtIn(f.Secret)
+		if tok == "" {
+			tok = jwtIn(f.Match)
+		}
+		j, ok := validate.DecodeJWT(tok)
+		if !ok {
+			continue
+		}
+		if f.Metadata == nil {
+			f.Metadata = map[string]string{}
+		}
+		for k, v := range map[string]string{
+			"jwt_alg": j.Alg,
+			"jwt_kid": j.Kid,
+			"jwt_iss": j.Iss,
+			"jwt_sub": j.Sub,
+			"jwt_aud": strings.Join(j.Aud, ","),
+		} {
+			if v != "" {
+				f.Metadata[k] = v
+			}
+		}
+		if !j.Iat.IsZero() {
+			f.Metadata["jwt_iat"] = j.Iat.Format(time.RFC3339)
+		}
+		var policy []types.Severity
+		if !j.Exp.IsZero() {
+			f.Metadata["jwt_exp"] = j.Exp.Format(time.RFC3339)
+			f.Metadata["jwt_expired"] = "false"
+			if j.Expired(now) {
+				f.Metadata["jwt_expired"] = "true"
+				policy = append(policy, cfg.JWTExpiredSeverity)
+			}
+		}
+		if j.Unsigned() {
+			f.Metadata["jwt_alg_none"] = "true"
+			policy = append(policy, cfg.JWTAlgNoneSeverity)
+		}
+		if sev, ok := highestSeverity(policy); ok {
+			f.Severity = sev
+		}
+	}
+}
+
+var severityRank = map[types.Severity]int{types.SevInfo: 1, types.SevLow: 2, types.SevMed: 3, types.SevHigh: 4}
+
+// highestSeverity returns the highest of the set severities in sevs.
+func highestSeverity(sevs []types.Severity) (types.Severity, bool) {
+	var best types.Severity
+	for _, s := range sevs {
+		if severityRank[s] > severityRank[best] {
+			best = s
+		}
+	}
+	return best, best != ""
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"testing"
+	"time"
+
+	"github.com/varalys/redactyl/internal/types"
+)
+
+const (
+	// {"alg":"RS256","kid":"k1"}.{"iss":"https://issuer.example","sub":"svc","aud":["api","web"],"exp":1700000000,"iat":1600000000}
+	expiredJWT = "eyJhbGciOiJSUzI1NiIsImtpZCI6ImsxIn0.eyJpc3MiOiJodHRwczovL2lzc3Vlci5leGFtcGxlIiwic3ViIjoic3ZjIiwiYXVkIjpbImFwaSIsIndlYiJdLCJleHAiOjE3MDAwMDAwMDAsImlhdCI6MTYwMDAwMDAwMH0.c2ln"
+	// {"alg":"none"}.{"aud":"api"}
+	unsignedJWT = "eyJhbGciOiJub25lIn0.eyJhdWQiOiJhcGkifQ."
+)
+
+func TestAnnotateJWTs(t *testing.T) {
+	findings := []types.Finding{
+		{Path: "a.env", Detector: "jwt", Secret: expiredJWT, Severity: types.SevMed},
+		{Path: "b.env", Detector: "jwt", Match: "Authorization: Bearer " + unsignedJWT, Severity: types.SevMed},
+		{Path: "c.env", Detector: "generic-api-key", Secret: "eyJnot-a-token", Severity: types.SevHigh},
+	}
+	cfg := Config{JWTExpiredSeverity: types.SevLow}
+	annotateJWTs(findings, cfg, time.Unix(1800000000, 0))
+
+	m := findings[0].Metadata
+	want := map[string]string{
+		"jwt_alg":     "RS256",
+		"jwt_kid":     "k1",
+		"jwt_iss":     "https://issuer.example",
+		"jwt_sub":     "svc",
+		"jwt_aud":     "api,web",
+		"jwt_exp":     "2023-11-14T22:13:20Z",
+		"jwt_iat":     "2020-09-13T12:26:40Z",
+		"jwt_expired": "true",
+	}
+	for k, v := range want {
+		if m[k] != v {
+			t.Fatalf("metadata %s = %q, want %q (all: %v)", k, m[k], v, m)
+		}
+	}
+	if findings[0].Severity != types.SevLow {
+		t.Fatalf("expired token severity = %s, want low", findings[0].Severity)
+	}
+	if findings[1].Metadata["jwt_alg_none"] != "true" || findings[1].Severity != types.SevMed {
+		t.Fatalf("alg none token should be flagged and keep its severity by default: %+v", findings[1])
+	}
+	if findings[2].Metadata != nil {
+		t.Fatalf("non-JWT finding annotated: %+v", findings[2])
+	}
+
+	// Before expiry and with an alg none policy.
+	findings = []types.Finding{
+		{Secret: expiredJWT, Severity: types.SevMed},
+		{Secret: unsignedJWT, Severity: types.SevMed},
+	}
+	cfg.JWTAlgNoneSeverity = types.SevHigh
+	annotateJWTs(findings, cfg, time.Unix(1650000000, 0))
+	if findings[0].Metadata["jwt_expired"] != "false" || findings[0].Severity != types.SevMed {
+		t.Fatalf("unexpired token changed: %+v", findings[0])
+	}
+	if findings[1].Severity != types.SevHigh {
+		t.Fatalf("alg none policy not applied: %+v", findings[1])
+	}
+}
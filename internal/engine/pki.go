@@ -0,0 +1,72 @@
+package engine
+
+import (
+	"bytes"
+	"crypto/x509"
+	"strings"
+
+	"github.com/varalys/redactyl/internal/artifacts"
+	"github.com/varalys/redactyl/internal/scanner"
+	"github.com/varalys/redactyl/internal/types"
+)
+
+// certIndex holds the PEM certificates seen in scanned inputs by artifact,
+// so that private keys found by the scanner can be matched to their
+// certificate.
+type certIndex map[string][]*x509.Certificate
+
+// artifactOf returns the artifact a (virtual) path belongs to: the
+// outermost file of an archive, image or chart path, or the path itself.
+func artifactOf(path string) string {
+	if i := strings.Index(path, scanner.VirtualPathSeparator); i >= 0 {
+		return path[:i]
+	}
+	return path
+}
+
+// add indexes the certificates in data, scanned at path.
+func (ci certIndex) add(path string, data []byte) {
+	if !bytes.Contains(data, []byte("-----BEGIN CERTIFICATE-----")) {
+		return
+	}
+	a := artifactOf(path)
+	ci[a] = append(ci[a], artifacts.PEMCertificates(data)...)
+}
+
+// annotatePrivateKeys adds the key type, encryption and fingerprint of PEM
+// private keys in findings to their metadata, along with the subject,
+// issuer and expiry of a certificate for the key from the same artifact.
+// Passphrase-encrypted keys are downgraded to low severity.
+func annotatePrivateKeys(findings []types.Finding, certs certIndex) {
+	for i := range findings {
+		f := &findings[i]
+		secret := f.Secret
+		if !strings.Contains(secret, "PRIVATE KEY") {
+			secret = f.Match
+		}
+		if !strings.Contains(secret, "PRIVATE KEY") {
+			continue
+		}
+		k, ok := artifacts.ParsePEMKey(secret)
+		if !ok {
+			continue
+		}
+		if f.Metadata == nil {
+			f.Metadata = map[string]string{}
+		}
+		for key, v := range k.Metadata() {
+			f.Metadata[key] = v
+		}
+		if k.Encrypted {
+			f.Severity = types.SevLow
+		}
+		for _, c := range certs[artifactOf(f.Path)] {
+			if k.Matches(c) {
+				for key, v := range artifacts.CertificateMetadata(c) {
+					f.Metadata[key] = v
+				}
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package engine
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/varalys/redactyl/internal/types"
+)
+
+func TestAnnotatePrivateKeys(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(7),
+		Subject:      pkix.Name{CommonName: "api.example.com"},
+		NotBefore:    time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:     time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyPEM := string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8}))
+	encPEM := string(pem.EncodeToMemory(&pem.Block{Type: "ENCRYPTED PRIVATE KEY", Bytes: []byte("ciphertext")}))
+
+	certs := certIndex{}
+	certs.add("tls.tgz::certs/tls.crt", pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	findings := []types.Finding{
+		{Path: "tls.tgz::certs/tls.key", Detector: "private-key", Secret: keyPEM, Severity: types.SevHigh},
+		{Path: "other.pem", Detector: "private-key", Secret: keyPEM, Severity: types.SevHigh},
+		{Path: "enc.pem", Detector: "private-key", Secret: encPEM, Severity: types.SevHigh},
+		{Path: "app.env", Detector: "generic-api-key", Secret: "abc123", Severity: types.SevHigh},
+	}
+	annotatePrivateKeys(findings, certs)
+
+	m := findings[0].Metadata
+	if m["key_type"] != "ECDSA P-256" || m["key_encrypted"] != "false" || len(m["key_fingerprint"]) != 64 {
+		t.Fatalf("unexpected key metadata: %v", m)
+	}
+	if m["cert_subject"] != "CN=api.example.com" || m["cert_issuer"] != "CN=api.example.com" || m["cert_not_after"] != "2027-01-01T00:00:00Z" {
+		t.Fatalf("certificate from the same artifact not matched: %v", m)
+	}
+	if _, ok := findings[1].Metadata["cert_subject"]; ok || findings[1].Metadata["key_fingerprint"] != m["key_fingerprint"] {
+		t.Fatalf("certificates must come from the same artifact: %v", findings[1].Metadata)
+	}
+	if findings[2].Severity != types.SevLow || findings[2].Metadata["key_encrypted"] != "true" {
+		t.Fatalf("encrypted key should be low severity: %+v", findings[2])
+	}
+	if findings[3].Metadata != nil {
+		t.Fatalf("non-key finding annotated: %+v", findings[3])
+	}
+}
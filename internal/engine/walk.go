@@ -10,10 +10,17 @@ import (
 
 	"github.com/varalys/redactyl/internal/git"
 	"github.com/varalys/redactyl/internal/ignore"
+	"github.com/varalys/redactyl/internal/textenc"
 )
 
 // Walk traverses the working tree and invokes handle for each eligible file.
 func Walk(ctx context.Context, cfg Config, ign ignore.Matcher, handle func(path string, data []byte)) error {
+	return walk(ctx, cfg, ign, handle, nil)
+}
+
+// walk is Walk with binary, if non-nil, invoked for the eligible files that
+// Walk skips as binary.
+func walk(ctx context.Context, cfg Config, ign ignore.Matcher, handle, binary func(path string, data []byte)) error {
 	return filepath.WalkDir(cfg.Root, func(p string, d fs.DirEntry, err error) error {
 		if err != nil {
 			return nil
@@ -48,6 +55,9 @@ func Walk(ctx context.Context, cfg Config, ign ignore.Matcher, handle func(path
 			return nil
 		}
 		if looksBinary(b) || looksNonTextMIME(rel, b) {
+			if binary != nil {
+				binary(rel, b)
+			}
 			return nil
 		}
 		handle(rel, b)
@@ -55,6 +65,8 @@ func Walk(ctx context.Context, cfg Config, ign ignore.Matcher, handle func(path
 	})
 }
 
+// looksBinary reports whether b has NUL bytes near its start, other than
+// those of UTF-16 or UTF-32 text.
 func looksBinary(b []byte) bool {
 	const sniff = 800
 	n := sniff
@@ -63,7 +75,8 @@ func looksBinary(b []byte) bool {
 	}
 	for i := 0; i < n; i++ {
 		if b[i] == 0 {
-			return true
+			_, ok := textenc.Decode(b)
+			return !ok
 		}
 	}
 	return false
@@ -51,13 +51,15 @@ func PrintText(w io.Writer, findings []types.Finding, opts PrintOptions) {
 		}
 	}
 	// Summary footer
-	high, med, low := 0, 0, 0
+	high, med, low, info := 0, 0, 0, 0
 	for _, f := range findings {
 		switch f.Severity {
 		case types.SevHigh:
 			high++
 		case types.SevMed:
 			med++
+		case types.SevInfo:
+			info++
 		default:
 			low++
 		}
@@ -65,7 +67,11 @@ func PrintText(w io.Writer, findings []types.Finding, opts PrintOptions) {
 	// Summary footer (always show if we have stats)
 	if opts.Duration > 0 || opts.FilesScanned > 0 {
 		fmt.Fprintln(w)
-		fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d)\n", len(findings), high, med, low)
+		if info > 0 {
+			fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d, info: %d)\n", len(findings), high, med, low, info)
+		} else {
+			fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d)\n", len(findings), high, med, low)
+		}
 
 		// Show cache transparency info
 		if opts.TotalFindings > 0 && opts.TotalFindings > len(findings) {
@@ -99,6 +105,8 @@ func colorSeverity(s types.Severity) string {
 		return "\x1b[31mhigh\x1b[0m" // red
 	case types.SevMed:
 		return "\x1b[33mmedium\x1b[0m" // yellow
+	case types.SevInfo:
+		return "info"
 	default:
 		return "\x1b[36mlow\x1b[0m" // cyan
 	}
@@ -139,13 +147,15 @@ func PrintTable(w io.Writer, findings []types.Finding, opts PrintOptions) {
 	}
 
 	// Summary footer (same as PrintText)
-	high, med, low := 0, 0, 0
+	high, med, low, info := 0, 0, 0, 0
 	for _, f := range findings {
 		switch f.Severity {
 		case types.SevHigh:
 			high++
 		case types.SevMed:
 			med++
+		case types.SevInfo:
+			info++
 		default:
 			low++
 		}
@@ -154,7 +164,11 @@ func PrintTable(w io.Writer, findings []types.Finding, opts PrintOptions) {
 	// Summary footer (always show if we have stats)
 	if opts.Duration > 0 || opts.FilesScanned > 0 {
 		fmt.Fprintln(w)
-		fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d)\n", len(findings), high, med, low)
+		if info > 0 {
+			fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d, info: %d)\n", len(findings), high, med, low, info)
+		} else {
+			fmt.Fprintf(w, "Findings: %d (high: %d, medium: %d, low: %d)\n", len(findings), high, med, low)
+		}
 
 		// Show cache transparency info
 		if opts.TotalFindings > 0 && opts.TotalFindings > len(findings) {
@@ -62,3 +62,20 @@ func TestPrintTable_NoFindings_ShowsFooter(t *testing.T) {
 		t.Fatalf("expected footer with files scanned; got: %q", out)
 	}
 }
+
+func TestSummaryFooter_CountsInfoSeparately(t *testing.T) {
+	fs := []types.Finding{
+		{Path: "a.go", Line: 1, Match: "ghp_xxx", Detector: "github_token", Severity: types.SevHigh},
+		{Path: "app.zip", Detector: "unscannable-content", Severity: types.SevInfo},
+	}
+	for name, print := range map[string]func(*bytes.Buffer){
+		"text":  func(b *bytes.Buffer) { PrintText(b, fs, PrintOptions{NoColor: true, FilesScanned: 1}) },
+		"table": func(b *bytes.Buffer) { PrintTable(b, fs, PrintOptions{NoColor: true, FilesScanned: 1}) },
+	} {
+		var buf bytes.Buffer
+		print(&buf)
+		if !strings.Contains(buf.String(), "Findings: 2 (high: 1, medium: 0, low: 0, info: 1)") {
+			t.Fatalf("%s: expected info counted separately; got: %q", name, buf.String())
+		}
+	}
+}
@@ -122,6 +122,12 @@ func WriteSARIF(w io.Writer, findings []types.Finding) error {
 
 // WriteSARIFWithStats writes findings as SARIF and includes artifactStats in the run.properties bag.
 func WriteSARIFWithStats(w io.Writer, findings []types.Finding, artifactStats map[string]int) error {
+	return WriteSARIFWithArtifacts(w, findings, artifactStats, nil)
+}
+
+// WriteSARIFWithArtifacts is like WriteSARIFWithStats and also lists the
+// per-artifact reports under run.properties.artifacts.
+func WriteSARIFWithArtifacts(w io.Writer, findings []types.Finding, artifactStats map[string]int, artifacts []types.ArtifactReport) error {
 	run := sarifRun{Tool: sarifTool{Driver: sarifDriver{Name: "redactyl", Version: time.Now().Format("2006.01.02")}}, Properties: map[string]interface{}{}}
 	// Build rules index
 	ruleIndex := map[string]int{}
@@ -144,6 +150,9 @@ func WriteSARIFWithStats(w io.Writer, findings []types.Finding, artifactStats ma
 	if artifactStats != nil {
 		run.Properties = map[string]interface{}{"artifactStats": artifactStats}
 	}
+	if artifacts != nil {
+		run.Properties["artifacts"] = artifacts
+	}
 	doc := sarif{Version: "2.1.0", Runs: []sarifRun{run}}
 	enc := json.NewEncoder(w)
 	enc.SetIndent("", "  ")
@@ -100,3 +100,28 @@ func TestWriteSARIF_Golden(t *testing.T) {
 		t.Fatalf("expected snippet present")
 	}
 }
+
+func TestWriteSARIFWithArtifacts_ListsArtifacts(t *testing.T) {
+	artifacts := []types.ArtifactReport{
+		{Path: "dist/app.zip", Type: "archive", Entries: 3, Bytes: 120},
+		{Path: "dist/logs.tgz", Type: "archive", Errors: []string{"corrupt gzip: gzip: invalid header"}},
+	}
+	var buf bytes.Buffer
+	if err := WriteSARIFWithArtifacts(&buf, nil, map[string]int{"bytes": 0}, artifacts); err != nil {
+		t.Fatalf("WriteSARIFWithArtifacts: %v", err)
+	}
+	var doc struct {
+		Runs []struct {
+			Properties struct {
+				Artifacts []types.ArtifactReport `json:"artifacts"`
+			} `json:"properties"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatalf("unmarshal: %v; body=%s", err, buf.String())
+	}
+	got := doc.Runs[0].Properties.Artifacts
+	if len(got) != 2 || got[0].Entries != 3 || got[1].Errors[0] != "corrupt gzip: gzip: invalid header" {
+		t.Fatalf("unexpected artifacts: %+v", got)
+	}
+}
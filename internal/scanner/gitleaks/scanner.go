@@ -249,6 +249,7 @@ func normalizeContext(ctx scanner.ScanContext, fallback string) scanner.ScanCont
 		VirtualPath: ctx.VirtualPath,
 		RealPath:    ctx.RealPath,
 		Metadata:    nil,
+		LineOffset:  ctx.LineOffset,
 	}
 	if out.VirtualPath == "" {
 		out.VirtualPath = fallback
@@ -339,7 +340,7 @@ func (s *Scanner) convertFindings(gf []GitleaksFinding, ctx scanner.ScanContext)
 			Detector:   f.RuleID,
 			Match:      f.Match,
 			Secret:     f.Secret,
-			Line:       f.StartLine,
+			Line:       f.StartLine + ctx.LineOffset,
 			Column:     f.StartColumn,
 			Context:    f.Description,
 			Confidence: confidence,
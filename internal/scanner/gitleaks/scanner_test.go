@@ -325,3 +325,13 @@ func TestScanner_NoSecrets(t *testing.T) {
 	require.NoError(t, err)
 	assert.Empty(t, findings)
 }
+
+func TestConvertFindings_LineOffset(t *testing.T) {
+	s := &Scanner{}
+	ctx := scanner.ScanContext{VirtualPath: "secret.yaml::Secret/prod/db::data.password", LineOffset: 9}
+	findings := s.convertFindings([]GitleaksFinding{{RuleID: "generic-api-key", StartLine: 2, StartColumn: 4}}, ctx)
+	require.Len(t, findings, 1)
+	assert.Equal(t, 11, findings[0].Line)
+	assert.Equal(t, 4, findings[0].Column)
+	assert.Equal(t, "secret.yaml::Secret/prod/db::data.password", findings[0].Path)
+}
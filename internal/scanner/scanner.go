@@ -46,6 +46,11 @@ type ScanContext struct {
 	//   - "kubernetes_kind": "Secret"
 	//   - "kubernetes_namespace": "default"
 	Metadata map[string]string
+
+	// LineOffset is added to reported line numbers when the scanned data is
+	// an excerpt of a larger file, such as a single decoded Kubernetes Secret
+	// value; findings then point at the line in the original file.
+	LineOffset int
 }
 
 // VirtualPathSeparator is used to delimit components in virtual paths.
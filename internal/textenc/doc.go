@@ -0,0 +1,4 @@
+// Package textenc detects text stored in encodings other than UTF-8, such as
+// UTF-16 files written by Windows tools, and transcodes it to UTF-8 for
+// scanning, mapping positions back to the original bytes.
+package textenc
@@ -0,0 +1,163 @@
+package textenc
+
+import (
+	"bytes"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/encoding/unicode/utf32"
+)
+
+// Encoding names reported in Text.Encoding.
+const (
+	UTF16LE     = "utf-16le"
+	UTF16BE     = "utf-16be"
+	UTF32LE     = "utf-32le"
+	UTF32BE     = "utf-32be"
+	Windows1252 = "windows-1252"
+)
+
+// sniff is how many leading bytes the heuristics look at.
+const sniff = 800
+
+// Text is content transcoded to UTF-8 from Encoding.
+type Text struct {
+	Data     []byte
+	Encoding string
+}
+
+// Decode transcodes b to UTF-8 if it is text in UTF-16 or UTF-32, detected
+// by byte order mark or by the pattern of NUL bytes around ASCII characters,
+// or in Windows-1252 (a superset of ISO-8859-1), assumed for text without
+// NULs that is not valid UTF-8. It reports false for UTF-8 and ASCII text,
+// which need no transcoding, and for binary data.
+func Decode(b []byte) (Text, bool) {
+	name := detect(b)
+	if name == "" {
+		return Text{}, false
+	}
+	out, err := decoder(name).Bytes(b)
+	if err != nil || !utf8.Valid(out) || bytes.IndexByte(out, 0) >= 0 {
+		return Text{}, false
+	}
+	return Text{Data: out, Encoding: name}, true
+}
+
+func decoder(name string) *encoding.Decoder {
+	switch name {
+	case UTF16LE:
+		return unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()
+	case UTF16BE:
+		return unicode.UTF16(unicode.BigEndian, unicode.UseBOM).NewDecoder()
+	case UTF32LE:
+		return utf32.UTF32(utf32.LittleEndian, utf32.UseBOM).NewDecoder()
+	case UTF32BE:
+		return utf32.UTF32(utf32.BigEndian, utf32.UseBOM).NewDecoder()
+	default:
+		return charmap.Windows1252.NewDecoder()
+	}
+}
+
+// detect names the encoding Decode should try for b, or returns "".
+func detect(b []byte) string {
+	switch {
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE, 0, 0}):
+		return UTF32LE
+	case bytes.HasPrefix(b, []byte{0, 0, 0xFE, 0xFF}):
+		return UTF32BE
+	case bytes.HasPrefix(b, []byte{0xFF, 0xFE}):
+		return UTF16LE
+	case bytes.HasPrefix(b, []byte{0xFE, 0xFF}):
+		return UTF16BE
+	}
+	head := b[:min(len(b), sniff)]
+	if bytes.IndexByte(head, 0) < 0 {
+		if utf8.Valid(b) || bytes.IndexByte(b, 0) >= 0 || !mostlyPrintable(head) {
+			return ""
+		}
+		return Windows1252
+	}
+	if len(b)%4 == 0 {
+		if asciiUnits(head, 4, 0) {
+			return UTF32LE
+		}
+		if asciiUnits(head, 4, 3) {
+			return UTF32BE
+		}
+	}
+	if len(b)%2 == 0 {
+		if asciiUnits(head, 2, 0) {
+			return UTF16LE
+		}
+		if asciiUnits(head, 2, 1) {
+			return UTF16BE
+		}
+	}
+	return ""
+}
+
+// asciiUnits reports whether most size-byte code units in b look like ASCII
+// characters: the byte at index low is set and the others are NUL.
+func asciiUnits(b []byte, size, low int) bool {
+	units, ascii := len(b)/size, 0
+	for i := 0; i+size <= len(b); i += size {
+		ok := b[i+low] != 0 && b[i+low] < 0x80
+		for j := 0; j < size && ok; j++ {
+			ok = j == low || b[i+j] == 0
+		}
+		if ok {
+			ascii++
+		}
+	}
+	return units > 0 && ascii*10 >= units*7
+}
+
+// mostlyPrintable reports whether b has almost no control characters other
+// than whitespace.
+func mostlyPrintable(b []byte) bool {
+	ctrl := 0
+	for _, c := range b {
+		if c < 0x20 && c != '\t' && c != '\n' && c != '\r' && c != '\f' || c == 0x7F {
+			ctrl++
+		}
+	}
+	return ctrl*100 <= len(b)
+}
+
+// Column maps col, a 1-based byte column on the 1-based line of t.Data, to
+// the byte column of the same character in the original encoding. Columns
+// on the first line do not count a byte order mark.
+func (t Text) Column(line, col int) int {
+	if line < 1 || col < 1 {
+		return col
+	}
+	rest := t.Data
+	for i := 1; i < line; i++ {
+		nl := bytes.IndexByte(rest, '\n')
+		if nl < 0 {
+			return col
+		}
+		rest = rest[nl+1:]
+	}
+	if nl := bytes.IndexByte(rest, '\n'); nl >= 0 {
+		rest = rest[:nl]
+	}
+	prefix := rest[:min(col-1, len(rest))]
+	width := 0
+	for _, r := range string(prefix) {
+		switch t.Encoding {
+		case UTF16LE, UTF16BE:
+			width += 2
+			if r > 0xFFFF {
+				width += 2
+			}
+		case UTF32LE, UTF32BE:
+			width += 4
+		default:
+			width++
+		}
+	}
+	return width + 1 + (col - 1 - len(prefix))
+}
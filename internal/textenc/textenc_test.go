@@ -0,0 +1,91 @@
+package textenc
+
+import (
+	"testing"
+	"unicode/utf16"
+)
+
+func encode16(s string, bom, bigEndian bool) []byte {
+	var b []byte
+	if bom {
+		b = []byte{0xFF, 0xFE}
+		if bigEndian {
+			b = []byte{0xFE, 0xFF}
+		}
+	}
+	for _, u := range utf16.Encode([]rune(s)) {
+		if bigEndian {
+			b = append(b, byte(u>>8), byte(u))
+		} else {
+			b = append(b, byte(u), byte(u>>8))
+		}
+	}
+	return b
+}
+
+func encode32LE(s string) []byte {
+	b := []byte{0xFF, 0xFE, 0, 0}
+	for _, r := range s {
+		b = append(b, byte(r), byte(r>>8), byte(r>>16), 0)
+	}
+	return b
+}
+
+func TestDecode(t *testing.T) {
+	const text = "$password = \"hunter2\"\r\n# café ☕\r\n"
+	cases := []struct {
+		name string
+		in   []byte
+		enc  string
+	}{
+		{"utf-16le bom", encode16(text, true, false), UTF16LE},
+		{"utf-16be bom", encode16(text, true, true), UTF16BE},
+		{"utf-16le no bom", encode16(text, false, false), UTF16LE},
+		{"utf-16be no bom", encode16(text, false, true), UTF16BE},
+		{"utf-32le bom", encode32LE(text), UTF32LE},
+	}
+	for _, tc := range cases {
+		got, ok := Decode(tc.in)
+		if !ok || got.Encoding != tc.enc || string(got.Data) != text {
+			t.Fatalf("%s: got %q (%s, %v), want %q (%s)", tc.name, got.Data, got.Encoding, ok, text, tc.enc)
+		}
+	}
+
+	got, ok := Decode([]byte("user=admin\npass=p\xe4ssw\xf6rd\n"))
+	if !ok || got.Encoding != Windows1252 || string(got.Data) != "user=admin\npass=pässwörd\n" {
+		t.Fatalf("windows-1252: got %q (%s, %v)", got.Data, got.Encoding, ok)
+	}
+
+	for name, in := range map[string][]byte{
+		"ascii":  []byte("plain text\n"),
+		"utf-8":  []byte("café ☕\n"),
+		"binary": {0x7F, 'E', 'L', 'F', 2, 1, 1, 0, 0, 0, 0, 0, 0, 0, 0, 0, 3, 0, 0x3E, 0},
+		"empty":  nil,
+	} {
+		if got, ok := Decode(in); ok {
+			t.Fatalf("%s: unexpectedly decoded as %s", name, got.Encoding)
+		}
+	}
+}
+
+func TestText_Column(t *testing.T) {
+	got, ok := Decode(encode16("a\r\nxé😀=secret\r\n", true, false))
+	if !ok {
+		t.Fatal("not decoded")
+	}
+	// "xé😀=" is 1+2+4+1 = 8 bytes in UTF-8 and 2+2+4+2 = 10 in UTF-16.
+	if c := got.Column(2, 9); c != 11 {
+		t.Fatalf("utf-16 column = %d, want 11", c)
+	}
+	if c := got.Column(1, 1); c != 1 {
+		t.Fatalf("first column = %d, want 1", c)
+	}
+	if c := got.Column(9, 4); c != 4 {
+		t.Fatalf("column past the last line = %d, want it unchanged", c)
+	}
+
+	latin := Text{Data: []byte("pässwörd=x"), Encoding: Windows1252}
+	if c := latin.Column(1, 12); c != 10 {
+		t.Fatalf("windows-1252 column = %d, want 10", c)
+	}
+}
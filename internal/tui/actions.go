@@ -782,7 +782,7 @@ func (m *Model) findingsToSARIF(findings []types.Finding) ([]byte, error) {
 		switch f.Severity {
 		case types.SevHigh:
 			level = "error"
-		case types.SevLow:
+		case types.SevLow, types.SevInfo:
 			level = "note"
 		}
 
@@ -76,6 +76,8 @@ func severityText(s types.Severity) string {
 		return "MED"
 	case types.SevLow:
 		return "LOW"
+	case types.SevInfo:
+		return "INFO"
 	default:
 		return string(s)
 	}
@@ -7,6 +7,9 @@ const (
 	SevLow  Severity = "low"
 	SevMed  Severity = "medium"
 	SevHigh Severity = "high"
+	// SevInfo marks informational findings, such as an archive skipped as
+	// a likely zip bomb, that never fail a scan.
+	SevInfo Severity = "info"
 )
 
 // Finding describes a potential secret or sensitive value detected at a path
@@ -23,3 +26,17 @@ type Finding struct {
 	Context    string            `json:"context,omitempty"`  // Additional context or description
 	Metadata   map[string]string `json:"metadata,omitempty"` // Artifact-specific metadata
 }
+
+// ArtifactReport describes how one deep-scanned artifact (archive, image,
+// chart, manifest, ...) was covered: how much of it was scanned, the
+// guardrail that stopped the scan early, if any, and the problems that left
+// parts of it unscanned, such as a corrupt gzip stream or an encrypted zip
+// entry.
+type ArtifactReport struct {
+	Path    string   `json:"path"`
+	Type    string   `json:"type"`
+	Entries int      `json:"entries"`           // text entries passed on for scanning
+	Bytes   int64    `json:"bytes"`             // bytes read or decompressed; emitted bytes for rendered charts and kustomizations
+	Aborted string   `json:"aborted,omitempty"` // bytes, entries, depth, time or ratio
+	Errors  []string `json:"errors,omitempty"`
+}
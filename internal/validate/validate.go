@@ -1,10 +1,13 @@
 package validate
 
 import (
+	"bytes"
 	"encoding/base32"
 	"encoding/base64"
 	"encoding/hex"
+	"encoding/json"
 	"strings"
+	"time"
 )
 
 // LengthBetween returns true if n is within [min,max].
@@ -141,3 +144,88 @@ func IsJWTStructure(s string) bool {
 	// signature can be empty or non-decodable; we do not require decoding
 	return true
 }
+
+// JWT holds the header fields and registered claims of a JSON Web Token.
+type JWT struct {
+	Alg string
+	Kid string
+	Iss string
+	Sub string
+	Aud []string
+	Exp time.Time // zero if absent
+	Iat time.Time // zero if absent
+}
+
+// DecodeJWT decodes the header and payload of the JWT s without verifying
+// its signature. It reports false unless both are base64url-encoded JSON
+// objects.
+func DecodeJWT(s string) (JWT, bool) {
+	var j JWT
+	if !IsJWTStructure(s) {
+		return j, false
+	}
+	parts := strings.Split(s, ".")
+	header, ok := decodeJWTSegment(parts[0])
+	if !ok {
+		return j, false
+	}
+	claims, ok := decodeJWTSegment(parts[1])
+	if !ok {
+		return j, false
+	}
+	j.Alg, _ = header["alg"].(string)
+	j.Kid, _ = header["kid"].(string)
+	j.Iss, _ = claims["iss"].(string)
+	j.Sub, _ = claims["sub"].(string)
+	switch aud := claims["aud"].(type) {
+	case string:
+		j.Aud = []string{aud}
+	case []any:
+		for _, a := range aud {
+			if s, ok := a.(string); ok {
+				j.Aud = append(j.Aud, s)
+			}
+		}
+	}
+	j.Exp = numericDate(claims["exp"])
+	j.Iat = numericDate(claims["iat"])
+	return j, true
+}
+
+// Expired reports whether the token has an expiry that is before now.
+func (j JWT) Expired(now time.Time) bool {
+	return !j.Exp.IsZero() && now.After(j.Exp)
+}
+
+// Unsigned reports whether the token uses the "none" algorithm.
+func (j JWT) Unsigned() bool {
+	return strings.EqualFold(j.Alg, "none")
+}
+
+// decodeJWTSegment decodes a base64url-encoded JSON object.
+func decodeJWTSegment(seg string) (map[string]any, bool) {
+	b, err := base64.RawURLEncoding.DecodeString(seg)
+	if err != nil {
+		return nil, false
+	}
+	var m map[string]any
+	d := json.NewDecoder(bytes.NewReader(b))
+	d.UseNumber()
+	if err := d.Decode(&m); err != nil || m == nil {
+		return nil, false
+	}
+	return m, true
+}
+
+// numericDate converts a JWT NumericDate (seconds since the epoch) claim.
+func numericDate(v any) time.Time {
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}
+	}
+	f, err := n.Float64()
+	if err != nil || f <= 0 {
+		return time.Time{}
+	}
+	return time.Unix(int64(f), 0).UTC()
+}
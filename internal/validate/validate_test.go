@@ -1,6 +1,9 @@
 package validate
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func TestLengthBetween(t *testing.T) {
 	if !LengthBetween("abcd", 2, 5) {
@@ -89,3 +92,38 @@ func TestIsJWTStructure(t *testing.T) {
 		t.Fatal("expected invalid jwt structure")
 	}
 }
+
+func TestDecodeJWT(t *testing.T) {
+	// header: {"alg":"RS256","kid":"k1"}
+	// payload: {"iss":"https://issuer.example","sub":"svc","aud":["api","web"],"exp":1700000000,"iat":1600000000}
+	jwt := "eyJhbGciOiJSUzI1NiIsImtpZCI6ImsxIn0." +
+		"eyJpc3MiOiJodHRwczovL2lzc3Vlci5leGFtcGxlIiwic3ViIjoic3ZjIiwiYXVkIjpbImFwaSIsIndlYiJdLCJleHAiOjE3MDAwMDAwMDAsImlhdCI6MTYwMDAwMDAwMH0." +
+		"sig"
+	j, ok := DecodeJWT(jwt)
+	if !ok {
+		t.Fatal("expected jwt to decode")
+	}
+	if j.Alg != "RS256" || j.Kid != "k1" || j.Iss != "https://issuer.example" || j.Sub != "svc" || len(j.Aud) != 2 || j.Aud[1] != "web" {
+		t.Fatalf("unexpected claims: %+v", j)
+	}
+	if !j.Exp.Equal(time.Unix(1700000000, 0)) || !j.Iat.Equal(time.Unix(1600000000, 0)) {
+		t.Fatalf("unexpected exp/iat: %v %v", j.Exp, j.Iat)
+	}
+	if !j.Expired(time.Unix(1700000001, 0)) || j.Expired(time.Unix(1699999999, 0)) {
+		t.Fatal("expiry check failed")
+	}
+	if j.Unsigned() {
+		t.Fatal("RS256 token reported as unsigned")
+	}
+
+	// header: {"alg":"none"}, payload: {"aud":"api"}
+	j, ok = DecodeJWT("eyJhbGciOiJub25lIn0.eyJhdWQiOiJhcGkifQ.")
+	if !ok || !j.Unsigned() || len(j.Aud) != 1 || j.Aud[0] != "api" || !j.Exp.IsZero() || j.Expired(time.Now()) {
+		t.Fatalf("unexpected alg none token: %+v", j)
+	}
+
+	// payload: null
+	if _, ok := DecodeJWT("eyJhbGciOiJub25lIn0.bnVsbA.x"); ok {
+		t.Fatal("expected non-object payload to be rejected")
+	}
+}
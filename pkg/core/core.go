@@ -12,6 +12,7 @@ type Config = engine.Config
 type Finding = types.Finding
 type Result = engine.Result
 type DeepStats = engine.DeepStats
+type ArtifactReport = types.ArtifactReport
 
 // Scan is the stable entrypoint for other programs.
 func Scan(cfg Config) ([]Finding, error) {